@@ -2,11 +2,43 @@ package agent
 
 import (
 	"context"
+	"errors"
+	"fmt"
 	"log/slog"
+	"slices"
+	"strings"
+	"time"
 
+	"github.com/rickif/tiny-research/internal/cache"
 	"github.com/rickif/tiny-research/internal/config"
+	"github.com/rickif/tiny-research/internal/history"
+	"github.com/rickif/tiny-research/internal/rag"
+	"github.com/rickif/tiny-research/internal/replay"
+	"github.com/rickif/tiny-research/internal/report"
+	"github.com/rickif/tiny-research/internal/resilience"
+	"github.com/rickif/tiny-research/internal/telemetry"
+	"github.com/rickif/tiny-research/internal/tool"
+	"github.com/rickif/tiny-research/internal/transcript"
+	"github.com/tmc/langchaingo/embeddings"
 	"github.com/tmc/langchaingo/llms"
 	"github.com/tmc/langchaingo/llms/openai"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/trace"
+)
+
+var (
+	// ErrCanceled is returned by Research when the run is canceled or times
+	// out before the report is written.
+	ErrCanceled = errors.New("research canceled")
+	// ErrIncomplete is returned by Research when a node fails and the report
+	// was written from the steps executed so far.
+	ErrIncomplete = errors.New("research incomplete")
+	// ErrBudgetExhausted is returned by nodes when the run has used up its
+	// tool call budget.
+	ErrBudgetExhausted = errors.New("tool call budget exhausted")
+	// ErrEmptyAnswer is returned by Research when the model answers the
+	// question directly with no content.
+	ErrEmptyAnswer = errors.New("empty answer")
 )
 
 type Node interface {
@@ -14,56 +46,364 @@ type Node interface {
 }
 
 type Agent struct {
-	llm    *openai.LLM
-	config *config.Config
+	llm         llms.Model
+	config      *config.Config
+	searcher    tool.Searcher
+	crawler     tool.Crawler
+	python      tool.PythonRunner
+	localSearch *tool.LocalSearch
+	embedder    embeddings.Embedder
+	cache       *cache.Cache
+	recorder    *replay.Recorder
+	history     *history.Store
+	now         func() time.Time
+}
+
+// Option overrides a dependency of the Agent, e.g. to run it against fakes.
+type Option func(*Agent)
+
+func WithModel(model llms.Model) Option {
+	return func(agent *Agent) { agent.llm = model }
+}
+
+func WithSearcher(searcher tool.Searcher) Option {
+	return func(agent *Agent) { agent.searcher = searcher }
+}
+
+func WithCrawler(crawler tool.Crawler) Option {
+	return func(agent *Agent) { agent.crawler = crawler }
+}
+
+func WithPython(python tool.PythonRunner) Option {
+	return func(agent *Agent) { agent.python = python }
+}
+
+func WithEmbedder(embedder embeddings.Embedder) Option {
+	return func(agent *Agent) { agent.embedder = embedder }
+}
+
+func WithClock(now func() time.Time) Option {
+	return func(agent *Agent) { agent.now = now }
+}
+
+func NewAgent(config config.Config, options ...Option) (*Agent, error) {
+	agent := &Agent{
+		config: &config,
+		now:    time.Now,
+	}
+	for _, option := range options {
+		option(agent)
+	}
+
+	if config.ReplayPath != "" {
+		replayer, err := replay.NewReplayer(config.ReplayPath)
+		if err != nil {
+			return nil, err
+		}
+		agent.useReplayer(replayer)
+	} else {
+		if err := agent.useDefaults(); err != nil {
+			return nil, err
+		}
+		// the metrics of the LLM calls sit beneath the cache and the cassette,
+		// so they only cover the calls that reach the provider
+		agent.llm = telemetry.NewProviderModel(agent.llm, config.LLMModel)
+		if !config.NoCache {
+			agent.cache = cache.New(config.CacheDir, map[string]time.Duration{
+				cache.KindLLM:    config.CacheTTLLLM,
+				cache.KindSearch: config.CacheTTLSearch,
+				cache.KindCrawl:  config.CacheTTLCrawl,
+			}, config.RefreshCache)
+			telemetry.ObserveCache(agent.cache.Stats)
+			agent.llm = cache.NewModel(agent.llm, agent.cache, config.LLMModel, config.LLMBaseURL)
+			agent.searcher = cache.NewSearcher(agent.searcher, agent.cache)
+			agent.crawler = cache.NewCrawler(agent.crawler, agent.cache)
+		}
+		if config.RecordPath != "" {
+			recorder, err := replay.NewRecorder(config.RecordPath)
+			if err != nil {
+				return nil, err
+			}
+			agent.useRecorder(recorder)
+		}
+	}
+
+	// the spans and the transcript wrap the cache and the cassette, so they
+	// cover every call of the nodes; spans are only recorded once
+	// telemetry.Setup ran
+	agent.llm = telemetry.NewModel(agent.llm, config.LLMModel)
+	agent.searcher = telemetry.NewSearcher(agent.searcher)
+	agent.crawler = telemetry.NewCrawler(agent.crawler)
+	agent.python = telemetry.NewPython(agent.python)
+
+	if config.LocalDocsDir != "" {
+		index, err := rag.BuildIndex(config.LocalDocsDir, config.LocalDocsChunkSize, config.LocalDocsChunkOverlap)
+		if err != nil {
+			return nil, err
+		}
+		slog.Info("local documents indexed", "dir", config.LocalDocsDir, "chunks", index.Len())
+		agent.localSearch = tool.NewLocalSearch(index, config.LocalDocsTopK)
+	}
+
+	if config.HistoryDB != "" && config.HistoryDB != "off" {
+		store, err := history.Open(config.HistoryDB)
+		if err != nil {
+			return nil, err
+		}
+		agent.history = store
+	}
+	return agent, nil
+}
+
+// useDefaults fills every dependency not set by an Option with the live
+// implementation.
+func (wf *Agent) useDefaults() error {
+	llmClient := resilience.NewClient("llm", wf.policy(wf.config.LLMMaxConcurrency, wf.config.LLMRPM))
+	if wf.llm == nil {
+		llm, err := openai.New(openai.WithBaseURL(wf.config.LLMBaseURL), openai.WithModel(wf.config.LLMModel), openai.WithToken(wf.config.LLMToken), openai.WithHTTPClient(llmClient))
+		if err != nil {
+			return err
+		}
+		wf.llm = llm
+	}
+	if wf.searcher == nil {
+		wf.searcher = tool.NewTavilySearchTool(wf.config.TavilyKey, resilience.NewClient("tavily", wf.policy(wf.config.SearchMaxConcurrency, wf.config.SearchRPM)))
+	}
+	if wf.crawler == nil {
+		wf.crawler = tool.NewJinaCrawler(resilience.NewClient("jina", wf.policy(wf.config.CrawlMaxConcurrency, wf.config.CrawlRPM)))
+	}
+	if wf.python == nil {
+		wf.python = tool.PythonFunc(tool.Python)
+	}
+	wf.searcher = tool.SearcherWithTimeout(wf.searcher, wf.config.SearchTimeout)
+	wf.crawler = tool.CrawlerWithTimeout(wf.crawler, wf.config.CrawlTimeout)
+	wf.python = tool.PythonWithTimeout(wf.python, wf.config.PythonTimeout)
+	if wf.embedder == nil && wf.config.EmbeddingModel != "" {
+		client, err := openai.New(openai.WithBaseURL(wf.config.LLMBaseURL), openai.WithToken(wf.config.LLMToken), openai.WithEmbeddingModel(wf.config.EmbeddingModel), openai.WithHTTPClient(llmClient))
+		if err != nil {
+			return err
+		}
+		wf.embedder, err = embeddings.NewEmbedder(client)
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (wf *Agent) policy(maxConcurrency int, requestsPerMinute int) resilience.Policy {
+	policy := resilience.DefaultPolicy()
+	policy.MaxRetries = wf.config.MaxRetries
+	policy.MaxConcurrency = maxConcurrency
+	policy.RequestsPerMinute = requestsPerMinute
+	return policy
+}
+
+// useRecorder wraps every dependency so its calls are written to the cassette.
+func (wf *Agent) useRecorder(recorder *replay.Recorder) {
+	wf.recorder = recorder
+	wf.llm = recorder.Model(wf.llm)
+	wf.searcher = recorder.Searcher(wf.searcher)
+	wf.crawler = recorder.Crawler(wf.crawler)
+	wf.python = recorder.Python(wf.python)
+	if wf.embedder != nil {
+		wf.embedder = recorder.Embedder(wf.embedder)
+	}
+	wf.now = recorder.Now
+}
+
+// useReplayer serves every dependency not set by an Option from the cassette.
+func (wf *Agent) useReplayer(replayer *replay.Replayer) {
+	if wf.llm == nil {
+		wf.llm = replayer.Model()
+	}
+	if wf.searcher == nil {
+		wf.searcher = replayer.Searcher()
+	}
+	if wf.crawler == nil {
+		wf.crawler = replayer.Crawler()
+	}
+	if wf.python == nil {
+		wf.python = replayer.Python()
+	}
+	if wf.embedder == nil && wf.config.EmbeddingModel != "" {
+		wf.embedder = replayer.Embedder()
+	}
+	wf.now = replayer.Now
+}
+
+// Close flushes and releases the resources held by the agent.
+func (wf *Agent) Close() error {
+	var errs []error
+	if wf.recorder != nil {
+		errs = append(errs, wf.recorder.Close())
+	}
+	if wf.history != nil {
+		errs = append(errs, wf.history.Close())
+	}
+	return errors.Join(errs...)
+}
+
+// CacheStats returns the cache hit and miss counts accumulated so far, or nil
+// if caching is disabled.
+func (wf *Agent) CacheStats() []cache.Stat {
+	if wf.cache == nil {
+		return nil
+	}
+	return wf.cache.Stats()
+}
+
+// ResearchOption sets a parameter of a single research run.
+type ResearchOption func(*AgentState)
+
+// WithReportStyle selects the report style of the run, see ReportStyles.
+func WithReportStyle(style string) ResearchOption {
+	return func(state *AgentState) {
+		state.ReportStyle = style
+	}
+}
+
+// WithLocale sets the locale of the run, e.g. "zh-CN".
+func WithLocale(locale string) ResearchOption {
+	return func(state *AgentState) {
+		state.Locale = locale
+	}
+}
+
+// WithPlanLimits bounds the steps of each plan and the planning iterations
+// of the run; zero keeps the default of 3.
+func WithPlanLimits(maxSteps int, maxIterations int) ResearchOption {
+	return func(state *AgentState) {
+		state.MaxStepNum = maxSteps
+		state.MaxPlanIterations = maxIterations
+	}
 }
 
-func NewAgent(config config.Config) (*Agent, error) {
-	llm, err := openai.New(openai.WithBaseURL(config.LLMBaseURL), openai.WithModel(config.LLMModel), openai.WithToken(config.LLMToken))
+// WithPlannerInstructions adds instructions the planner must follow, e.g. a
+// step every plan must include.
+func WithPlannerInstructions(instructions string) ResearchOption {
+	return func(state *AgentState) {
+		state.PlannerInstructions = instructions
+	}
+}
+
+// WithTools restricts the run to the tools named, see ToolNames.
+func WithTools(names ...string) ResearchOption {
+	return func(state *AgentState) {
+		state.AllowedTools = append([]string{}, names...)
+	}
+}
+
+// ToolNames returns the names of the tools a run can be restricted to.
+func ToolNames() []string {
+	return []string{
+		tool.SearchTool.Function.Name,
+		tool.CrawlTool.Function.Name,
+		tool.LocalSearchTool.Function.Name,
+		tool.RetrieveTool.Function.Name,
+		tool.PythonTool.Function.Name,
+	}
+}
+
+// validateOptions checks the parameters set by the research options.
+func validateOptions(state *AgentState) error {
+	if _, err := LookupReportStyle(state.ReportStyle); err != nil {
+		return err
+	}
+	if state.MaxStepNum < 0 || state.MaxPlanIterations < 0 {
+		return fmt.Errorf("plan limits must not be negative, got %d steps and %d iterations", state.MaxStepNum, state.MaxPlanIterations)
+	}
+	for _, name := range state.AllowedTools {
+		if !slices.Contains(ToolNames(), name) {
+			return fmt.Errorf("unknown tool %q, expected one of %s", name, strings.Join(ToolNames(), ", "))
+		}
+	}
+	return nil
+}
+
+// Research researches query and returns the report along with the plan it
+// was written from. On failure or cancellation the report, when some steps
+// were executed, is returned along with the error; it is nil otherwise.
+func (wf *Agent) Research(ctx context.Context, query string, options ...ResearchOption) (*report.Report, error) {
+	session, err := wf.NewSession(options...)
 	if err != nil {
 		return nil, err
 	}
-	return &Agent{
-		llm:    llm,
-		config: &config,
-	}, nil
+	return session.Ask(ctx, query)
 }
 
-func (wf *Agent) Research(ctx context.Context, query string) (string, error) {
-	state := AgentState{
-		Messages: []llms.MessageContent{
-			{
-				Role:  llms.ChatMessageTypeHuman,
-				Parts: []llms.ContentPart{llms.TextContent{Text: query}},
-			},
-		},
-		Locale: "en-US",
-	}
+// research runs the nodes of the graph until the report is written.
+func (wf *Agent) research(ctx context.Context, state *AgentState, retriever *tool.Retriever) (string, error) {
 	coordinator := NewCoordinator(wf.llm)
-	planner := NewPlanner(wf.llm, 3, 3)
+	maxStepNum, maxIterations := 3, 3
+	if state.MaxStepNum > 0 {
+		maxStepNum = state.MaxStepNum
+	}
+	if state.MaxPlanIterations > 0 {
+		maxIterations = state.MaxPlanIterations
+	}
+	planner := NewPlanner(wf.llm, maxIterations, maxStepNum, wf.config.LLMStructuredOutput)
 	researchTeam := NewResearchTeam(wf.llm)
-	researcher := NewResearcher(wf.llm, wf.config.TavilyKey)
-	coder := NewCoder(wf.llm)
-	reporter := NewReporter(wf.llm)
+	researcher := NewResearcher(wf.llm, wf.searcher, wf.crawler, wf.localSearch, retriever)
+	coder := NewCoder(wf.llm, wf.python)
+	reporter := NewReporter(wf.llm, retriever, wf.config.LLMStructuredOutput)
+	var verifier *Verifier
+	if wf.config.VerifyClaims {
+		verifier = NewVerifier(wf.llm, wf.searcher, retriever, wf.config.VerifyMaxClaims, wf.config.LLMStructuredOutput)
+	}
+	var critic *Critic
+	if wf.config.CritiqueReport {
+		critic = NewCritic(wf.llm, wf.config.CritiqueMaxRevisions, maxStepNum, wf.config.LLMStructuredOutput)
+	}
 
-	nextStep, output, err := coordinator.Execute(ctx, &state)
+	nextStep, output, err := wf.execute(ctx, StepCoordinator, coordinator, state)
 	if err != nil {
 		slog.Error("coordinate", "error", err)
-		return "", err
+		return wf.interrupted(ctx, reporter, state, StepCoordinator, err)
 	}
 
 	for {
-		switch nextStep {
+		step := nextStep
+		if step == StepReporter && verifier != nil && !state.Verified {
+			step = StepVerifier
+		}
+		if step == StepEnd && critic != nil && state.Report != nil && !state.Reviewed {
+			step = StepCritic
+		}
+		switch step {
 		case StepPlanner:
-			nextStep, output, err = planner.Execute(ctx, &state)
+			nextStep, output, err = wf.execute(ctx, step, planner, state)
 		case StepResearchTeam:
-			nextStep, output, err = researchTeam.Execute(ctx, &state)
+			if state.Control != nil {
+				state.Control.applyEdit(state)
+			}
+			nextStep, output, err = wf.execute(ctx, step, researchTeam, state)
 		case StepResearcher:
-			nextStep, output, err = researcher.Execute(ctx, &state)
+			nextStep, output, err = wf.execute(ctx, step, researcher, state)
 		case StepCoder:
-			nextStep, output, err = coder.Execute(ctx, &state)
+			nextStep, output, err = wf.execute(ctx, step, coder, state)
+		case StepVerifier:
+			nextStep, output, err = wf.execute(ctx, step, verifier, state)
+			if err != nil && ctx.Err() == nil {
+				// verification is best effort, report without it
+				slog.Error("verify claims", "error", err)
+				state.Verification = nil
+				nextStep, err = StepReporter, nil
+			}
 		case StepReporter:
-			nextStep, output, err = reporter.Execute(ctx, &state)
+			nextStep, output, err = wf.execute(ctx, step, reporter, state)
+		case StepCritic:
+			nextStep, output, err = wf.execute(ctx, step, critic, state)
+			if err != nil {
+				// the draft is complete, so it is the result even when the
+				// review fails
+				slog.Error("critique report", "error", err)
+				if ctx.Err() != nil {
+					return state.Report.Markdown(), fmt.Errorf("%w during %s: %w", ErrCanceled, step, context.Cause(ctx))
+				}
+				state.Reviewed = true
+				nextStep, output, err = StepEnd, state.Report.Markdown(), nil
+			}
 		case StepEnd:
 			return output, nil
 		default:
@@ -71,8 +411,193 @@ func (wf *Agent) Research(ctx context.Context, query string) (string, error) {
 			return "", err
 		}
 		if err != nil {
-			slog.Error("execute", "error", err)
-			return "", err
+			slog.Error("execute", "step", step, "error", err)
+			return wf.interrupted(ctx, reporter, state, step, err)
+		}
+	}
+}
+
+// execute runs node in a span of its own, bounded by the configured node
+// timeout and, for a plan step, skippable by the Control of the run. It
+// records its duration and, in the transcript, its output and the plan it
+// revised.
+func (wf *Agent) execute(ctx context.Context, step string, node Node, state *AgentState) (nextStep string, output string, err error) {
+	start := time.Now()
+	ctx, span := telemetry.Tracer().Start(ctx, "node "+step, trace.WithAttributes(attribute.String("node.step", step)))
+	ctx = transcript.WithNode(ctx, step)
+	transcript.Record(ctx, transcript.Event{Kind: transcript.KindNodeStart})
+	defer func() {
+		span.SetAttributes(
+			attribute.String("node.next_step", nextStep),
+			attribute.Int("research.tool_calls", state.ToolCalls),
+		)
+		telemetry.End(span, err)
+		telemetry.NodeFinished(step, time.Since(start), err)
+
+		transcript.RecordPlan(ctx, planProgress(state))
+		event := transcript.Event{Kind: transcript.KindNodeEnd, NextStep: nextStep, Output: output, Duration: time.Since(start)}
+		if err != nil {
+			event.Error = err.Error()
+		}
+		transcript.Record(ctx, event)
+	}()
+
+	nodeCtx := ctx
+	if wf.config.NodeTimeout > 0 {
+		var cancel context.CancelFunc
+		nodeCtx, cancel = context.WithTimeout(ctx, wf.config.NodeTimeout)
+		defer cancel()
+	}
+	if state.Control != nil && (step == StepResearcher || step == StepCoder) {
+		var skip context.CancelCauseFunc
+		nodeCtx, skip = context.WithCancelCause(nodeCtx)
+		defer skip(nil)
+		defer state.Control.executing(skip)()
+	}
+
+	nextStep, output, err = node.Execute(nodeCtx, state)
+	switch {
+	case err == nil || ctx.Err() != nil:
+	case errors.Is(context.Cause(nodeCtx), errStepSkipped):
+		nextStep, output, err = StepResearchTeam, skipStep(state), nil
+	case errors.Is(nodeCtx.Err(), context.DeadlineExceeded):
+		err = fmt.Errorf("%s timed out after %s: %w", step, wf.config.NodeTimeout, err)
+	}
+	return nextStep, output, err
+}
+
+// planProgress returns the plan of the run with the steps executed so far.
+// Once the planner has enough context its plan may have no steps, so the
+// steps are those of the plan before.
+func planProgress(state *AgentState) *transcript.Plan {
+	plan := state.CurrentPlan
+	if plan == nil {
+		return nil
+	}
+	steps := plan.Steps
+	if plan.HasEnoughContext && len(steps) == 0 && state.LastPlan != nil {
+		steps = state.LastPlan.Steps
+	}
+	progress := &transcript.Plan{Title: plan.Title, Thought: plan.Thought, Steps: []transcript.Step{}}
+	for _, step := range steps {
+		progress.Steps = append(progress.Steps, transcript.Step{
+			Title:       step.Title,
+			Description: step.Description,
+			Type:        step.StepType,
+			Done:        step.ExecutionResult != "",
+			Skipped:     step.Skipped,
+		})
+	}
+	return progress
+}
+
+// interrupted turns the error of a failed step into the result of Research.
+// If some plan steps were already executed, the reporter writes a report
+// from their findings, marked as incomplete, which is returned along with an
+// ErrCanceled or ErrIncomplete error.
+func (wf *Agent) interrupted(ctx context.Context, reporter *Reporter, state *AgentState, step string, err error) (string, error) {
+	reason := err.Error()
+	if ctx.Err() != nil {
+		reason = context.Cause(ctx).Error()
+		err = fmt.Errorf("%w during %s: %w", ErrCanceled, step, context.Cause(ctx))
+	} else {
+		err = fmt.Errorf("%w: %w", ErrIncomplete, err)
+	}
+
+	if !hasFindings(state) {
+		return "", err
+	}
+	state.Interruption = &Interruption{Step: step, Reason: reason}
+
+	// the run context may be canceled already, so the report gets its own
+	reportCtx := context.WithoutCancel(ctx)
+	if wf.config.ReportTimeout > 0 {
+		var cancel context.CancelFunc
+		reportCtx, cancel = context.WithTimeout(reportCtx, wf.config.ReportTimeout)
+		defer cancel()
+	}
+	_, output, reportErr := wf.execute(reportCtx, StepReporter, reporter, state)
+	if reportErr != nil {
+		slog.Error("report partial results", "error", reportErr)
+		state.Report = partialResult(state)
+		return state.Report.Markdown(), err
+	}
+	return output, err
+}
+
+func hasFindings(state *AgentState) bool {
+	if state.CurrentPlan == nil {
+		return false
+	}
+	for _, step := range state.CurrentPlan.Steps {
+		if step.ExecutionResult != "" {
+			return true
+		}
+	}
+	return false
+}
+
+// describe fills the metadata of the report of a run.
+func (wf *Agent) describe(result *report.Report, query string, state *AgentState, duration time.Duration) {
+	result.Metadata = report.Metadata{
+		Query:      query,
+		Style:      state.ReportStyle,
+		Model:      wf.config.LLMModel,
+		SessionID:  state.SessionID,
+		Turn:       state.Turn,
+		CreatedAt:  state.CurrentTime,
+		Duration:   duration,
+		ToolCalls:  state.ToolCalls,
+		Incomplete: state.Interruption != nil,
+		Revisions:  state.Revisions,
+		Transcript: state.Transcript,
+	}
+	seen := make(map[Source]bool)
+	for _, source := range state.Sources {
+		key := Source{Tool: source.Tool, Input: source.Input}
+		if !seen[key] {
+			seen[key] = true
+			result.Metadata.Sources = append(result.Metadata.Sources, report.Source{Tool: source.Tool, Input: source.Input})
+		}
+	}
+	for _, check := range state.Verification {
+		result.Metadata.Claims = append(result.Metadata.Claims, report.Claim{
+			Claim:       check.Claim,
+			Verdict:     check.Verdict,
+			Explanation: check.Explanation,
+			Sources:     check.Sources,
+		})
+	}
+	if result.Metadata.Style == "" {
+		result.Metadata.Style = ReportStyleDefault
+	}
+	// a direct answer of the coordinator has no plan, even if an earlier
+	// question of the session had one
+	if plan := state.CurrentPlan; plan != nil && state.Report != nil {
+		if result.Title == "" {
+			result.Title = plan.Title
+		}
+		result.Metadata.Plan = &report.Plan{Title: plan.Title, Thought: plan.Thought}
+		for _, step := range plan.Steps {
+			result.Metadata.Plan.Steps = append(result.Metadata.Plan.Steps, report.Step{
+				Title:       step.Title,
+				Description: step.Description,
+				Type:        step.StepType,
+				Result:      step.ExecutionResult,
+			})
+		}
+	}
+}
+
+// partialResult lays out the findings of the executed steps, for when even
+// the reporter fails.
+func partialResult(state *AgentState) *report.Report {
+	result := &report.Report{Content: report.Content{Title: state.CurrentPlan.Title + " (partial results)"}}
+	for _, step := range state.CurrentPlan.Steps {
+		if step.ExecutionResult != "" {
+			result.Sections = append(result.Sections, report.Section{Heading: step.Title, Body: step.ExecutionResult})
 		}
 	}
+	markIncomplete(state, result)
+	return result
 }
@@ -0,0 +1,68 @@
+package agent
+
+import (
+	"testing"
+	"time"
+
+	"github.com/tmc/langchaingo/llms"
+)
+
+// newTestState returns the state of a first question whose plan has steps.
+func newTestState(steps ...Step) *AgentState {
+	state := &AgentState{
+		Locale:      "en-US",
+		Turn:        1,
+		Query:       "What is new in solid-state batteries?",
+		CurrentTime: time.Date(2025, 6, 1, 12, 0, 0, 0, time.UTC),
+		Messages: []llms.MessageContent{{
+			Role:  llms.ChatMessageTypeHuman,
+			Parts: []llms.ContentPart{llms.TextContent{Text: "What is new in solid-state batteries?"}},
+		}},
+	}
+	if steps != nil {
+		state.CurrentPlan = &Plan{Title: "Solid-state batteries", Steps: steps}
+	}
+	return state
+}
+
+func researchStep(title string) Step {
+	return Step{NeedSearch: true, Title: title, Description: "Find " + title + ".", StepType: StepTypeReasearch}
+}
+
+func processingStep(title string) Step {
+	return Step{Title: title, Description: "Compute " + title + ".", StepType: StepTypeProcessing}
+}
+
+// textOf returns the text of a message part, or "" if it holds none.
+func textOf(part llms.ContentPart) string {
+	if text, ok := part.(llms.TextContent); ok {
+		return text.Text
+	}
+	return ""
+}
+
+func toolCall(name string, arguments string) llms.ToolCall {
+	return llms.ToolCall{Type: "function", FunctionCall: &llms.FunctionCall{Name: name, Arguments: arguments}}
+}
+
+// checkToolCallOrder fails t unless every tool response in messages follows
+// the AI message requesting its tool call, as OpenAI-compatible APIs require.
+func checkToolCallOrder(t *testing.T, messages []llms.MessageContent) {
+	t.Helper()
+	requested := make(map[string]bool)
+	for i, message := range messages {
+		for _, part := range message.Parts {
+			switch part := part.(type) {
+			case llms.ToolCall:
+				if message.Role != llms.ChatMessageTypeAI {
+					t.Errorf("message %d: tool call %q in a %s message", i, part.ID, message.Role)
+				}
+				requested[part.ID] = true
+			case llms.ToolCallResponse:
+				if !requested[part.ToolCallID] {
+					t.Errorf("message %d: response to tool call %q before the call", i, part.ToolCallID)
+				}
+			}
+		}
+	}
+}
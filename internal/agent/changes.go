@@ -0,0 +1,143 @@
+package agent
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"strings"
+	"time"
+
+	"github.com/rickif/tiny-research/internal/llm"
+	promptfiles "github.com/rickif/tiny-research/internal/prompts"
+	"github.com/rickif/tiny-research/internal/report"
+	"github.com/rickif/tiny-research/internal/tool"
+	"github.com/tmc/langchaingo/llms"
+	"github.com/tmc/langchaingo/prompts"
+)
+
+// ChangesHeading is the heading of the section returned by Changes.
+const ChangesHeading = "What Changed"
+
+type changeSet struct {
+	Changed bool     `json:"changed"`
+	Changes []string `json:"changes"`
+}
+
+// Changes compares the report of a run with the report of a previous run of
+// the same query, and returns a "What Changed" section listing the changes
+// in the findings along with the sources gained and lost.
+func (wf *Agent) Changes(ctx context.Context, previous *report.Report, current *report.Report) (report.Section, error) {
+	content, err := promptfiles.ReadFile("changes.md")
+	if err != nil {
+		slog.Error("read changes prompt file", "error", err)
+		return report.Section{}, err
+	}
+	promptTemplate, err := prompts.NewPromptTemplate(string(content), []string{"current_time"}).Format(map[string]any{
+		"current_time": wf.now().Format(time.RFC3339),
+	})
+	if err != nil {
+		slog.Error("format changes prompt", "error", err)
+		return report.Section{}, err
+	}
+
+	var changes changeSet
+	_, err = llm.GenerateJSON(ctx, wf.llm, []llms.MessageContent{
+		{
+			Role:  llms.ChatMessageTypeSystem,
+			Parts: []llms.ContentPart{llms.TextContent{Text: promptTemplate}},
+		},
+		{
+			Role: llms.ChatMessageTypeHuman,
+			Parts: []llms.ContentPart{llms.TextContent{Text: fmt.Sprintf("# Previous Report (%s)\n\n%s\n\n# Current Report (%s)\n\n%s",
+				previous.Metadata.CreatedAt.Format(time.DateOnly), findings(previous),
+				current.Metadata.CreatedAt.Format(time.DateOnly), findings(current))}},
+		},
+	}, &changes, 3, wf.config.LLMStructuredOutput)
+	if err != nil {
+		slog.Error("compare reports", "error", err)
+		return report.Section{}, err
+	}
+
+	var b strings.Builder
+	if !changes.Changed || len(changes.Changes) == 0 {
+		fmt.Fprintf(&b, "No material changes since the previous run on %s.\n", previous.Metadata.CreatedAt.Format(time.DateOnly))
+	} else {
+		fmt.Fprintf(&b, "Since the previous run on %s:\n\n", previous.Metadata.CreatedAt.Format(time.DateOnly))
+		for _, change := range changes.Changes {
+			fmt.Fprintf(&b, "- %s\n", change)
+		}
+	}
+	added, removed := diffSources(sourceSet(previous), sourceSet(current))
+	if len(added) > 0 {
+		b.WriteString("\n**New sources**\n\n")
+		for _, source := range added {
+			fmt.Fprintf(&b, "- %s\n", source)
+		}
+	}
+	if len(removed) > 0 {
+		b.WriteString("\n**Sources no longer used**\n\n")
+		for _, source := range removed {
+			fmt.Fprintf(&b, "- %s\n", source)
+		}
+	}
+	slog.Info("compare reports", "changes", len(changes.Changes), "new_sources", len(added), "removed_sources", len(removed))
+	return report.Section{Heading: ChangesHeading, Body: b.String()}, nil
+}
+
+// findings lays out what a report says along with the plan findings it was
+// written from. The changes section of a report is left out, so that changes
+// are not reported twice.
+func findings(r *report.Report) string {
+	content := *r
+	content.Sections = nil
+	for _, section := range r.Sections {
+		if section.Heading != ChangesHeading {
+			content.Sections = append(content.Sections, section)
+		}
+	}
+	var b strings.Builder
+	b.WriteString(content.Markdown())
+	if r.Metadata.Plan != nil {
+		for _, step := range r.Metadata.Plan.Steps {
+			if step.Result != "" {
+				fmt.Fprintf(&b, "\n## Finding: %s\n\n%s\n", step.Title, step.Result)
+			}
+		}
+	}
+	return b.String()
+}
+
+// sourceSet returns the cited URLs and crawled pages of a report, in order.
+func sourceSet(r *report.Report) []string {
+	var sources []string
+	for _, citation := range r.Citations {
+		sources = append(sources, citation.URL)
+	}
+	for _, source := range r.Metadata.Sources {
+		if source.Tool == tool.CrawlTool.Function.Name {
+			sources = append(sources, source.Input)
+		}
+	}
+	return sources
+}
+
+func diffSources(previous []string, current []string) (added []string, removed []string) {
+	before := make(map[string]bool, len(previous))
+	for _, source := range previous {
+		before[source] = true
+	}
+	after := make(map[string]bool, len(current))
+	for _, source := range current {
+		if !after[source] && !before[source] {
+			added = append(added, source)
+		}
+		after[source] = true
+	}
+	for _, source := range previous {
+		if !after[source] {
+			removed = append(removed, source)
+			after[source] = true
+		}
+	}
+	return added, removed
+}
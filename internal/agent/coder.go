@@ -5,31 +5,32 @@ import (
 	"encoding/json"
 	"fmt"
 	"log/slog"
-	"os"
 	"strings"
 	"time"
 
+	promptfiles "github.com/rickif/tiny-research/internal/prompts"
 	"github.com/rickif/tiny-research/internal/tool"
 	"github.com/tmc/langchaingo/llms"
-	"github.com/tmc/langchaingo/llms/openai"
 	"github.com/tmc/langchaingo/prompts"
 )
 
 var _ Node = (*Coder)(nil)
 
 type Coder struct {
-	llm *openai.LLM
+	llm    llms.Model
+	python tool.PythonRunner
 }
 
-func NewCoder(llm *openai.LLM) *Coder {
+func NewCoder(llm llms.Model, python tool.PythonRunner) *Coder {
 	return &Coder{
-		llm: llm,
+		llm:    llm,
+		python: python,
 	}
 }
 
 func (r *Coder) Execute(ctx context.Context, state *AgentState) (nextStep string, output string, err error) {
 	slog.Info("coder starts")
-	content, err := os.ReadFile("./internal/prompts/coder.md")
+	content, err := promptfiles.ReadFile("coder.md")
 	if err != nil {
 		slog.Error("read prompt template", "error", err)
 		return "", "", err
@@ -39,7 +40,7 @@ func (r *Coder) Execute(ctx context.Context, state *AgentState) (nextStep string
 		string(content),
 		[]string{"current_time"},
 	).Format(map[string]any{
-		"current_time": time.Now().Format(time.RFC3339),
+		"current_time": state.CurrentTime.Format(time.RFC3339),
 		"locale":       state.Locale,
 	})
 	if err != nil {
@@ -83,8 +84,20 @@ func (r *Coder) Execute(ctx context.Context, state *AgentState) (nextStep string
 			slog.Error("generate content", "error", err)
 			return "", "", err
 		}
+		// the tool responses must follow the message requesting them
+		if len(resp.Choices[0].ToolCalls) > 0 {
+			messages = append(messages, llms.MessageContent{
+				Role:  llms.ChatMessageTypeAI,
+				Parts: toolCallParts(resp.Choices[0].ToolCalls),
+			})
+		}
 
 		for _, toolcall := range resp.Choices[0].ToolCalls {
+			if state.MaxToolCalls > 0 && state.ToolCalls >= state.MaxToolCalls {
+				slog.Error("tool call budget exhausted", "max_tool_calls", state.MaxToolCalls)
+				return "", "", fmt.Errorf("%w: %d tool calls", ErrBudgetExhausted, state.MaxToolCalls)
+			}
+			state.ToolCalls++
 			switch toolcall.FunctionCall.Name {
 			case "python-executor":
 				var args struct {
@@ -94,7 +107,7 @@ func (r *Coder) Execute(ctx context.Context, state *AgentState) (nextStep string
 					slog.Error("unmarshal arguments", "error", err)
 					return "", "", err
 				}
-				output, err = tool.Python(ctx, args.Code)
+				output, err = r.python.Run(ctx, args.Code)
 				if err != nil {
 					slog.Error("python", "error", err)
 					return "", "", err
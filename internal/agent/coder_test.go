@@ -0,0 +1,93 @@
+package agent
+
+import (
+	"context"
+	"errors"
+	"slices"
+	"testing"
+
+	"github.com/rickif/tiny-research/internal/fake"
+)
+
+func TestCoderExecute(t *testing.T) {
+	errPython := errors.New("python crashed")
+	tests := []struct {
+		name         string
+		responses    []fake.Response
+		pythonErr    error
+		maxToolCalls int
+		toolCalls    int
+		wantErr      error
+		wantAnyErr   bool
+		wantRuns     []string
+	}{
+		{
+			name: "runs code then answers",
+			responses: []fake.Response{
+				fake.ToolCall("python-executor", `{"code":"print(400/250)"}`),
+				fake.Text("Solid-state cells hold 1.6 times the energy."),
+			},
+			wantRuns: []string{"print(400/250)"},
+		},
+		{
+			name:         "stops when the budget is spent",
+			responses:    []fake.Response{fake.ToolCall("python-executor", `{"code":"print(1)"}`)},
+			maxToolCalls: 2,
+			toolCalls:    2,
+			wantErr:      ErrBudgetExhausted,
+		},
+		{
+			name:       "rejects malformed tool arguments",
+			responses:  []fake.Response{fake.ToolCall("python-executor", `{"code": print(1)`)},
+			wantAnyErr: true,
+		},
+		{
+			name:       "rejects an unknown tool",
+			responses:  []fake.Response{fake.ToolCall("tavily_search", `{"query":"a"}`)},
+			wantAnyErr: true,
+		},
+		{
+			name:      "fails with the interpreter",
+			responses: []fake.Response{fake.ToolCall("python-executor", `{"code":"print(1)"}`)},
+			pythonErr: errPython,
+			wantErr:   errPython,
+			wantRuns:  []string{"print(1)"},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			model := fake.NewModel(tt.responses...)
+			python := &fake.Tool{Default: "1.6", Err: tt.pythonErr}
+			state := newTestState(processingStep("Ratio"))
+			state.MaxToolCalls = tt.maxToolCalls
+			state.ToolCalls = tt.toolCalls
+
+			next, _, err := NewCoder(model, python).Execute(context.Background(), state)
+			for _, call := range model.Calls() {
+				checkToolCallOrder(t, call.Messages)
+			}
+			if got := python.Inputs(); !slices.Equal(got, tt.wantRuns) {
+				t.Errorf("code runs = %q, want %q", got, tt.wantRuns)
+			}
+			switch {
+			case tt.wantErr != nil || tt.wantAnyErr:
+				if err == nil || (tt.wantErr != nil && !errors.Is(err, tt.wantErr)) {
+					t.Fatalf("error = %v, want %v", err, tt.wantErr)
+				}
+				return
+			case err != nil:
+				t.Fatal(err)
+			}
+
+			if next != StepResearchTeam {
+				t.Errorf("next = %q, want %q", next, StepResearchTeam)
+			}
+			if got := state.CurrentPlan.Steps[0].ExecutionResult; got != "Solid-state cells hold 1.6 times the energy." {
+				t.Errorf("step findings = %q", got)
+			}
+			if state.ToolCalls != len(tt.wantRuns) {
+				t.Errorf("tool calls = %d, want %d", state.ToolCalls, len(tt.wantRuns))
+			}
+		})
+	}
+}
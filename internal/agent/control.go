@@ -0,0 +1,129 @@
+package agent
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log/slog"
+	"slices"
+	"sync"
+
+	"github.com/go-playground/validator/v10"
+	"github.com/tmc/langchaingo/llms"
+)
+
+// errStepSkipped is the cause of the context of a step skipped by a Control.
+var errStepSkipped = errors.New("step skipped")
+
+// skippedResult is the finding of a skipped step.
+const skippedResult = "This step was skipped by the user; it has no findings."
+
+// Control steers a run while it executes, e.g. from a terminal UI: it skips
+// the plan step being executed, and replaces the steps not executed yet.
+// Pass it to a run with WithControl.
+type Control struct {
+	mu sync.Mutex
+	// skip cancels the researcher or coder executing a step, if any
+	skip context.CancelCauseFunc
+	// steps replace the pending steps of the plan before the next step starts
+	steps []Step
+}
+
+func NewControl() *Control {
+	return &Control{}
+}
+
+// WithControl lets control steer the run.
+func WithControl(control *Control) ResearchOption {
+	return func(state *AgentState) {
+		state.Control = control
+	}
+}
+
+// SkipStep ends the plan step being executed without findings and moves on
+// to the next one. It reports whether a step was being executed.
+func (c *Control) SkipStep() bool {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.skip == nil {
+		return false
+	}
+	c.skip(errStepSkipped)
+	c.skip = nil
+	return true
+}
+
+// EditSteps replaces the plan steps not executed yet with steps, before the
+// next step starts. Steps executed in the meantime are left out of steps by
+// title. Without pending steps, the planner decides whether to plan more.
+func (c *Control) EditSteps(steps []Step) error {
+	steps = append([]Step{}, steps...)
+	validate := validator.New()
+	for i := range steps {
+		steps[i].ExecutionResult, steps[i].Skipped = "", false
+		if err := validate.Struct(steps[i]); err != nil {
+			return fmt.Errorf("step %d: %w", i+1, err)
+		}
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.steps = steps
+	return nil
+}
+
+// executing registers the cancel function of the node executing a step,
+// and returns the function to call once it returns.
+func (c *Control) executing(skip context.CancelCauseFunc) (done func()) {
+	c.mu.Lock()
+	c.skip = skip
+	c.mu.Unlock()
+	return func() {
+		c.mu.Lock()
+		c.skip = nil
+		c.mu.Unlock()
+	}
+}
+
+// applyEdit replaces the pending steps of the plan of state with the ones
+// of the last EditSteps call, if any.
+func (c *Control) applyEdit(state *AgentState) {
+	c.mu.Lock()
+	steps := c.steps
+	c.steps = nil
+	c.mu.Unlock()
+	if steps == nil || state.CurrentPlan == nil {
+		return
+	}
+
+	plan := state.CurrentPlan
+	executed := slices.DeleteFunc(slices.Clone(plan.Steps), func(step Step) bool {
+		return step.ExecutionResult == ""
+	})
+	steps = slices.DeleteFunc(steps, func(step Step) bool {
+		return slices.ContainsFunc(executed, func(e Step) bool { return e.Title == step.Title })
+	})
+	plan.Steps = append(executed, steps...)
+
+	// the planner revises the edited plan rather than the one it wrote
+	b, _ := json.Marshal(plan)
+	state.Messages = append(state.Messages, llms.MessageContent{
+		Role:  llms.ChatMessageTypeHuman,
+		Parts: []llms.ContentPart{llms.TextContent{Text: "I edited the plan, follow this one instead:\n\n" + string(b)}},
+	})
+	slog.Info("plan edited", "steps", len(plan.Steps))
+}
+
+// skipStep marks the first pending step of the plan of state as skipped.
+func skipStep(state *AgentState) string {
+	for i := range state.CurrentPlan.Steps {
+		step := &state.CurrentPlan.Steps[i]
+		if step.ExecutionResult == "" {
+			step.ExecutionResult = skippedResult
+			step.Skipped = true
+			slog.Info("step skipped", "title", step.Title)
+			break
+		}
+	}
+	return skippedResult
+}
@@ -4,11 +4,10 @@ import (
 	"context"
 	"fmt"
 	"log/slog"
-	"os"
 	"time"
 
+	promptfiles "github.com/rickif/tiny-research/internal/prompts"
 	"github.com/tmc/langchaingo/llms"
-	"github.com/tmc/langchaingo/llms/openai"
 	"github.com/tmc/langchaingo/prompts"
 )
 
@@ -21,13 +20,24 @@ var coordinatorTool = llms.Tool{
 	},
 }
 
+// answerTool is offered for follow-up questions, once earlier turns of the
+// session have findings to answer from.
+var answerTool = llms.Tool{
+	Type: "function",
+	Function: &llms.FunctionDefinition{
+		Name:        "answer_from_context",
+		Description: "Handoff to reporter agent to answer a follow-up question from the findings of the previous research, without new research",
+		Parameters:  map[string]any{},
+	},
+}
+
 var _ Node = (*Coordinator)(nil)
 
 type Coordinator struct {
-	llm *openai.LLM
+	llm llms.Model
 }
 
-func NewCoordinator(llm *openai.LLM) *Coordinator {
+func NewCoordinator(llm llms.Model) *Coordinator {
 	return &Coordinator{
 		llm: llm,
 	}
@@ -35,13 +45,13 @@ func NewCoordinator(llm *openai.LLM) *Coordinator {
 
 func (coord *Coordinator) Execute(ctx context.Context, state *AgentState) (nextStep string, output string, err error) {
 	// load prompts
-	content, err := os.ReadFile("./internal/prompts/coordinator.md")
+	content, err := promptfiles.ReadFile("coordinator.md")
 	if err != nil {
 		slog.Info("read coordinator prompt file", "error", err)
 		return "", "", err
 	}
 	promptTemplate, err := prompts.NewPromptTemplate(string(content), []string{"current_time", "locale"}).Format(map[string]any{
-		"current_time": time.Now().Format(time.RFC3339),
+		"current_time": state.CurrentTime.Format(time.RFC3339),
 		"locale":       state.Locale,
 	})
 	if err != nil {
@@ -56,7 +66,11 @@ func (coord *Coordinator) Execute(ctx context.Context, state *AgentState) (nextS
 
 	messages = append(messages, state.Messages...)
 
-	resp, err := coord.llm.GenerateContent(context.Background(), messages, llms.WithTools([]llms.Tool{coordinatorTool}))
+	tools := []llms.Tool{coordinatorTool}
+	if state.Turn > 1 && len(state.executedSteps()) > 0 {
+		tools = append(tools, answerTool)
+	}
+	resp, err := coord.llm.GenerateContent(ctx, messages, llms.WithTools(tools))
 	if err != nil {
 		return "", "", err
 	}
@@ -65,6 +79,12 @@ func (coord *Coordinator) Execute(ctx context.Context, state *AgentState) (nextS
 		return "", "", fmt.Errorf("empty response")
 	}
 
+	for _, toolcall := range resp.Choices[0].ToolCalls {
+		if toolcall.FunctionCall != nil && toolcall.FunctionCall.Name == answerTool.Function.Name {
+			slog.Info("coordinator answers from context")
+			return StepReporter, "", nil
+		}
+	}
 	if len(resp.Choices[0].ToolCalls) > 0 {
 		return StepPlanner, "", nil
 	}
@@ -0,0 +1,94 @@
+package agent
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/rickif/tiny-research/internal/config"
+	"github.com/rickif/tiny-research/internal/fake"
+)
+
+func TestCoordinatorExecute(t *testing.T) {
+	errModel := errors.New("model unavailable")
+	tests := []struct {
+		name     string
+		response fake.Response
+		followUp bool
+		wantNext string
+		wantOut  string
+		wantErr  error
+	}{
+		{
+			name:     "hands off to the planner",
+			response: fake.ToolCall("handoff_to_planner", `{}`),
+			wantNext: StepPlanner,
+		},
+		{
+			name:     "answers small talk directly",
+			response: fake.Text("Hello! What would you like to research?"),
+			wantNext: StepEnd,
+			wantOut:  "Hello! What would you like to research?",
+		},
+		{
+			name:     "answers a follow-up from context",
+			response: fake.ToolCall("answer_from_context", `{}`),
+			followUp: true,
+			wantNext: StepReporter,
+		},
+		{
+			name:     "fails with the model",
+			response: fake.Error(errModel),
+			wantErr:  errModel,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			model := fake.NewModel(tt.response)
+			state := newTestState()
+			if tt.followUp {
+				state = newTestState(Step{Title: "Done", Description: "d", StepType: StepTypeReasearch, ExecutionResult: "findings"})
+				state.Turn = 2
+			}
+
+			next, output, err := NewCoordinator(model).Execute(context.Background(), state)
+			if !errors.Is(err, tt.wantErr) {
+				t.Fatalf("error = %v, want %v", err, tt.wantErr)
+			}
+			if next != tt.wantNext || output != tt.wantOut {
+				t.Errorf("Execute = (%q, %q), want (%q, %q)", next, output, tt.wantNext, tt.wantOut)
+			}
+		})
+	}
+}
+
+func TestCoordinatorOffersAnswerToolOnFollowUpOnly(t *testing.T) {
+	for turn, want := range map[int]int{1: 1, 2: 2} {
+		model := fake.NewModel(fake.ToolCall("handoff_to_planner", `{}`))
+		state := newTestState(Step{Title: "Done", Description: "d", StepType: StepTypeReasearch, ExecutionResult: "findings"})
+		state.Turn = turn
+		if _, _, err := NewCoordinator(model).Execute(context.Background(), state); err != nil {
+			t.Fatal(err)
+		}
+		if got := len(model.Calls()[0].Options.Tools); got != want {
+			t.Errorf("turn %d: %d tools offered, want %d", turn, got, want)
+		}
+	}
+}
+
+func TestAskFailsOnEmptyAnswer(t *testing.T) {
+	agent, err := NewAgent(config.Config{NoCache: true, LLMModel: "fake-model", LLMToken: "test"},
+		WithModel(fake.NewModel(fake.Text(""))), WithSearcher(&fake.Tool{}), WithCrawler(&fake.Tool{}), WithPython(&fake.Tool{}))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer agent.Close()
+
+	result, err := agent.Research(context.Background(), "Hi")
+	if !errors.Is(err, ErrEmptyAnswer) {
+		t.Errorf("error = %v, want %v", err, ErrEmptyAnswer)
+	}
+	if result != nil {
+		t.Errorf("result = %+v, want none", result)
+	}
+}
@@ -0,0 +1,127 @@
+package agent
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"strings"
+	"time"
+
+	"github.com/rickif/tiny-research/internal/llm"
+	promptfiles "github.com/rickif/tiny-research/internal/prompts"
+	"github.com/tmc/langchaingo/llms"
+	"github.com/tmc/langchaingo/prompts"
+)
+
+var _ Node = (*Critic)(nil)
+
+// Critic reviews the draft report and either approves it, sends follow-up
+// steps back to the research team, or asks the reporter for a revision.
+type Critic struct {
+	llm          llms.Model
+	maxRevisions int
+	maxStepNum   int
+	strictJSON   bool
+}
+
+// NewCritic creates a critic node allowing at most maxRevisions revisions of
+// the report, each with at most maxStepNum follow-up steps.
+func NewCritic(llm llms.Model, maxRevisions int, maxStepNum int, strictJSON bool) *Critic {
+	return &Critic{
+		llm:          llm,
+		maxRevisions: maxRevisions,
+		maxStepNum:   maxStepNum,
+		strictJSON:   strictJSON,
+	}
+}
+
+func (critic *Critic) Execute(ctx context.Context, state *AgentState) (nextStep string, output string, err error) {
+	slog.Info("critic starts")
+	draft := state.Report.Markdown()
+	if state.Revisions >= critic.maxRevisions {
+		slog.Info("revisions reach max revisions", "revisions", state.Revisions)
+		state.Reviewed = true
+		return StepEnd, draft, nil
+	}
+
+	content, err := promptfiles.ReadFile("critic.md")
+	if err != nil {
+		slog.Error("read critic prompt file", "error", err)
+		return "", "", err
+	}
+	promptTemplate, err := prompts.NewPromptTemplate(string(content), []string{"current_time", "max_step_num"}).Format(map[string]any{
+		"current_time": state.CurrentTime.Format(time.RFC3339),
+		"max_step_num": critic.maxStepNum,
+	})
+	if err != nil {
+		slog.Error("format critic prompt", "error", err)
+		return "", "", err
+	}
+
+	var b strings.Builder
+	fmt.Fprintf(&b, "# Question\n\n%s\n\n# Plan\n\n## %s\n\n%s\n", state.Query, state.CurrentPlan.Title, state.CurrentPlan.Thought)
+	b.WriteString("\n# Findings\n")
+	for _, step := range state.executedSteps() {
+		fmt.Fprintf(&b, "\n## %s\n\n%s\n", step.Title, step.ExecutionResult)
+	}
+	if len(state.Verification) > 0 {
+		fmt.Fprintf(&b, "\n%s\n", verificationSummary(state.Verification))
+	}
+	fmt.Fprintf(&b, "\n# Draft\n\n%s", draft)
+
+	var critique Critique
+	_, err = llm.GenerateJSON(ctx, critic.llm, []llms.MessageContent{
+		{
+			Role:  llms.ChatMessageTypeSystem,
+			Parts: []llms.ContentPart{llms.TextContent{Text: promptTemplate}},
+		},
+		{
+			Role:  llms.ChatMessageTypeHuman,
+			Parts: []llms.ContentPart{llms.TextContent{Text: b.String()}},
+		},
+	}, &critique, 3, critic.strictJSON)
+	if err != nil {
+		slog.Error("generate critique", "error", err)
+		return "", "", err
+	}
+
+	if critique.Approved || (len(critique.Issues) == 0 && len(critique.FollowUpSteps) == 0) {
+		slog.Info("critic approves report", "revisions", state.Revisions)
+		state.Reviewed = true
+		return StepEnd, draft, nil
+	}
+
+	state.Critique = &critique
+	state.Revisions++
+	for _, issue := range critique.Issues {
+		slog.Info("critic issue", "issue", issue)
+	}
+	if len(critique.FollowUpSteps) > critic.maxStepNum {
+		critique.FollowUpSteps = critique.FollowUpSteps[:critic.maxStepNum]
+	}
+	if len(critique.FollowUpSteps) == 0 {
+		slog.Info("critic requests revision", "revision", state.Revisions)
+		return StepReporter, draft, nil
+	}
+
+	// the follow-up findings are new, so they are verified again
+	state.CurrentPlan.Steps = append(state.CurrentPlan.Steps, critique.FollowUpSteps...)
+	state.CurrentPlan.HasEnoughContext = false
+	state.Verified = false
+	for i, step := range critique.FollowUpSteps {
+		slog.Info("critic follow-up step", "id", i+1, "title", step.Title, "type", step.StepType, "description", step.Description)
+	}
+	slog.Info("critic requests follow-up research", "revision", state.Revisions, "steps", len(critique.FollowUpSteps))
+	return StepResearchTeam, draft, nil
+}
+
+// revisionRequest shows the reporter its previous draft and what to fix.
+func revisionRequest(previous string, critique *Critique) string {
+	var b strings.Builder
+	b.WriteString("# Revision Request\n\nA reviewer found problems in your previous draft. Write the report again, fixing every issue below and using any new findings. Keep what was right.\n\n## Issues\n")
+	for _, issue := range critique.Issues {
+		fmt.Fprintf(&b, "\n- %s", issue)
+	}
+	fmt.Fprintf(&b, "\n\n## Previous Draft\n\n%s", previous)
+	return b.String()
+}
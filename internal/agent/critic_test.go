@@ -0,0 +1,139 @@
+package agent
+
+import (
+	"context"
+	"testing"
+
+	"github.com/rickif/tiny-research/internal/fake"
+	"github.com/rickif/tiny-research/internal/report"
+)
+
+func TestCriticExecute(t *testing.T) {
+	tests := []struct {
+		name          string
+		responses     []fake.Response
+		revisions     int
+		wantNext      string
+		wantCalls     int
+		wantSteps     int
+		wantRevisions int
+		wantReviewed  bool
+		wantErr       bool
+	}{
+		{
+			name:         "approves the draft",
+			responses:    []fake.Response{fake.Text(`{"approved":true,"issues":[],"follow_up_steps":[]}`)},
+			wantNext:     StepEnd,
+			wantCalls:    1,
+			wantSteps:    1,
+			wantReviewed: true,
+		},
+		{
+			name:          "requests a revision from the findings",
+			responses:     []fake.Response{fake.Text(`{"approved":false,"issues":["The summary overstates the density."],"follow_up_steps":[]}`)},
+			wantNext:      StepReporter,
+			wantCalls:     1,
+			wantSteps:     1,
+			wantRevisions: 1,
+		},
+		{
+			name: "sends follow-up steps to the research team",
+			responses: []fake.Response{fake.Text(`{"approved":false,"issues":["The cost is missing."],"follow_up_steps":[` +
+				`{"need_search":true,"title":"Cost","description":"Find the cost per kWh.","step_type":"research"},` +
+				`{"need_search":true,"title":"Lifetime","description":"Find the cycle life.","step_type":"research"},` +
+				`{"need_search":true,"title":"Supply","description":"Find the suppliers.","step_type":"research"}]}`)},
+			wantNext:      StepResearchTeam,
+			wantCalls:     1,
+			wantSteps:     3, // capped at two follow-up steps
+			wantRevisions: 1,
+		},
+		{
+			name:          "stops after the last revision",
+			revisions:     2,
+			wantNext:      StepEnd,
+			wantSteps:     1,
+			wantRevisions: 2,
+			wantReviewed:  true,
+		},
+		{
+			name:         "retries a malformed critique",
+			responses:    []fake.Response{fake.Text(`{"approved": }`), fake.Text(`{"approved":true,"issues":[],"follow_up_steps":[]}`)},
+			wantNext:     StepEnd,
+			wantCalls:    2,
+			wantSteps:    1,
+			wantReviewed: true,
+		},
+		{
+			name:      "fails after three malformed critiques",
+			responses: []fake.Response{fake.Text("looks good"), fake.Text("still good"), fake.Text(`{"approved": }`)},
+			wantCalls: 3,
+			wantErr:   true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			step := researchStep("Density")
+			step.ExecutionResult = "Solid-state cells reach about 400 Wh/kg."
+			state := newTestState(step)
+			state.Report = &report.Report{Content: report.Content{Title: "Batteries", Sections: []report.Section{{Heading: "Density", Body: "400 Wh/kg."}}}}
+			state.Revisions = tt.revisions
+			state.Verified = true
+			model := fake.NewModel(tt.responses...)
+
+			next, _, err := NewCritic(model, 2, 2, false).Execute(context.Background(), state)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("error = %v, want error %v", err, tt.wantErr)
+			}
+			if got := len(model.Calls()); got != tt.wantCalls {
+				t.Errorf("%d model calls, want %d", got, tt.wantCalls)
+			}
+			if tt.wantErr {
+				return
+			}
+			if next != tt.wantNext {
+				t.Errorf("next = %q, want %q", next, tt.wantNext)
+			}
+			if got := len(state.CurrentPlan.Steps); got != tt.wantSteps {
+				t.Errorf("%d plan steps, want %d", got, tt.wantSteps)
+			}
+			if state.Revisions != tt.wantRevisions || state.Reviewed != tt.wantReviewed {
+				t.Errorf("revisions = %d, reviewed = %v; want %d, %v", state.Revisions, state.Reviewed, tt.wantRevisions, tt.wantReviewed)
+			}
+			if next == StepResearchTeam && state.Verified {
+				t.Error("follow-up findings are not verified again")
+			}
+		})
+	}
+}
+
+// TestCriticFollowUpKeepsThePlan runs the follow-up steps of the critic
+// through the graph: the research team hands them to the reporter rather
+// than the planner, so the reviewed plan and its findings are kept.
+func TestCriticFollowUpKeepsThePlan(t *testing.T) {
+	step := researchStep("Density")
+	step.ExecutionResult = "Solid-state cells reach about 400 Wh/kg."
+	state := newTestState(step)
+	state.Report = &report.Report{Content: report.Content{Title: "Batteries"}}
+	model := fake.NewModel(
+		fake.Text(`{"approved":false,"issues":["The cost is missing."],"follow_up_steps":[{"need_search":true,"title":"Cost","description":"Find the cost per kWh.","step_type":"research"}]}`),
+		fake.Text("Cells cost about 800 USD/kWh."),
+	)
+	ctx := context.Background()
+
+	next, _, err := NewCritic(model, 2, 2, false).Execute(ctx, state)
+	if err != nil || next != StepResearchTeam {
+		t.Fatalf("critic = (%q, %v), want %q", next, err, StepResearchTeam)
+	}
+	if next, _, _ = NewResearchTeam(model).Execute(ctx, state); next != StepResearcher {
+		t.Fatalf("research team = %q, want %q", next, StepResearcher)
+	}
+	if _, _, err := NewResearcher(model, &fake.Tool{}, &fake.Tool{}, nil, nil).Execute(ctx, state); err != nil {
+		t.Fatal(err)
+	}
+	if next, _, _ = NewResearchTeam(model).Execute(ctx, state); next != StepReporter {
+		t.Errorf("after the follow-up steps, research team = %q, want %q", next, StepReporter)
+	}
+	if got := len(state.executedSteps()); got != 2 {
+		t.Errorf("%d executed steps, want both the reviewed and the follow-up step", got)
+	}
+}
@@ -0,0 +1,67 @@
+package agent
+
+import (
+	"context"
+	"sync"
+
+	"github.com/google/uuid"
+	"github.com/rickif/tiny-research/internal/history"
+	"github.com/rickif/tiny-research/internal/report"
+	"github.com/rickif/tiny-research/internal/transcript"
+)
+
+// History returns the store of past runs, or nil if the history is disabled.
+func (wf *Agent) History() *history.Store {
+	return wf.history
+}
+
+// saveRun stores the run of state that produced result in the history, along
+// with sources, those fetched during the run.
+func (wf *Agent) saveRun(ctx context.Context, state *AgentState, sources []Source, result *report.Report, usage *usage, err error) error {
+	run := &history.Run{
+		ID:     uuid.NewString(),
+		Status: runStatus(err),
+		Locale: state.Locale,
+		Report: result,
+	}
+	if err != nil {
+		run.Error = err.Error()
+	}
+	run.LLMCalls, run.InputTokens, run.OutputTokens = usage.totals()
+	seen := make(map[Source]bool)
+	for _, source := range sources {
+		key := Source{Tool: source.Tool, Input: source.Input}
+		if !seen[key] {
+			seen[key] = true
+			run.Sources = append(run.Sources, history.Source{Tool: source.Tool, Input: source.Input, Content: source.Content})
+		}
+	}
+	// a canceled run is saved with its partial report
+	return wf.history.Save(context.WithoutCancel(ctx), run)
+}
+
+// usage counts the LLM calls of a run that reached the model and their
+// tokens from its events.
+type usage struct {
+	mu           sync.Mutex
+	calls        int
+	inputTokens  int
+	outputTokens int
+}
+
+func (u *usage) Record(event transcript.Event) {
+	if event.Kind != transcript.KindLLMResponse || event.Cached {
+		return
+	}
+	u.mu.Lock()
+	defer u.mu.Unlock()
+	u.calls++
+	u.inputTokens += event.InputTokens
+	u.outputTokens += event.OutputTokens
+}
+
+func (u *usage) totals() (calls int, inputTokens int, outputTokens int) {
+	u.mu.Lock()
+	defer u.mu.Unlock()
+	return u.calls, u.inputTokens, u.outputTokens
+}
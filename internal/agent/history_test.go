@@ -0,0 +1,65 @@
+package agent
+
+import (
+	"context"
+	"path/filepath"
+	"slices"
+	"testing"
+
+	"github.com/rickif/tiny-research/internal/config"
+	"github.com/rickif/tiny-research/internal/fake"
+)
+
+// researchTurn scripts a turn searching for query.
+func researchTurn(query string) []fake.Response {
+	return []fake.Response{
+		fake.ToolCall("handoff_to_planner", `{}`),
+		fake.Text(validPlan),
+		fake.ToolCall("tavily_search", `{"query":"`+query+`"}`),
+		fake.Text("Findings."),
+		fake.Text(enoughPlan),
+		fake.Text(`{"title":"Batteries","key_points":[],"sections":[{"heading":"Density","body":"Findings.","tables":[]}],"citations":[]}`),
+	}
+}
+
+func TestSessionSavesTheSourcesOfEachTurn(t *testing.T) {
+	model := fake.NewModel(append(researchTurn("first"), researchTurn("second")...)...)
+	agent, err := NewAgent(config.Config{HistoryDB: filepath.Join(t.TempDir(), "history.db"), NoCache: true, LLMModel: "fake-model", LLMToken: "test"},
+		WithModel(model), WithSearcher(&fake.Tool{Default: "results"}), WithCrawler(&fake.Tool{}), WithPython(&fake.Tool{}))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer agent.Close()
+	session, err := agent.NewSession()
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, question := range []string{"What is new in batteries?", "And in solid-state ones?"} {
+		if _, err := session.Ask(context.Background(), question); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	ctx := context.Background()
+	summaries, err := agent.History().List(ctx, 0)
+	if err != nil || len(summaries) != 2 {
+		t.Fatalf("List = %d runs, %v; want 2", len(summaries), err)
+	}
+	// newest first
+	for i, want := range []string{"second", "first"} {
+		run, err := agent.History().Get(ctx, summaries[i].ID)
+		if err != nil {
+			t.Fatal(err)
+		}
+		var inputs []string
+		for _, source := range run.Sources {
+			inputs = append(inputs, source.Input)
+		}
+		if !slices.Equal(inputs, []string{want}) {
+			t.Errorf("run %d sources = %q, want [%s]", i, inputs, want)
+		}
+		if n := len(researchTurn(want)); run.LLMCalls != n {
+			t.Errorf("run %d LLM calls = %d, want %d", i, run.LLMCalls, n)
+		}
+	}
+}
@@ -2,29 +2,35 @@ package agent
 
 import (
 	"context"
+	"fmt"
 	"log/slog"
-	"os"
 	"time"
 
 	"github.com/rickif/tiny-research/internal/llm"
+	promptfiles "github.com/rickif/tiny-research/internal/prompts"
+	"github.com/rickif/tiny-research/internal/tool"
 	"github.com/tmc/langchaingo/llms"
-	"github.com/tmc/langchaingo/llms/openai"
 	"github.com/tmc/langchaingo/prompts"
 )
 
 var _ Node = (*Planner)(nil)
 
 type Planner struct {
-	llm           *openai.LLM
+	llm           llms.Model
 	maxIterations int
 	maxStepNum    int
+	strictJSON    bool
 }
 
-func NewPlanner(llm *openai.LLM, maxIterations int, maxStepNum int) *Planner {
+// NewPlanner creates a planner node. strictJSON enforces the plan schema
+// through the provider's strict structured output, which must be supported
+// by the model.
+func NewPlanner(llm llms.Model, maxIterations int, maxStepNum int, strictJSON bool) *Planner {
 	return &Planner{
 		llm:           llm,
 		maxIterations: maxIterations,
 		maxStepNum:    maxStepNum,
+		strictJSON:    strictJSON,
 	}
 }
 
@@ -35,13 +41,13 @@ func (planner *Planner) Execute(ctx context.Context, state *AgentState) (nextSte
 		return StepReporter, "", nil
 	}
 
-	content, err := os.ReadFile("./internal/prompts/planner.md")
+	content, err := promptfiles.ReadFile("planner.md")
 	if err != nil {
 		slog.Error("read planner prompt file", "error", err)
 		return "", "", err
 	}
 	promptTemplate, err := prompts.NewPromptTemplate(string(content), []string{"current_time", "max_step_num"}).Format(map[string]any{
-		"current_time": time.Now().Format(time.RFC3339),
+		"current_time": state.CurrentTime.Format(time.RFC3339),
 		"max_step_num": planner.maxStepNum,
 		"locale":       state.Locale,
 	})
@@ -58,8 +64,28 @@ func (planner *Planner) Execute(ctx context.Context, state *AgentState) (nextSte
 
 	messages = append(messages, state.Messages...)
 
+	if state.Turn > 1 {
+		messages = append(messages, llms.MessageContent{
+			Role:  llms.ChatMessageTypeSystem,
+			Parts: []llms.ContentPart{llms.TextContent{Text: fmt.Sprintf("IMPORTANT: The last message is a follow-up question in a conversation: %q. The plans and findings of the previous questions are above. Plan only the steps that gather information those findings do not already contain, and set `has_enough_context` to true if they contain everything needed.", state.Query)}},
+		})
+	}
+
+	if state.PlannerInstructions != "" {
+		messages = append(messages, llms.MessageContent{
+			Role:  llms.ChatMessageTypeSystem,
+			Parts: []llms.ContentPart{llms.TextContent{Text: "IMPORTANT: Follow these instructions when planning:\n\n" + state.PlannerInstructions}},
+		})
+	}
+	if !state.toolAllowed(tool.PythonTool.Function.Name) {
+		messages = append(messages, llms.MessageContent{
+			Role:  llms.ChatMessageTypeSystem,
+			Parts: []llms.ContentPart{llms.TextContent{Text: "IMPORTANT: Code execution is not available in this run. Plan `research` steps only, never `processing` steps."}},
+		})
+	}
+
 	var plan Plan
-	output, err = llm.GenerateJSON(ctx, planner.llm, messages, &plan, 3)
+	output, err = llm.GenerateJSON(ctx, planner.llm, messages, &plan, 3, planner.strictJSON)
 	if err != nil {
 		slog.Error("generate plan", "error", err)
 		return "", "", err
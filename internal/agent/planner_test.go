@@ -0,0 +1,137 @@
+package agent
+
+import (
+	"context"
+	"strings"
+	"testing"
+
+	"github.com/rickif/tiny-research/internal/fake"
+)
+
+const (
+	validPlan  = `{"has_enough_context":false,"thought":"t","title":"Batteries","steps":[{"need_search":true,"title":"Density","description":"Find the energy density.","step_type":"research"}]}`
+	enoughPlan = `{"has_enough_context":true,"thought":"t","title":"Batteries","steps":[]}`
+)
+
+func TestPlannerExecute(t *testing.T) {
+	tests := []struct {
+		name       string
+		responses  []fake.Response
+		iterations int
+		wantNext   string
+		wantSteps  int
+		wantCalls  int
+		wantErr    bool
+	}{
+		{
+			name:      "plans research steps",
+			responses: []fake.Response{fake.Text(validPlan)},
+			wantNext:  StepResearchTeam,
+			wantSteps: 1,
+			wantCalls: 1,
+		},
+		{
+			name:      "goes to the reporter with enough context",
+			responses: []fake.Response{fake.Text(enoughPlan)},
+			wantNext:  StepReporter,
+			wantCalls: 1,
+		},
+		{
+			name:      "repairs JSON wrapped in prose and a fence",
+			responses: []fake.Response{fake.Text("Here is the plan:\n```json\n" + validPlan + "\n```")},
+			wantNext:  StepResearchTeam,
+			wantSteps: 1,
+			wantCalls: 1,
+		},
+		{
+			name:      "retries after prose without JSON",
+			responses: []fake.Response{fake.Text("I need to think about the plan first."), fake.Text(validPlan)},
+			wantNext:  StepResearchTeam,
+			wantSteps: 1,
+			wantCalls: 2,
+		},
+		{
+			name:      "retries after JSON with a syntax error",
+			responses: []fake.Response{fake.Text(`{"title": "Batteries", "steps": [{"title": }]}`), fake.Text(validPlan)},
+			wantNext:  StepResearchTeam,
+			wantSteps: 1,
+			wantCalls: 2,
+		},
+		{
+			name:      "retries after a plan failing the schema",
+			responses: []fake.Response{fake.Text(`{"title":"Batteries","steps":[{"title":"Density","description":"d","step_type":"survey"}]}`), fake.Text(validPlan)},
+			wantNext:  StepResearchTeam,
+			wantSteps: 1,
+			wantCalls: 2,
+		},
+		{
+			name:      "fails after three malformed answers",
+			responses: []fake.Response{fake.Text("not json"), fake.Text("still not json"), fake.Text("{")},
+			wantCalls: 3,
+			wantErr:   true,
+		},
+		{
+			name:       "stops planning after the last iteration",
+			iterations: 2,
+			wantNext:   StepReporter,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			model := fake.NewModel(tt.responses...)
+			state := newTestState()
+			state.PlanIterations = tt.iterations
+
+			next, _, err := NewPlanner(model, 2, 3, false).Execute(context.Background(), state)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("error = %v, want error %v", err, tt.wantErr)
+			}
+			if got := len(model.Calls()); got != tt.wantCalls {
+				t.Errorf("%d model calls, want %d", got, tt.wantCalls)
+			}
+			if tt.wantErr {
+				return
+			}
+			if next != tt.wantNext {
+				t.Errorf("next = %q, want %q", next, tt.wantNext)
+			}
+			if tt.wantCalls == 0 {
+				return
+			}
+			if state.CurrentPlan == nil || len(state.CurrentPlan.Steps) != tt.wantSteps {
+				t.Errorf("plan = %+v, want %d steps", state.CurrentPlan, tt.wantSteps)
+			}
+			if state.PlanIterations != 1 {
+				t.Errorf("plan iterations = %d, want 1", state.PlanIterations)
+			}
+		})
+	}
+}
+
+func TestPlannerSendsRepairRequest(t *testing.T) {
+	model := fake.NewModel(fake.Text("not json"), fake.Text(validPlan))
+	if _, _, err := NewPlanner(model, 2, 3, false).Execute(context.Background(), newTestState()); err != nil {
+		t.Fatal(err)
+	}
+	retry := model.Calls()[1].Messages
+	last := retry[len(retry)-1].Parts[0]
+	if text := textOf(last); !strings.Contains(text, "could not be used") {
+		t.Errorf("retry ends with %q, want a repair request", text)
+	}
+}
+
+func TestPlannerForbidsProcessingStepsWithoutPython(t *testing.T) {
+	model := fake.NewModel(fake.Text(validPlan))
+	state := newTestState()
+	state.AllowedTools = []string{"tavily_search"}
+	if _, _, err := NewPlanner(model, 2, 3, false).Execute(context.Background(), state); err != nil {
+		t.Fatal(err)
+	}
+	found := false
+	for _, message := range model.Calls()[0].Messages {
+		found = found || strings.Contains(textOf(message.Parts[0]), "Code execution is not available")
+	}
+	if !found {
+		t.Error("planner was not told that code execution is unavailable")
+	}
+}
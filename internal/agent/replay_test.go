@@ -0,0 +1,117 @@
+package agent
+
+import (
+	"context"
+	"flag"
+	"path/filepath"
+	"sync"
+	"testing"
+
+	"github.com/rickif/tiny-research/internal/config"
+	"github.com/rickif/tiny-research/internal/fake"
+	"github.com/rickif/tiny-research/internal/transcript"
+)
+
+var update = flag.Bool("update", false, "record the cassettes of testdata again from the fake scripts")
+
+const replayQuery = "How do solid-state batteries compare with lithium-ion batteries?"
+
+// recordResearch records a research run against fakes to cassette, so the
+// cassette follows the prompts when they change.
+func recordResearch(t *testing.T, cassette string) {
+	t.Helper()
+	model := fake.NewModel(
+		fake.ToolCall("handoff_to_planner", `{}`),
+		fake.Text(`{"has_enough_context":false,"thought":"Compare the two battery chemistries.","title":"Solid-state vs lithium-ion batteries","steps":[{"need_search":true,"title":"Energy density and safety","description":"Collect energy density and safety figures of both chemistries.","step_type":"research"}]}`),
+		fake.ToolCall("tavily_search", `{"query":"solid-state battery energy density"}`),
+		fake.ToolCall("crawl", `{"url":"https://example.com/solid-state"}`),
+		fake.Text("Solid-state cells reach about 400 Wh/kg against 250 Wh/kg for lithium-ion, and do not use a flammable liquid electrolyte."),
+		fake.Text(`{"has_enough_context":true,"thought":"The findings cover the question.","title":"Solid-state vs lithium-ion batteries","steps":[]}`),
+		fake.Text(`{"title":"Solid-state vs lithium-ion batteries","key_points":["Solid-state cells store about 60% more energy per kilogram."],"sections":[{"heading":"Energy density and safety","body":"Solid-state cells reach about 400 Wh/kg, against 250 Wh/kg for lithium-ion [1].","tables":[]}],"citations":[{"id":1,"title":"Solid-state batteries","url":"https://example.com/solid-state"}]}`),
+	)
+	searcher := &fake.Tool{Default: `[{"title":"Solid-state batteries","url":"https://example.com/solid-state","content":"Solid-state cells reach about 400 Wh/kg."}]`}
+	crawler := &fake.Tool{Default: "Solid-state cells reach about 400 Wh/kg, lithium-ion cells about 250 Wh/kg."}
+
+	agent, err := NewAgent(config.Config{RecordPath: cassette, NoCache: true, LLMModel: "fake-model", LLMToken: "test"},
+		WithModel(model), WithSearcher(searcher), WithCrawler(crawler), WithPython(&fake.Tool{}))
+	if err != nil {
+		t.Fatalf("new agent: %v", err)
+	}
+	if _, err := agent.Research(context.Background(), replayQuery); err != nil {
+		t.Fatalf("record research: %v", err)
+	}
+	if err := agent.Close(); err != nil {
+		t.Fatalf("write cassette: %v", err)
+	}
+	if n := model.Remaining(); n != 0 {
+		t.Fatalf("%d scripted responses left unused", n)
+	}
+}
+
+// TestResearchReplay runs the whole graph offline from a recorded cassette;
+// run it with -update to record the cassette again.
+func TestResearchReplay(t *testing.T) {
+	cassette := filepath.Join("testdata", "research.cassette")
+	if *update {
+		recordResearch(t, cassette)
+	}
+
+	agent, err := NewAgent(config.Config{ReplayPath: cassette, NoCache: true, LLMModel: "fake-model"})
+	if err != nil {
+		t.Fatalf("new agent: %v", err)
+	}
+	defer agent.Close()
+
+	events := &eventLog{}
+	result, err := agent.Research(transcript.NewContext(context.Background(), events), replayQuery)
+	if err != nil {
+		t.Fatalf("research: %v", err)
+	}
+	if got, want := result.Title, "Solid-state vs lithium-ion batteries"; got != want {
+		t.Errorf("title = %q, want %q", got, want)
+	}
+	if len(result.Sections) != 1 || result.Sections[0].Heading != "Energy density and safety" {
+		t.Errorf("sections = %+v, want the one section of the cassette", result.Sections)
+	}
+	if got := result.Metadata.ToolCalls; got != 2 {
+		t.Errorf("tool calls = %d, want 2", got)
+	}
+	if got := len(result.Metadata.Sources); got != 2 {
+		t.Errorf("sources = %d, want 2", got)
+	}
+	if result.Metadata.Plan == nil {
+		t.Error("report has no plan")
+	}
+	if result.Metadata.Incomplete {
+		t.Error("report is marked incomplete")
+	}
+
+	// replayed calls are recorded in the transcript, but use no tokens
+	requests, responses := 0, 0
+	for _, event := range events.events {
+		switch event.Kind {
+		case transcript.KindLLMRequest:
+			requests++
+		case transcript.KindLLMResponse:
+			responses++
+			if !event.Cached {
+				t.Errorf("replayed response %d is not marked cached", event.Seq)
+			}
+		}
+	}
+	if requests != 7 || responses != 7 {
+		t.Errorf("%d llm requests and %d responses recorded, want 7 of each", requests, responses)
+	}
+}
+
+// eventLog keeps the transcript events of a run.
+type eventLog struct {
+	mu     sync.Mutex
+	events []transcript.Event
+}
+
+func (l *eventLog) Record(event transcript.Event) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	l.events = append(l.events, event)
+}
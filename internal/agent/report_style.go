@@ -0,0 +1,186 @@
+package agent
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/rickif/tiny-research/internal/report"
+)
+
+const (
+	ReportStyleDefault    = "default"
+	ReportStyleAcademic   = "academic"
+	ReportStyleExecutive  = "executive"
+	ReportStyleNews       = "news"
+	ReportStyleThread     = "thread"
+	ReportStyleComparison = "comparison"
+)
+
+// ReportSection is a section the reporter must write, in order, as a level
+// 2 heading.
+type ReportSection struct {
+	Heading     string
+	Description string
+	Optional    bool
+}
+
+// ReportStyle is a kind of report: the prompt the reporter writes it with and
+// the sections it is made of.
+type ReportStyle struct {
+	Name        string
+	Description string
+	// Prompt is the prompt file of the reporter, see internal/prompts.
+	Prompt string
+	// KeyPoints is the heading the key points are rendered under, first;
+	// styles without one still collect key points but do not show them.
+	KeyPoints string
+	Sections  []ReportSection
+	// Citations is the heading the citations are rendered under, last.
+	Citations string
+	// Guidance holds writing instructions beyond the sections.
+	Guidance string
+}
+
+const noInlineCitations = "DO NOT include inline citations in the text. List every source in `citations` instead."
+
+const tableGuidance = "PRIORITIZE USING TABLES for data presentation and comparison. Whenever presenting comparative data, statistics, features, or options, put them in the `tables` of the section, with clear headers, rather than in its body."
+
+var reportStyles = map[string]ReportStyle{
+	ReportStyleDefault: {
+		Name:        ReportStyleDefault,
+		Description: "comprehensive research report",
+		Prompt:      "reporter.md",
+		KeyPoints:   "Key Points",
+		Sections: []ReportSection{
+			{Heading: "Overview", Description: "A brief introduction to the topic"},
+			{Heading: "Detailed Analysis", Description: "Organized into logical `###` subsections"},
+			{Heading: "Survey Note", Description: "For more comprehensive reports", Optional: true},
+		},
+		Citations: "Key Citations",
+		Guidance:  noInlineCitations + "\n\n" + tableGuidance,
+	},
+	ReportStyleAcademic: {
+		Name:        ReportStyleAcademic,
+		Description: "academic paper with abstract, method and references",
+		Prompt:      "reporter_academic.md",
+		Sections: []ReportSection{
+			{Heading: "Abstract", Description: "One paragraph summarizing the question, approach and main findings"},
+			{Heading: "Introduction", Description: "Background of the topic and the research question"},
+			{Heading: "Methodology", Description: "How the information was gathered: sources searched, data processed"},
+			{Heading: "Findings", Description: "The results, organized into `###` subsections"},
+			{Heading: "Discussion", Description: "Interpretation of the findings, limitations and open questions"},
+			{Heading: "Conclusion", Description: "The answer to the research question in a few sentences"},
+		},
+		Citations: "References",
+		Guidance:  "Cite sources inline with their bracketed citation id, like [1].\n\n" + tableGuidance,
+	},
+	ReportStyleExecutive: {
+		Name:        ReportStyleExecutive,
+		Description: "one-page brief for decision makers",
+		Prompt:      "reporter_executive.md",
+		KeyPoints:   "Bottom Line",
+		Sections: []ReportSection{
+			{Heading: "Key Findings", Description: "At most five bullets, each one sentence with the key number or fact in bold"},
+			{Heading: "Implications", Description: "What the findings mean for the reader"},
+			{Heading: "Recommendations", Description: "Concrete next steps as a numbered list"},
+			{Heading: "Risks and Unknowns", Description: "What could change the conclusion", Optional: true},
+		},
+		Citations: "Sources",
+		Guidance:  "The key points are the answer in two to four bullets. " + noInlineCitations + "\n\nKeep the whole brief under 400 words. Prefer a small table over prose for numbers.",
+	},
+	ReportStyleNews: {
+		Name:        ReportStyleNews,
+		Description: "news article in inverted pyramid style",
+		Prompt:      "reporter_news.md",
+		Sections: []ReportSection{
+			{Heading: "Lede", Description: "One paragraph answering who, what, when, where and why"},
+			{Heading: "Details", Description: "The supporting facts, most important first, in short paragraphs"},
+			{Heading: "Background", Description: "Context a reader new to the topic needs"},
+			{Heading: "What's Next", Description: "Upcoming events or open questions", Optional: true},
+		},
+		Citations: "Sources",
+		Guidance:  "Use a headline as the title. Attribute facts in the text to their source by name, e.g. \"according to Reuters\", and list every source in `citations`. Do not use tables unless the data cannot be told in prose.",
+	},
+	ReportStyleThread: {
+		Name:        ReportStyleThread,
+		Description: "social media thread of short numbered posts",
+		Prompt:      "reporter_thread.md",
+		Sections: []ReportSection{
+			{Heading: "Thread", Description: "5 to 12 posts numbered `1/`, `2/`, ..., each under 280 characters and separated by an empty line; the first post is a hook, the last a takeaway"},
+		},
+		Citations: "Sources",
+		Guidance:  "Do not use tables, nested lists or subheadings inside the thread. Put at most one link in a post, and list every source in `citations`.",
+	},
+	ReportStyleComparison: {
+		Name:        ReportStyleComparison,
+		Description: "technical comparison of options against criteria",
+		Prompt:      "reporter_comparison.md",
+		Sections: []ReportSection{
+			{Heading: "Summary", Description: "Which option fits which use case, in a few sentences"},
+			{Heading: "Options", Description: "A short description of each option compared"},
+			{Heading: "Comparison Matrix", Description: "A table with one row per option and one column per criterion"},
+			{Heading: "Criteria in Detail", Description: "A `###` subsection per criterion explaining the differences"},
+			{Heading: "Recommendation", Description: "The recommended option per use case and why"},
+		},
+		Citations: "Key Citations",
+		Guidance:  noInlineCitations + "\n\n" + tableGuidance,
+	},
+}
+
+// ReportStyles returns the names of the available report styles, sorted.
+func ReportStyles() []string {
+	names := make([]string, 0, len(reportStyles))
+	for name := range reportStyles {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// LookupReportStyle returns the report style named name; an empty name is
+// the default style.
+func LookupReportStyle(name string) (ReportStyle, error) {
+	if name == "" {
+		name = ReportStyleDefault
+	}
+	style, ok := reportStyles[name]
+	if !ok {
+		return ReportStyle{}, fmt.Errorf("unknown report style %q, expected one of %s", name, strings.Join(ReportStyles(), ", "))
+	}
+	return style, nil
+}
+
+// instruction tells the reporter how to structure a report of this style.
+func (style ReportStyle) instruction() string {
+	var b strings.Builder
+	fmt.Fprintf(&b, "IMPORTANT: Write a %s as a JSON object:\n\n", style.Description)
+	b.WriteString("- `title`: the title of the report\n")
+	if style.KeyPoints != "" {
+		fmt.Fprintf(&b, "- `key_points`: the most important findings, one sentence each, shown as %q\n", style.KeyPoints)
+	} else {
+		b.WriteString("- `key_points`: the most important findings, one sentence each\n")
+	}
+	b.WriteString("- `sections`: the sections below, in this order, each with its `heading`, its markdown `body` (no tables, and no `#` or `##` headings) and its `tables`")
+	for i, section := range style.Sections {
+		optional := ""
+		if section.Optional {
+			optional = " (optional)"
+		}
+		fmt.Fprintf(&b, "\n  %d. %s%s - %s", i+1, section.Heading, optional, section.Description)
+	}
+	b.WriteString("\n- `citations`: every source the report relies on, with `id` numbered from 1, its `title` and its `url`")
+	if style.Guidance != "" {
+		b.WriteString("\n\n" + style.Guidance)
+	}
+	return b.String()
+}
+
+// apply lays out content in this style.
+func (style ReportStyle) apply(content report.Content) *report.Report {
+	return &report.Report{
+		Content:          content,
+		KeyPointsHeading: style.KeyPoints,
+		CitationsHeading: style.Citations,
+	}
+}
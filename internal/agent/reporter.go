@@ -2,38 +2,60 @@ package agent
 
 import (
 	"context"
+	"encoding/json"
 	"fmt"
 	"log/slog"
-	"os"
+	"strings"
 	"time"
 
+	"github.com/rickif/tiny-research/internal/llm"
+	promptfiles "github.com/rickif/tiny-research/internal/prompts"
+	"github.com/rickif/tiny-research/internal/report"
+	"github.com/rickif/tiny-research/internal/telemetry"
+	"github.com/rickif/tiny-research/internal/tool"
 	"github.com/tmc/langchaingo/llms"
-	"github.com/tmc/langchaingo/llms/openai"
 	"github.com/tmc/langchaingo/prompts"
 )
 
 var _ Node = (*Reporter)(nil)
 
+// maxRetrieveRounds bounds the rounds of retrieve calls before the report
+// is written.
+const maxRetrieveRounds = 5
+
 type Reporter struct {
-	llm *openai.LLM
+	llm        llms.Model
+	retriever  *tool.Retriever
+	strictJSON bool
 }
 
-func NewReporter(llm *openai.LLM) *Reporter {
+// NewReporter creates a reporter node. retriever is optional and lets the
+// reporter pull source passages with the retrieve tool when non-nil.
+// strictJSON enforces the report schema like for the planner.
+func NewReporter(llm llms.Model, retriever *tool.Retriever, strictJSON bool) *Reporter {
 	return &Reporter{
-		llm: llm,
+		llm:        llm,
+		retriever:  retriever,
+		strictJSON: strictJSON,
 	}
 }
 
 func (reporter *Reporter) Execute(ctx context.Context, state *AgentState) (nextStep string, output string, err error) {
 	slog.Info("reporter starts")
 
-	content, err := os.ReadFile("./internal/prompts/reporter.md")
+	style, err := LookupReportStyle(state.ReportStyle)
+	if err != nil {
+		slog.Error("lookup report style", "error", err)
+		return "", "", err
+	}
+
+	content, err := promptfiles.ReadFile(style.Prompt)
 	if err != nil {
 		slog.Error("read planner prompt file", "error", err)
 		return "", "", err
 	}
 	promptTemplate, err := prompts.NewPromptTemplate(string(content), []string{"current_time", "max_step_num"}).Format(map[string]any{
-		"current_time": time.Now().Format(time.RFC3339),
+		"current_time": state.CurrentTime.Format(time.RFC3339),
 	})
 	if err != nil {
 		slog.Error("format planner prompt", "error", err)
@@ -53,17 +75,166 @@ func (reporter *Reporter) Execute(ctx context.Context, state *AgentState) (nextS
 
 	messages = append(messages, llms.MessageContent{
 		Role:  llms.ChatMessageTypeSystem,
-		Parts: []llms.ContentPart{llms.TextContent{Text: "IMPORTANT: Structure your report according to the format in the prompt. Remember to include:\n\n1. Key Points - A bulleted list of the most important findings\n2. Overview - A brief introduction to the topic\n3. Detailed Analysis - Organized into logical sections\n4. Survey Note (optional) - For more comprehensive reports\n5. Key Citations - List all references at the end\n\nFor citations, DO NOT include inline citations in the text. Instead, place all citations in the 'Key Citations' section at the end using the format: `- [Source Title](URL)`. Include an empty line between each citation for better readability.\n\nPRIORITIZE USING MARKDOWN TABLES for data presentation and comparison. Use tables whenever presenting comparative data, statistics, features, or options. Structure tables with clear headers and aligned columns. Example table format:\n\n| Feature | Description | Pros | Cons |\n|---------|-------------|------|------|\n| Feature 1 | Description 1 | Pros 1 | Cons 1 |\n| Feature 2 | Description 2 | Pros 2 | Cons 2 |"}},
+		Parts: []llms.ContentPart{llms.TextContent{Text: style.instruction()}},
 	})
 
+	if state.Turn > 1 {
+		messages = append(messages, llms.MessageContent{
+			Role:  llms.ChatMessageTypeSystem,
+			Parts: []llms.ContentPart{llms.TextContent{Text: fmt.Sprintf("IMPORTANT: This is a follow-up question in a conversation: %q. Write the report as the answer to it, building on the findings of the whole conversation and the previous reports rather than repeating them.", state.Query)}},
+		})
+	}
+
+	retrieve := reporter.retriever != nil && state.toolAllowed(tool.RetrieveTool.Function.Name)
+	if retrieve {
+		messages = append(messages, llms.MessageContent{
+			Role:  llms.ChatMessageTypeSystem,
+			Parts: []llms.ContentPart{llms.TextContent{Text: "The full text of searched and crawled sources is not included below. Use the `retrieve` tool to pull the exact source passages you need to support or detail the findings before writing the report."}},
+		})
+	}
+
+	if state.Interruption != nil {
+		messages = append(messages, llms.MessageContent{
+			Role:  llms.ChatMessageTypeSystem,
+			Parts: []llms.ContentPart{llms.TextContent{Text: fmt.Sprintf("IMPORTANT: The research was stopped before every step of the plan was executed (%s). Write the report from the findings below only, do not make up results for the missing steps, and point out where the findings are incomplete.", state.Interruption.Reason)}},
+		})
+	}
+
+	if len(state.Verification) > 0 {
+		messages = append(messages, llms.MessageContent{
+			Role:  llms.ChatMessageTypeSystem,
+			Parts: []llms.ContentPart{llms.TextContent{Text: "IMPORTANT: The key claims of the findings were fact-checked, see the Claim Verification message. State supported claims plainly. Do not present contradicted claims as facts: give the conflicting versions and their sources, or leave the claim out. Qualify unverified claims, e.g. \"reportedly\", rather than stating them as certain."}},
+		})
+	}
+
 	messages = append(messages, state.Messages...)
 
-	resp, err := reporter.llm.GenerateContent(ctx, messages)
-	if err != nil {
-		slog.Error("generate plan", "error", err)
+	if state.Critique != nil && state.Report != nil {
+		messages = append(messages, llms.MessageContent{
+			Role:  llms.ChatMessageTypeHuman,
+			Parts: []llms.ContentPart{llms.TextContent{Text: revisionRequest(state.Report.Markdown(), state.Critique)}},
+		})
+	}
+
+	if retrieve {
+		messages, err = reporter.retrieve(ctx, state, messages)
+		if err != nil {
+			return "", "", err
+		}
+	}
+
+	var written report.Content
+	if _, err := llm.GenerateJSON(ctx, reporter.llm, messages, &written, 3, reporter.strictJSON); err != nil {
+		slog.Error("generate report", "error", err)
 		return "", "", err
 	}
 
-	slog.Info("reporter ends")
-	return StepEnd, resp.Choices[0].Content, nil
+	result := style.apply(written)
+	if state.Interruption != nil {
+		markIncomplete(state, result)
+	}
+	state.Report = result
+	slog.Info("reporter ends", "sections", len(result.Sections), "citations", len(result.Citations))
+	return StepEnd, result.Markdown(), nil
+}
+
+// retrieve lets the model pull source passages with the retrieve tool until
+// it stops calling it, for at most maxRetrieveRounds rounds and within the
+// tool call budget, and returns messages extended with the passages.
+func (reporter *Reporter) retrieve(ctx context.Context, state *AgentState, messages []llms.MessageContent) ([]llms.MessageContent, error) {
+	messages = append(messages, llms.MessageContent{
+		Role:  llms.ChatMessageTypeHuman,
+		Parts: []llms.ContentPart{llms.TextContent{Text: "Retrieve the source passages you need for the report. Reply with DONE once you have them."}},
+	})
+	for round := 0; round < maxRetrieveRounds; round++ {
+		if state.MaxToolCalls > 0 && state.ToolCalls >= state.MaxToolCalls {
+			slog.Info("reporter stops retrieving, tool call budget exhausted", "max_tool_calls", state.MaxToolCalls)
+			return messages, nil
+		}
+		resp, err := reporter.llm.GenerateContent(ctx, messages, llms.WithTools([]llms.Tool{tool.RetrieveTool}))
+		if err != nil {
+			slog.Error("generate content", "error", err)
+			return nil, err
+		}
+		if len(resp.Choices[0].ToolCalls) == 0 {
+			return messages, nil
+		}
+
+		messages = append(messages, llms.MessageContent{
+			Role:  llms.ChatMessageTypeAI,
+			Parts: toolCallParts(resp.Choices[0].ToolCalls),
+		})
+		for _, toolcall := range resp.Choices[0].ToolCalls {
+			if toolcall.FunctionCall.Name != "retrieve" {
+				slog.Error("unexpected function call", "name", toolcall.FunctionCall.Name)
+				return nil, fmt.Errorf("unexpected function call: %v", toolcall.FunctionCall.Name)
+			}
+			var args struct {
+				Query string `json:"query"`
+			}
+			if err := json.Unmarshal([]byte(toolcall.FunctionCall.Arguments), &args); err != nil {
+				slog.Error("unmarshal arguments", "error", err)
+				return nil, err
+			}
+			// every tool call gets a response, so the calls over the budget
+			// are answered without being run
+			output := "Tool call budget exhausted. Write the report from the passages retrieved so far."
+			if state.MaxToolCalls <= 0 || state.ToolCalls < state.MaxToolCalls {
+				state.ToolCalls++
+				var err error
+				output, err = telemetry.TraceTool(ctx, toolcall.FunctionCall.Name, args.Query, func(ctx context.Context) (string, error) {
+					return reporter.retriever.Retrieve(ctx, args.Query)
+				})
+				if err != nil {
+					slog.Error("retrieve", "error", err)
+					return nil, err
+				}
+			}
+			messages = append(messages, llms.MessageContent{
+				Role: llms.ChatMessageTypeTool,
+				Parts: []llms.ContentPart{
+					llms.ToolCallResponse{
+						ToolCallID: toolcall.ID,
+						Name:       toolcall.FunctionCall.Name,
+						Content:    output,
+					},
+				},
+			})
+			slog.Info("reporter use retrieve", "query", args.Query)
+		}
+	}
+	slog.Info("reporter stops retrieving", "rounds", maxRetrieveRounds)
+	return messages, nil
+}
+
+func toolCallParts(toolCalls []llms.ToolCall) []llms.ContentPart {
+	parts := make([]llms.ContentPart, 0, len(toolCalls))
+	for _, toolCall := range toolCalls {
+		parts = append(parts, toolCall)
+	}
+	return parts
+}
+
+// markIncomplete marks result as written from partial results and lists
+// the plan steps that were not executed.
+func markIncomplete(state *AgentState, result *report.Report) {
+	interruption := state.Interruption
+	result.Notice = fmt.Sprintf("**Incomplete report**: the research stopped in the %s (%s). The findings below cover the executed steps only.", strings.Trim(interruption.Step, "_"), interruption.Reason)
+
+	var pending []string
+	failed := interruption.Step == StepResearcher || interruption.Step == StepCoder
+	for _, step := range state.CurrentPlan.Steps {
+		if step.ExecutionResult != "" {
+			continue
+		}
+		if failed {
+			pending = append(pending, fmt.Sprintf("- %s: failed: %s", step.Title, interruption.Reason))
+			failed = false
+			continue
+		}
+		pending = append(pending, fmt.Sprintf("- %s: not executed, the run stopped", step.Title))
+	}
+	if len(pending) > 0 {
+		result.Sections = append(result.Sections, report.Section{Heading: "Steps Not Executed", Body: strings.Join(pending, "\n")})
+	}
 }
@@ -4,16 +4,17 @@ import (
 	"context"
 	"log/slog"
 
-	"github.com/tmc/langchaingo/llms/openai"
+	"github.com/rickif/tiny-research/internal/tool"
+	"github.com/tmc/langchaingo/llms"
 )
 
 var _ Node = (*ResearchTeam)(nil)
 
 type ResearchTeam struct {
-	llm *openai.LLM
+	llm llms.Model
 }
 
-func NewResearchTeam(llm *openai.LLM) *ResearchTeam {
+func NewResearchTeam(llm llms.Model) *ResearchTeam {
 	return &ResearchTeam{
 		llm: llm,
 	}
@@ -34,8 +35,7 @@ func (planner *ResearchTeam) Execute(ctx context.Context, state *AgentState) (ne
 	}
 
 	if step == nil {
-		slog.Info("research team assign task", "agent", "planner")
-		return StepPlanner, "", nil
+		return afterSteps(state), "", nil
 	}
 
 	switch step.StepType {
@@ -43,10 +43,27 @@ func (planner *ResearchTeam) Execute(ctx context.Context, state *AgentState) (ne
 		slog.Info("research team assign task", "agent", "researcher")
 		return StepResearcher, "", nil
 	case StepTypeProcessing:
+		if !state.toolAllowed(tool.PythonTool.Function.Name) {
+			// without code execution the researcher reasons it through
+			slog.Info("research team assign task", "agent", "researcher")
+			return StepResearcher, "", nil
+		}
 		slog.Info("research team assign task", "agent", "coder")
 		return StepCoder, "", nil
 	default:
-		slog.Info("research team assign task", "agent", "planner")
-		return StepPlanner, "", nil
+		return afterSteps(state), "", nil
+	}
+}
+
+// afterSteps returns the node taking over once the steps of the plan are
+// executed: the planner, or the reporter for the follow-up steps of the
+// critic, which would otherwise start another planning iteration and let a
+// new plan drop the findings of the reviewed one.
+func afterSteps(state *AgentState) string {
+	if state.Critique != nil {
+		slog.Info("research team assign task", "agent", "reporter")
+		return StepReporter
 	}
+	slog.Info("research team assign task", "agent", "planner")
+	return StepPlanner
 }
@@ -0,0 +1,56 @@
+package agent
+
+import (
+	"context"
+	"testing"
+
+	"github.com/rickif/tiny-research/internal/fake"
+)
+
+func TestResearchTeamRouting(t *testing.T) {
+	done := researchStep("Done")
+	done.ExecutionResult = "findings"
+	tests := []struct {
+		name     string
+		steps    []Step
+		noPlan   bool
+		tools    []string
+		critique bool
+		wantNext string
+	}{
+		{name: "plans without a plan", noPlan: true, wantNext: StepPlanner},
+		{name: "researches a research step", steps: []Step{researchStep("Density")}, wantNext: StepResearcher},
+		{name: "codes a processing step", steps: []Step{processingStep("Average")}, wantNext: StepCoder},
+		{name: "researches a processing step without python", steps: []Step{processingStep("Average")}, tools: []string{"tavily_search"}, wantNext: StepResearcher},
+		{name: "skips executed steps", steps: []Step{done, processingStep("Average")}, wantNext: StepCoder},
+		{name: "plans again once every step is executed", steps: []Step{done}, wantNext: StepPlanner},
+		{name: "plans again for an unknown step type", steps: []Step{{Title: "Odd", Description: "d", StepType: "survey"}}, wantNext: StepPlanner},
+		{name: "reports once the follow-up steps of the critic are executed", steps: []Step{done}, critique: true, wantNext: StepReporter},
+		{name: "researches a follow-up step of the critic", steps: []Step{done, researchStep("Cost")}, critique: true, wantNext: StepResearcher},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			state := newTestState(tt.steps...)
+			if tt.noPlan {
+				state.CurrentPlan = nil
+			}
+			state.AllowedTools = tt.tools
+			if tt.critique {
+				state.Critique = &Critique{Issues: []string{"The cost is missing."}}
+			}
+
+			// routing never calls the model
+			model := fake.NewModel()
+			next, _, err := NewResearchTeam(model).Execute(context.Background(), state)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if next != tt.wantNext {
+				t.Errorf("next = %q, want %q", next, tt.wantNext)
+			}
+			if n := len(model.Calls()); n != 0 {
+				t.Errorf("%d model calls, want none", n)
+			}
+		})
+	}
+}
@@ -3,34 +3,45 @@ package agent
 import (
 	"context"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"log/slog"
-	"os"
 	"time"
 
+	promptfiles "github.com/rickif/tiny-research/internal/prompts"
+	"github.com/rickif/tiny-research/internal/telemetry"
 	"github.com/rickif/tiny-research/internal/tool"
 	"github.com/tmc/langchaingo/llms"
-	"github.com/tmc/langchaingo/llms/openai"
 	"github.com/tmc/langchaingo/prompts"
 )
 
 var _ Node = (*Researcher)(nil)
 
 type Researcher struct {
-	llm       *openai.LLM
-	tavilyKey string
+	llm         llms.Model
+	searcher    tool.Searcher
+	crawler     tool.Crawler
+	localSearch *tool.LocalSearch
+	retriever   *tool.Retriever
 }
 
-func NewResearcher(llm *openai.LLM, tavilyKey string) *Researcher {
+// NewResearcher creates a researcher node. localSearch and retriever are
+// optional and enable the local_search and retrieve tools when non-nil.
+func NewResearcher(llm llms.Model, searcher tool.Searcher, crawler tool.Crawler, localSearch *tool.LocalSearch, retriever *tool.Retriever) *Researcher {
 	return &Researcher{
-		llm:       llm,
-		tavilyKey: tavilyKey,
+		llm:         llm,
+		searcher:    searcher,
+		crawler:     crawler,
+		localSearch: localSearch,
+		retriever:   retriever,
 	}
 }
 
 func (r *Researcher) Execute(ctx context.Context, state *AgentState) (nextStep string, output string, err error) {
 	slog.Info("researcher starts")
-	content, err := os.ReadFile("./internal/prompts/researcher.md")
+	// crawled pages are only stored for retrieval when the run may retrieve
+	retrieve := r.retriever != nil && state.toolAllowed(tool.RetrieveTool.Function.Name)
+	content, err := promptfiles.ReadFile("researcher.md")
 	if err != nil {
 		slog.Error("read prompt template", "error", err)
 		return "", "", err
@@ -40,8 +51,12 @@ func (r *Researcher) Execute(ctx context.Context, state *AgentState) (nextStep s
 		string(content),
 		[]string{"current_time", "locale"},
 	).Format(map[string]any{
-		"current_time": time.Now().Format(time.RFC3339),
+		"current_time": state.CurrentTime.Format(time.RFC3339),
 		"locale":       state.Locale,
+		"web_search":   state.toolAllowed(tool.SearchTool.Function.Name),
+		"crawl":        state.toolAllowed(tool.CrawlTool.Function.Name),
+		"local_search": r.localSearch != nil && state.toolAllowed(tool.LocalSearchTool.Function.Name),
+		"retrieve":     retrieve,
 	})
 	if err != nil {
 		slog.Error("format prompt", "error", err)
@@ -67,14 +82,41 @@ func (r *Researcher) Execute(ctx context.Context, state *AgentState) (nextStep s
 		},
 	}
 
+	var tools []llms.Tool
+	for _, t := range []llms.Tool{tool.CrawlTool, tool.SearchTool, tool.LocalSearchTool, tool.RetrieveTool} {
+		switch {
+		case !state.toolAllowed(t.Function.Name):
+		case t.Function.Name == tool.LocalSearchTool.Function.Name && r.localSearch == nil:
+		case t.Function.Name == tool.RetrieveTool.Function.Name && r.retriever == nil:
+		default:
+			tools = append(tools, t)
+		}
+	}
+
 	for {
-		resp, err := r.llm.GenerateContent(ctx, messages, llms.WithTools([]llms.Tool{tool.CrawlTool, tool.SearchTool}))
+		resp, err := r.llm.GenerateContent(ctx, messages, llms.WithTools(tools))
 		if err != nil {
 			slog.Error("generate content", "error", err)
 			return "", "", err
 		}
+		// the tool responses must follow the message requesting them
+		if len(resp.Choices[0].ToolCalls) > 0 {
+			messages = append(messages, llms.MessageContent{
+				Role:  llms.ChatMessageTypeAI,
+				Parts: toolCallParts(resp.Choices[0].ToolCalls),
+			})
+		}
 
 		for _, toolcall := range resp.Choices[0].ToolCalls {
+			if !state.toolAllowed(toolcall.FunctionCall.Name) {
+				slog.Error("disallowed function call", "name", toolcall.FunctionCall.Name)
+				return "", "", fmt.Errorf("function call not allowed in this run: %v", toolcall.FunctionCall.Name)
+			}
+			if state.MaxToolCalls > 0 && state.ToolCalls >= state.MaxToolCalls {
+				slog.Error("tool call budget exhausted", "max_tool_calls", state.MaxToolCalls)
+				return "", "", fmt.Errorf("%w: %d tool calls", ErrBudgetExhausted, state.MaxToolCalls)
+			}
+			state.ToolCalls++
 			switch toolcall.FunctionCall.Name {
 			case "crawl":
 				var args struct {
@@ -84,10 +126,24 @@ func (r *Researcher) Execute(ctx context.Context, state *AgentState) (nextStep s
 					slog.Error("unmarshal arguments", "error", err)
 					return "", "", err
 				}
-				output, err := tool.Crawl(ctx, args.URL)
-				if err != nil {
+				output, err := r.crawler.Crawl(ctx, args.URL)
+				switch {
+				case errors.Is(err, tool.ErrTimeout):
+					slog.Warn("crawl timed out", "url", args.URL, "error", err)
+					output = fmt.Sprintf("Error: %v. Try another source.", err)
+				case err != nil:
 					slog.Error("crawl", "error", err)
 					return "", "", err
+				default:
+					state.Sources = append(state.Sources, Source{Tool: toolcall.FunctionCall.Name, Input: args.URL, Content: output})
+					if retrieve {
+						n, err := r.retriever.Index(ctx, args.URL, output)
+						if err != nil {
+							slog.Error("index crawled content", "error", err)
+							return "", "", err
+						}
+						output = fmt.Sprintf("Crawled %s and stored %d passages. Use the retrieve tool to look up the passages relevant to the task.", args.URL, n)
+					}
 				}
 				message := llms.MessageContent{
 					Role: llms.ChatMessageTypeTool,
@@ -109,10 +165,22 @@ func (r *Researcher) Execute(ctx context.Context, state *AgentState) (nextStep s
 					slog.Error("unmarshal arguments", "error", err)
 					return "", "", err
 				}
-				output, err := tool.NewTavilySearchTool(r.tavilyKey).Search(ctx, args.Query)
-				if err != nil {
+				output, err := r.searcher.Search(ctx, args.Query)
+				switch {
+				case errors.Is(err, tool.ErrTimeout):
+					slog.Warn("search timed out", "query", args.Query, "error", err)
+					output = fmt.Sprintf("Error: %v. Try another query.", err)
+				case err != nil:
 					slog.Error("search", "error", err)
 					return "", "", err
+				default:
+					state.Sources = append(state.Sources, Source{Tool: toolcall.FunctionCall.Name, Input: args.Query, Content: output})
+					if retrieve {
+						if _, err := r.retriever.IndexSearch(ctx, output); err != nil {
+							slog.Error("index search results", "error", err)
+							return "", "", err
+						}
+					}
 				}
 				message := llms.MessageContent{
 					Role: llms.ChatMessageTypeTool,
@@ -126,6 +194,69 @@ func (r *Researcher) Execute(ctx context.Context, state *AgentState) (nextStep s
 				}
 				messages = append(messages, message)
 				slog.Info("researcher use tavily search", "query", args.Query)
+			case "local_search":
+				if r.localSearch == nil {
+					slog.Error("local search is not enabled")
+					return "", "", fmt.Errorf("unexpected function call: %v", toolcall.FunctionCall.Name)
+				}
+				var args struct {
+					Query string `json:"query"`
+				}
+				if err := json.Unmarshal([]byte(toolcall.FunctionCall.Arguments), &args); err != nil {
+					slog.Error("unmarshal arguments", "error", err)
+					return "", "", err
+				}
+				output, err := telemetry.TraceTool(ctx, toolcall.FunctionCall.Name, args.Query, func(ctx context.Context) (string, error) {
+					return r.localSearch.Search(ctx, args.Query)
+				})
+				if err != nil {
+					slog.Error("local search", "error", err)
+					return "", "", err
+				}
+				state.Sources = append(state.Sources, Source{Tool: toolcall.FunctionCall.Name, Input: args.Query, Content: output})
+				message := llms.MessageContent{
+					Role: llms.ChatMessageTypeTool,
+					Parts: []llms.ContentPart{
+						llms.ToolCallResponse{
+							ToolCallID: toolcall.ID,
+							Name:       toolcall.FunctionCall.Name,
+							Content:    output,
+						},
+					},
+				}
+				messages = append(messages, message)
+				slog.Info("researcher use local search", "query", args.Query)
+			case "retrieve":
+				if r.retriever == nil {
+					slog.Error("retrieval is not enabled")
+					return "", "", fmt.Errorf("unexpected function call: %v", toolcall.FunctionCall.Name)
+				}
+				var args struct {
+					Query string `json:"query"`
+				}
+				if err := json.Unmarshal([]byte(toolcall.FunctionCall.Arguments), &args); err != nil {
+					slog.Error("unmarshal arguments", "error", err)
+					return "", "", err
+				}
+				output, err := telemetry.TraceTool(ctx, toolcall.FunctionCall.Name, args.Query, func(ctx context.Context) (string, error) {
+					return r.retriever.Retrieve(ctx, args.Query)
+				})
+				if err != nil {
+					slog.Error("retrieve", "error", err)
+					return "", "", err
+				}
+				message := llms.MessageContent{
+					Role: llms.ChatMessageTypeTool,
+					Parts: []llms.ContentPart{
+						llms.ToolCallResponse{
+							ToolCallID: toolcall.ID,
+							Name:       toolcall.FunctionCall.Name,
+							Content:    output,
+						},
+					},
+				}
+				messages = append(messages, message)
+				slog.Info("researcher use retrieve", "query", args.Query)
 			default:
 				slog.Error("unexpected function call", "name", toolcall.FunctionCall.Name)
 				return "", "", fmt.Errorf("unexpected function call: %v", toolcall.FunctionCall.Name)
@@ -0,0 +1,183 @@
+package agent
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"slices"
+	"strings"
+	"testing"
+
+	"github.com/rickif/tiny-research/internal/fake"
+	"github.com/rickif/tiny-research/internal/tool"
+	"github.com/tmc/langchaingo/llms"
+)
+
+func TestResearcherExecute(t *testing.T) {
+	tests := []struct {
+		name          string
+		responses     []fake.Response
+		tools         []string
+		maxToolCalls  int
+		toolCalls     int
+		wantErr       error
+		wantAnyErr    bool
+		wantSearches  []string
+		wantCrawls    []string
+		wantToolCalls int
+	}{
+		{
+			name:          "answers without tools",
+			responses:     []fake.Response{fake.Text("Findings.")},
+			wantToolCalls: 0,
+		},
+		{
+			name: "searches then crawls",
+			responses: []fake.Response{
+				fake.ToolCall("tavily_search", `{"query":"solid-state density"}`),
+				fake.ToolCall("crawl", `{"url":"https://example.com/a"}`),
+				fake.Text("Findings."),
+			},
+			wantSearches:  []string{"solid-state density"},
+			wantCrawls:    []string{"https://example.com/a"},
+			wantToolCalls: 2,
+		},
+		{
+			name: "runs parallel tool calls",
+			responses: []fake.Response{
+				fake.ToolCalls(toolCall("tavily_search", `{"query":"a"}`), toolCall("tavily_search", `{"query":"b"}`)),
+				fake.Text("Findings."),
+			},
+			wantSearches:  []string{"a", "b"},
+			wantToolCalls: 2,
+		},
+		{
+			name:         "stops when the budget is spent",
+			responses:    []fake.Response{fake.ToolCall("tavily_search", `{"query":"a"}`)},
+			maxToolCalls: 3,
+			toolCalls:    3,
+			wantErr:      ErrBudgetExhausted,
+		},
+		{
+			name: "spends the budget within the step",
+			responses: []fake.Response{
+				fake.ToolCall("tavily_search", `{"query":"a"}`),
+				fake.ToolCall("tavily_search", `{"query":"b"}`),
+			},
+			maxToolCalls: 1,
+			wantErr:      ErrBudgetExhausted,
+			wantSearches: []string{"a"},
+		},
+		{
+			name:       "rejects a tool not allowed in the run",
+			responses:  []fake.Response{fake.ToolCall("crawl", `{"url":"https://example.com/a"}`)},
+			tools:      []string{"tavily_search"},
+			wantAnyErr: true,
+		},
+		{
+			name:       "rejects an unknown tool",
+			responses:  []fake.Response{fake.ToolCall("send_email", `{}`)},
+			wantAnyErr: true,
+		},
+		{
+			name:       "rejects malformed tool arguments",
+			responses:  []fake.Response{fake.ToolCall("tavily_search", `{"query":`)},
+			wantAnyErr: true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			model := fake.NewModel(tt.responses...)
+			searcher := &fake.Tool{Default: "search results"}
+			crawler := &fake.Tool{Default: "page content"}
+			state := newTestState(researchStep("Density"))
+			state.AllowedTools = tt.tools
+			state.MaxToolCalls = tt.maxToolCalls
+			state.ToolCalls = tt.toolCalls
+
+			next, output, err := NewResearcher(model, searcher, crawler, nil, nil).Execute(context.Background(), state)
+			for _, call := range model.Calls() {
+				checkToolCallOrder(t, call.Messages)
+			}
+			if got := searcher.Inputs(); !slices.Equal(got, tt.wantSearches) {
+				t.Errorf("searches = %q, want %q", got, tt.wantSearches)
+			}
+			if got := crawler.Inputs(); !slices.Equal(got, tt.wantCrawls) {
+				t.Errorf("crawls = %q, want %q", got, tt.wantCrawls)
+			}
+			switch {
+			case tt.wantErr != nil || tt.wantAnyErr:
+				if err == nil || (tt.wantErr != nil && !errors.Is(err, tt.wantErr)) {
+					t.Fatalf("error = %v, want %v", err, tt.wantErr)
+				}
+				if state.CurrentPlan.Steps[0].ExecutionResult != "" {
+					t.Error("failed step has findings")
+				}
+				return
+			case err != nil:
+				t.Fatal(err)
+			}
+
+			if next != StepResearchTeam || output != "Findings." {
+				t.Errorf("Execute = (%q, %q), want (%q, %q)", next, output, StepResearchTeam, "Findings.")
+			}
+			if got := state.CurrentPlan.Steps[0].ExecutionResult; got != "Findings." {
+				t.Errorf("step findings = %q", got)
+			}
+			if state.ToolCalls != tt.wantToolCalls {
+				t.Errorf("tool calls = %d, want %d", state.ToolCalls, tt.wantToolCalls)
+			}
+			if got := len(state.Sources); got != tt.wantToolCalls {
+				t.Errorf("%d sources, want %d", got, tt.wantToolCalls)
+			}
+		})
+	}
+}
+
+func TestResearcherOffersAllowedToolsOnly(t *testing.T) {
+	model := fake.NewModel(fake.Text("Findings."))
+	state := newTestState(researchStep("Density"))
+	state.AllowedTools = []string{"crawl"}
+	if _, _, err := NewResearcher(model, &fake.Tool{}, &fake.Tool{}, nil, nil).Execute(context.Background(), state); err != nil {
+		t.Fatal(err)
+	}
+	var offered []string
+	for _, tool := range model.Calls()[0].Options.Tools {
+		offered = append(offered, tool.Function.Name)
+	}
+	if !slices.Equal(offered, []string{"crawl"}) {
+		t.Errorf("offered tools = %q, want [crawl]", offered)
+	}
+}
+
+func TestResearcherReportsToolTimeouts(t *testing.T) {
+	tests := []struct {
+		name    string
+		err     error
+		wantErr bool
+	}{
+		{name: "a timeout goes back to the model", err: fmt.Errorf("%w after 1s: %w", tool.ErrTimeout, context.DeadlineExceeded)},
+		{name: "other errors end the step", err: errors.New("tavily: unauthorized"), wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			model := fake.NewModel(fake.ToolCall("tavily_search", `{"query":"a"}`), fake.Text("Findings."))
+			state := newTestState(researchStep("Density"))
+			_, _, err := NewResearcher(model, &fake.Tool{Err: tt.err}, &fake.Tool{}, nil, nil).Execute(context.Background(), state)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("error = %v, want error %v", err, tt.wantErr)
+			}
+			if tt.wantErr {
+				return
+			}
+			retry := model.Calls()[1].Messages
+			response, ok := retry[len(retry)-1].Parts[0].(llms.ToolCallResponse)
+			if !ok || !strings.Contains(response.Content, "timed out") {
+				t.Errorf("tool response = %+v, want a timeout error", retry[len(retry)-1].Parts[0])
+			}
+			if len(state.Sources) != 0 {
+				t.Errorf("%d sources, want none", len(state.Sources))
+			}
+		})
+	}
+}
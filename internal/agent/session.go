@@ -0,0 +1,228 @@
+package agent
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/rickif/tiny-research/internal/rag"
+	"github.com/rickif/tiny-research/internal/report"
+	"github.com/rickif/tiny-research/internal/telemetry"
+	"github.com/rickif/tiny-research/internal/tool"
+	"github.com/rickif/tiny-research/internal/transcript"
+	"github.com/tmc/langchaingo/llms"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// Session is a conversation with the agent. Every question after the first
+// is a follow-up: the coordinator answers it from the plans, findings and
+// sources of the previous turns, or hands it to the planner for incremental
+// research on top of them.
+type Session struct {
+	agent     *Agent
+	retriever *tool.Retriever
+
+	mu    sync.Mutex
+	state AgentState
+}
+
+// NewSession starts a conversation. options apply to every question; those
+// passed to Ask apply from that question on.
+func (wf *Agent) NewSession(options ...ResearchOption) (*Session, error) {
+	state := AgentState{
+		Locale:       "en-US",
+		SessionID:    uuid.NewString(),
+		MaxToolCalls: wf.config.MaxToolCalls,
+	}
+	for _, option := range options {
+		option(&state)
+	}
+	if err := validateOptions(&state); err != nil {
+		return nil, err
+	}
+
+	session := &Session{agent: wf, state: state}
+	if wf.embedder != nil {
+		store, err := rag.NewVectorStore(wf.embedder, filepath.Join(wf.config.VectorStoreDir, state.SessionID, "vectors.json"), 1000, 2)
+		if err != nil {
+			slog.Error("open vector store", "error", err)
+			return nil, err
+		}
+		session.retriever = tool.NewRetriever(store, 5)
+	}
+	return session, nil
+}
+
+// ID returns the session ID, which also names the session vector store.
+func (s *Session) ID() string {
+	return s.state.SessionID
+}
+
+// Turns returns the number of questions asked so far.
+func (s *Session) Turns() int {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.state.Turn
+}
+
+// Ask asks the next question of the conversation and returns its report,
+// like Agent.Research. Questions of a session are answered one at a time.
+func (s *Session) Ask(ctx context.Context, question string, options ...ResearchOption) (result *report.Report, err error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	start := time.Now()
+	state := &s.state
+	previous := *state
+	for _, option := range options {
+		option(state)
+	}
+	if err := validateOptions(state); err != nil {
+		*state = previous
+		return nil, err
+	}
+	state.newTurn(question, s.agent.now())
+	// the sources of earlier turns were saved with their own runs
+	firstSource := len(state.Sources)
+	if s.agent.config.TranscriptDir != "" {
+		t, err := s.agent.createTranscript(state)
+		if err != nil {
+			slog.Error("create transcript", "error", err)
+			*state = previous
+			return nil, err
+		}
+		ctx = transcript.NewContext(ctx, t)
+		defer func() {
+			if err := t.Close(); err != nil {
+				slog.Error("write transcript", "path", t.Path(), "error", err)
+				return
+			}
+			slog.Info("transcript written", "path", t.Path(), "timeline", t.HTMLPath())
+		}()
+	}
+	transcript.Record(ctx, transcript.Event{
+		Kind: transcript.KindRunStart,
+		Time: state.CurrentTime,
+		Run: &transcript.Run{
+			Query:     state.Query,
+			SessionID: state.SessionID,
+			Turn:      state.Turn,
+			Style:     state.ReportStyle,
+			Locale:    state.Locale,
+			Model:     s.agent.config.LLMModel,
+		},
+	})
+	defer func() {
+		event := transcript.Event{Kind: transcript.KindRunEnd, Duration: time.Since(start)}
+		if err != nil {
+			event.Error = err.Error()
+		}
+		transcript.Record(ctx, event)
+	}()
+	var runUsage *usage
+	if s.agent.history != nil {
+		runUsage = &usage{}
+		ctx = transcript.NewContext(ctx, runUsage)
+	}
+
+	ctx, span := telemetry.Tracer().Start(ctx, "research", trace.WithAttributes(
+		attribute.String("research.query", question),
+		attribute.String("research.session_id", state.SessionID),
+		attribute.Int("research.turn", state.Turn),
+		attribute.String("research.style", state.ReportStyle),
+		attribute.String("research.locale", state.Locale),
+	))
+	telemetry.RunStarted()
+	defer func() {
+		span.SetAttributes(
+			attribute.Int("research.tool_calls", state.ToolCalls),
+			attribute.Bool("research.incomplete", state.Interruption != nil),
+		)
+		telemetry.End(span, err)
+		telemetry.RunFinished(runStatus(err), time.Since(start))
+	}()
+
+	output, err := s.agent.research(ctx, state, s.retriever)
+	if output == "" {
+		if err == nil {
+			err = ErrEmptyAnswer
+		}
+		return nil, err
+	}
+	result = state.Report
+	if result == nil {
+		// the coordinator answered directly, without research
+		result = &report.Report{Content: report.Content{Sections: []report.Section{{Body: output}}}}
+	}
+	s.agent.describe(result, question, state, time.Since(start))
+	transcript.Record(ctx, transcript.Event{Kind: transcript.KindReport, Content: result.Markdown()})
+	if s.agent.history != nil {
+		if err := s.agent.saveRun(ctx, state, state.Sources[firstSource:], result, runUsage, err); err != nil {
+			slog.Error("save run to history", "error", err)
+		}
+	}
+
+	// the answer is part of the conversation the next question refers to
+	state.Messages = append(state.Messages, llms.MessageContent{
+		Role:  llms.ChatMessageTypeAI,
+		Parts: []llms.ContentPart{llms.TextContent{Text: output}},
+	})
+	return result, err
+}
+
+// createTranscript creates the transcript of the question of state, named
+// after its session and turn.
+func (wf *Agent) createTranscript(state *AgentState) (*transcript.Transcript, error) {
+	if err := os.MkdirAll(wf.config.TranscriptDir, 0o755); err != nil {
+		return nil, err
+	}
+	t, err := transcript.Create(filepath.Join(wf.config.TranscriptDir, fmt.Sprintf("%s-%d.jsonl", state.SessionID, state.Turn)))
+	if err != nil {
+		return nil, err
+	}
+	state.Transcript = t.Path()
+	return t, nil
+}
+
+// runStatus classifies the result of a run for the metrics.
+func runStatus(err error) string {
+	switch {
+	case err == nil:
+		return telemetry.RunCompleted
+	case errors.Is(err, ErrIncomplete):
+		return telemetry.RunIncomplete
+	case errors.Is(err, ErrCanceled):
+		return telemetry.RunCanceled
+	default:
+		return telemetry.RunFailed
+	}
+}
+
+// newTurn resets the per-question state for question, keeping the messages,
+// plans and sources of the previous turns.
+func (state *AgentState) newTurn(question string, now time.Time) {
+	state.Turn++
+	state.Query = question
+	state.CurrentTime = now
+	state.Messages = append(state.Messages, llms.MessageContent{
+		Role:  llms.ChatMessageTypeHuman,
+		Parts: []llms.ContentPart{llms.TextContent{Text: question}},
+	})
+	state.PlanIterations = 0
+	state.ToolCalls = 0
+	state.Interruption = nil
+	state.Report = nil
+	state.Verified = false
+	state.Verification = nil
+	state.Critique = nil
+	state.Revisions = 0
+	state.Reviewed = false
+	state.Transcript = ""
+}
@@ -1,6 +1,12 @@
 package agent
 
-import "github.com/tmc/langchaingo/llms"
+import (
+	"slices"
+	"time"
+
+	"github.com/rickif/tiny-research/internal/report"
+	"github.com/tmc/langchaingo/llms"
+)
 
 const (
 	StepTypeReasearch  = "research"
@@ -9,17 +15,55 @@ const (
 
 type Step struct {
 	NeedSearch      bool   `json:"need_search"`
-	Title           string `json:"title"`
-	Description     string `json:"description"`
-	StepType        string `json:"step_type"`
+	Title           string `json:"title" validate:"required"`
+	Description     string `json:"description" validate:"required"`
+	StepType        string `json:"step_type" validate:"oneof=research processing"`
 	ExecutionResult string `json:"-"`
+	// Skipped is set when the step was skipped with Control.SkipStep.
+	Skipped bool `json:"-"`
 }
 
 type Plan struct {
 	HasEnoughContext bool   `json:"has_enough_context"`
 	Thought          string `json:"thought"`
-	Title            string `json:"title"`
-	Steps            []Step `json:"steps"`
+	Title            string `json:"title" validate:"required"`
+	Steps            []Step `json:"steps" validate:"dive"`
+}
+
+// Interruption records why a run stopped before every plan step was executed.
+type Interruption struct {
+	Step   string
+	Reason string
+}
+
+// Source is the raw output of a research tool call.
+type Source struct {
+	Tool    string
+	Input   string
+	Content string
+}
+
+// ClaimCheck is the verdict of the verifier on a claim of the findings.
+type ClaimCheck struct {
+	Claim       string   `json:"claim" validate:"required"`
+	Verdict     string   `json:"verdict" validate:"oneof=supported contradicted unverified"`
+	Explanation string   `json:"explanation"`
+	Sources     []string `json:"sources"`
+}
+
+const (
+	VerdictSupported    = "supported"
+	VerdictContradicted = "contradicted"
+	VerdictUnverified   = "unverified"
+)
+
+// Critique is the review of a draft report by the critic.
+type Critique struct {
+	Approved bool     `json:"approved"`
+	Issues   []string `json:"issues"`
+	// FollowUpSteps is research that fills the gaps of the draft; without
+	// it, the reporter revises the draft from the existing findings.
+	FollowUpSteps []Step `json:"follow_up_steps" validate:"dive"`
 }
 
 type AgentState struct {
@@ -28,13 +72,66 @@ type AgentState struct {
 	CurrentPlan    *Plan
 	PlanIterations int
 	Locale         string
+	SessionID      string
+	CurrentTime    time.Time
+	ToolCalls      int
+	MaxToolCalls   int
+	Interruption   *Interruption
+	ReportStyle    string
+	Report         *report.Report
+	Sources        []Source
+	Verified       bool
+	Verification   []ClaimCheck
+	Query          string
+	Turn           int
+	Critique       *Critique
+	Revisions      int
+	Reviewed       bool
+	// Transcript is the path of the transcript of the question, if any.
+	Transcript string
+
+	// MaxStepNum, MaxPlanIterations and PlannerInstructions constrain the
+	// planner; zero values keep the defaults.
+	MaxStepNum          int
+	MaxPlanIterations   int
+	PlannerInstructions string
+	// AllowedTools restricts the tools of the run by name, see ToolNames;
+	// nil allows every tool.
+	AllowedTools []string
+	// Control steers the run, if set, see WithControl.
+	Control *Control
 }
 
 const (
+	StepCoordinator  = "__coordinator__"
 	StepEnd          = "__end__"
 	StepPlanner      = "__planner__"
 	StepResearchTeam = "__research_team__"
 	StepReporter     = "__reporter__"
 	StepResearcher   = "__researcher__"
 	StepCoder        = "__coder__"
+	StepVerifier     = "__verifier__"
+	StepCritic       = "__critic__"
 )
+
+// executedSteps returns the plan steps executed so far. Once the planner has
+// enough context, they are in the last plan rather than the current one.
+func (state *AgentState) executedSteps() []Step {
+	var steps []Step
+	for _, plan := range []*Plan{state.LastPlan, state.CurrentPlan} {
+		if plan == nil {
+			continue
+		}
+		for _, step := range plan.Steps {
+			if step.ExecutionResult != "" {
+				steps = append(steps, step)
+			}
+		}
+	}
+	return steps
+}
+
+// toolAllowed reports whether the run may use the tool named name.
+func (state *AgentState) toolAllowed(name string) bool {
+	return state.AllowedTools == nil || slices.Contains(state.AllowedTools, name)
+}
@@ -0,0 +1,231 @@
+package agent
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"strings"
+	"time"
+
+	"github.com/rickif/tiny-research/internal/llm"
+	promptfiles "github.com/rickif/tiny-research/internal/prompts"
+	"github.com/rickif/tiny-research/internal/rag"
+	"github.com/rickif/tiny-research/internal/tool"
+	"github.com/tmc/langchaingo/llms"
+	"github.com/tmc/langchaingo/prompts"
+)
+
+var _ Node = (*Verifier)(nil)
+
+type claimSet struct {
+	Claims []string `json:"claims" validate:"dive,required"`
+}
+
+type verdictSet struct {
+	Verdicts []ClaimCheck `json:"verdicts" validate:"dive"`
+}
+
+// Verifier checks the key claims of the findings against the fetched
+// sources before the report is written.
+type Verifier struct {
+	llm        llms.Model
+	searcher   tool.Searcher
+	retriever  *tool.Retriever
+	maxClaims  int
+	strictJSON bool
+}
+
+// NewVerifier creates a verifier node checking at most maxClaims claims.
+// Evidence comes from retriever when non-nil, and from the raw sources in
+// the state otherwise; searcher runs a targeted search for claims the
+// sources do not settle.
+func NewVerifier(llm llms.Model, searcher tool.Searcher, retriever *tool.Retriever, maxClaims int, strictJSON bool) *Verifier {
+	return &Verifier{
+		llm:        llm,
+		searcher:   searcher,
+		retriever:  retriever,
+		maxClaims:  maxClaims,
+		strictJSON: strictJSON,
+	}
+}
+
+func (v *Verifier) Execute(ctx context.Context, state *AgentState) (nextStep string, output string, err error) {
+	slog.Info("verifier starts")
+	state.Verified = true
+
+	var findings []string
+	for _, step := range state.executedSteps() {
+		findings = append(findings, fmt.Sprintf("## %s\n\n%s", step.Title, step.ExecutionResult))
+	}
+	if len(findings) == 0 {
+		slog.Info("verifier ends", "claims", 0)
+		return StepReporter, "", nil
+	}
+
+	content, err := promptfiles.ReadFile("verifier.md")
+	if err != nil {
+		slog.Error("read verifier prompt file", "error", err)
+		return "", "", err
+	}
+	promptTemplate, err := prompts.NewPromptTemplate(string(content), []string{"current_time"}).Format(map[string]any{
+		"current_time": state.CurrentTime.Format(time.RFC3339),
+	})
+	if err != nil {
+		slog.Error("format verifier prompt", "error", err)
+		return "", "", err
+	}
+	system := llms.MessageContent{
+		Role:  llms.ChatMessageTypeSystem,
+		Parts: []llms.ContentPart{llms.TextContent{Text: promptTemplate}},
+	}
+
+	var claims claimSet
+	_, err = llm.GenerateJSON(ctx, v.llm, []llms.MessageContent{system, {
+		Role:  llms.ChatMessageTypeHuman,
+		Parts: []llms.ContentPart{llms.TextContent{Text: fmt.Sprintf("Extract at most %d key claims from these findings:\n\n%s", v.maxClaims, strings.Join(findings, "\n\n"))}},
+	}}, &claims, 3, v.strictJSON)
+	if err != nil {
+		slog.Error("extract claims", "error", err)
+		return "", "", err
+	}
+	if len(claims.Claims) > max(v.maxClaims, 0) {
+		claims.Claims = claims.Claims[:max(v.maxClaims, 0)]
+	}
+	if len(claims.Claims) == 0 {
+		slog.Info("verifier ends", "claims", 0)
+		return StepReporter, "", nil
+	}
+
+	// without the retrieve tool the evidence comes from the raw sources
+	retrieve := v.retriever != nil && state.toolAllowed(tool.RetrieveTool.Function.Name)
+	var index *rag.Index
+	if !retrieve {
+		var chunks []rag.Chunk
+		for _, source := range state.Sources {
+			chunks = append(chunks, rag.SplitLines(source.Input, source.Content, 1000, 2)...)
+		}
+		index = rag.NewIndex(chunks)
+	}
+	evidence := make([]string, len(claims.Claims))
+	for i, claim := range claims.Claims {
+		if evidence[i], err = v.evidence(ctx, index, claim); err != nil {
+			slog.Error("collect evidence", "error", err)
+			return "", "", err
+		}
+	}
+
+	checks, err := v.judge(ctx, system, claims.Claims, evidence)
+	if err != nil {
+		return "", "", err
+	}
+
+	// the sources do not settle unverified claims, so search for them
+	// directly, within the tool call budget
+	var retry []int
+	for i, check := range checks {
+		if check.Verdict != VerdictUnverified || !state.toolAllowed(tool.SearchTool.Function.Name) || (state.MaxToolCalls > 0 && state.ToolCalls >= state.MaxToolCalls) {
+			continue
+		}
+		state.ToolCalls++
+		result, err := v.searcher.Search(ctx, check.Claim)
+		if errors.Is(err, tool.ErrTimeout) {
+			slog.Warn("search claim timed out", "claim", check.Claim, "error", err)
+			continue
+		}
+		if err != nil {
+			slog.Error("search claim", "error", err)
+			return "", "", err
+		}
+		state.Sources = append(state.Sources, Source{Tool: "tavily_search", Input: check.Claim, Content: result})
+		evidence[i] += fmt.Sprintf("\n\n### Search results for the claim\n\n%s", result)
+		retry = append(retry, i)
+		slog.Info("verifier search claim", "claim", check.Claim)
+	}
+	if len(retry) > 0 {
+		retryClaims := make([]string, len(retry))
+		retryEvidence := make([]string, len(retry))
+		for j, i := range retry {
+			retryClaims[j], retryEvidence[j] = checks[i].Claim, evidence[i]
+		}
+		rechecks, err := v.judge(ctx, system, retryClaims, retryEvidence)
+		if err != nil {
+			return "", "", err
+		}
+		for j, i := range retry {
+			checks[i] = rechecks[j]
+		}
+	}
+
+	state.Verification = checks
+	output = verificationSummary(checks)
+	state.Messages = append(state.Messages, llms.MessageContent{
+		Role:  llms.ChatMessageTypeHuman,
+		Parts: []llms.ContentPart{llms.TextContent{Text: output}},
+	})
+	slog.Info("verifier ends", "claims", len(checks))
+	return StepReporter, output, nil
+}
+
+// evidence returns the source passages relevant to claim, from index when
+// non-nil and from the retriever otherwise.
+func (v *Verifier) evidence(ctx context.Context, index *rag.Index, claim string) (string, error) {
+	if index == nil {
+		return v.retriever.Retrieve(ctx, claim)
+	}
+	results := index.Search(claim, 3)
+	if len(results) == 0 {
+		return "no passages found in the fetched sources", nil
+	}
+	var passages []string
+	for _, result := range results {
+		passages = append(passages, fmt.Sprintf("Source: %s#L%d-L%d\n%s", result.Source, result.StartLine, result.EndLine, result.Text))
+	}
+	return strings.Join(passages, "\n\n"), nil
+}
+
+// judge asks for a verdict on every claim given its evidence. The verdicts
+// are returned in the order of claims.
+func (v *Verifier) judge(ctx context.Context, system llms.MessageContent, claims []string, evidence []string) ([]ClaimCheck, error) {
+	var b strings.Builder
+	b.WriteString("Judge each claim against its evidence. Return one verdict per claim, in the same order, repeating the claim verbatim.")
+	for i, claim := range claims {
+		fmt.Fprintf(&b, "\n\n# Claim %d\n\n%s\n\n## Evidence\n\n%s", i+1, claim, evidence[i])
+	}
+
+	var verdicts verdictSet
+	_, err := llm.GenerateJSON(ctx, v.llm, []llms.MessageContent{system, {
+		Role:  llms.ChatMessageTypeHuman,
+		Parts: []llms.ContentPart{llms.TextContent{Text: b.String()}},
+	}}, &verdicts, 3, v.strictJSON)
+	if err != nil {
+		slog.Error("judge claims", "error", err)
+		return nil, err
+	}
+
+	checks := make([]ClaimCheck, len(claims))
+	for i, claim := range claims {
+		checks[i] = ClaimCheck{Claim: claim, Verdict: VerdictUnverified, Explanation: "no verdict was returned"}
+		if i < len(verdicts.Verdicts) {
+			checks[i] = verdicts.Verdicts[i]
+			checks[i].Claim = claim
+		}
+	}
+	return checks, nil
+}
+
+func verificationSummary(checks []ClaimCheck) string {
+	var b strings.Builder
+	b.WriteString("# Claim Verification\n\nThe key claims of the findings were checked against the sources:\n")
+	for _, check := range checks {
+		fmt.Fprintf(&b, "\n- [%s] %s", strings.ToUpper(check.Verdict), check.Claim)
+		if check.Explanation != "" {
+			fmt.Fprintf(&b, " (%s", check.Explanation)
+			if len(check.Sources) > 0 {
+				fmt.Fprintf(&b, "; sources: %s", strings.Join(check.Sources, ", "))
+			}
+			b.WriteString(")")
+		}
+	}
+	return b.String()
+}
@@ -0,0 +1,42 @@
+package agent
+
+import (
+	"context"
+	"testing"
+
+	"github.com/rickif/tiny-research/internal/fake"
+)
+
+func TestVerifierSkipsJudgeWithoutClaims(t *testing.T) {
+	tests := []struct {
+		name      string
+		claims    string
+		maxClaims int
+	}{
+		{name: "no claims extracted", claims: `{"claims":[]}`, maxClaims: 10},
+		{name: "no claims allowed", claims: `{"claims":["Solid-state cells are denser."]}`, maxClaims: 0},
+		{name: "negative limit", claims: `{"claims":["Solid-state cells are denser."]}`, maxClaims: -1},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			step := researchStep("Density")
+			step.ExecutionResult = "Solid-state cells are denser."
+			state := newTestState(step)
+			model := fake.NewModel(fake.Text(tt.claims))
+
+			next, _, err := NewVerifier(model, &fake.Tool{}, nil, tt.maxClaims, false).Execute(context.Background(), state)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if next != StepReporter {
+				t.Errorf("next = %q, want %q", next, StepReporter)
+			}
+			if n := len(model.Calls()); n != 1 {
+				t.Errorf("%d model calls, want only the claim extraction", n)
+			}
+			if len(state.Verification) != 0 {
+				t.Errorf("verification = %+v, want none", state.Verification)
+			}
+		})
+	}
+}
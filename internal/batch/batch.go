@@ -0,0 +1,144 @@
+// Package batch researches a list of queries read from a CSV or JSONL file,
+// writing one report per query and an index of the results. Runs are
+// resumable: queries whose report is complete are skipped on rerun.
+package batch
+
+import (
+	"crypto/sha256"
+	"encoding/csv"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"unicode"
+)
+
+// Job is a query of a batch file. Locale and Style are optional, and ID is
+// derived from the other fields when the file does not set it.
+type Job struct {
+	ID     string `json:"id"`
+	Query  string `json:"query"`
+	Locale string `json:"locale"`
+	Style  string `json:"style"`
+}
+
+// Load reads the jobs of a batch file: a CSV file with a header row naming
+// its columns, or a JSONL file with one Job per line.
+func Load(path string) ([]Job, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	var jobs []Job
+	switch ext := strings.ToLower(filepath.Ext(path)); ext {
+	case ".csv":
+		jobs, err = loadCSV(f)
+	case ".jsonl", ".ndjson":
+		jobs, err = loadJSONL(f)
+	default:
+		return nil, fmt.Errorf("unknown batch file type %q, expected .csv or .jsonl", ext)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("load %s: %w", path, err)
+	}
+
+	ids := make(map[string]int, len(jobs))
+	for i := range jobs {
+		job := &jobs[i]
+		job.Query = strings.TrimSpace(job.Query)
+		if job.Query == "" {
+			return nil, fmt.Errorf("load %s: row %d has no query", path, i+1)
+		}
+		if job.ID == "" {
+			job.ID = jobID(*job)
+		}
+		if row, ok := ids[job.ID]; ok {
+			return nil, fmt.Errorf("load %s: rows %d and %d have the same id %q", path, row, i+1, job.ID)
+		}
+		ids[job.ID] = i + 1
+	}
+	return jobs, nil
+}
+
+func loadCSV(r io.Reader) ([]Job, error) {
+	reader := csv.NewReader(r)
+	reader.FieldsPerRecord = -1
+	reader.TrimLeadingSpace = true
+	header, err := reader.Read()
+	if err != nil {
+		return nil, fmt.Errorf("read header: %w", err)
+	}
+	columns := make(map[string]int, len(header))
+	for i, name := range header {
+		columns[strings.ToLower(strings.TrimSpace(name))] = i
+	}
+	if _, ok := columns["query"]; !ok {
+		return nil, errors.New("no query column in the header")
+	}
+	field := func(record []string, name string) string {
+		i, ok := columns[name]
+		if !ok || i >= len(record) {
+			return ""
+		}
+		return strings.TrimSpace(record[i])
+	}
+
+	var jobs []Job
+	for {
+		record, err := reader.Read()
+		if errors.Is(err, io.EOF) {
+			return jobs, nil
+		}
+		if err != nil {
+			return nil, err
+		}
+		jobs = append(jobs, Job{
+			ID:     field(record, "id"),
+			Query:  field(record, "query"),
+			Locale: field(record, "locale"),
+			Style:  field(record, "style"),
+		})
+	}
+}
+
+func loadJSONL(r io.Reader) ([]Job, error) {
+	var jobs []Job
+	decoder := json.NewDecoder(r)
+	for {
+		var job Job
+		if err := decoder.Decode(&job); errors.Is(err, io.EOF) {
+			return jobs, nil
+		} else if err != nil {
+			return nil, fmt.Errorf("row %d: %w", len(jobs)+1, err)
+		}
+		jobs = append(jobs, job)
+	}
+}
+
+// jobID names a job after its query, with a hash of its fields so that the
+// ID, and the report file named after it, stay the same across reruns even
+// when rows are reordered.
+func jobID(job Job) string {
+	var slug strings.Builder
+	dash := false
+	for _, c := range strings.ToLower(job.Query) {
+		if unicode.IsLetter(c) || unicode.IsDigit(c) {
+			slug.WriteRune(c)
+			dash = false
+		} else if !dash && slug.Len() > 0 {
+			slug.WriteByte('-')
+			dash = true
+		}
+		if len([]rune(slug.String())) >= 40 {
+			break
+		}
+	}
+	sum := sha256.Sum256([]byte(job.Query + "\x00" + job.Locale + "\x00" + job.Style))
+	return strings.TrimSuffix(slug.String(), "-") + "-" + hex.EncodeToString(sum[:4])
+}
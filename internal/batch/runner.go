@@ -0,0 +1,262 @@
+package batch
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/rickif/tiny-research/internal/agent"
+	"github.com/rickif/tiny-research/internal/report"
+	"github.com/rickif/tiny-research/util"
+)
+
+const (
+	StatusDone       = "done"
+	StatusIncomplete = "incomplete"
+	StatusFailed     = "failed"
+)
+
+// Researcher researches a single query, like agent.Agent.
+type Researcher interface {
+	Research(ctx context.Context, query string, options ...agent.ResearchOption) (*report.Report, error)
+}
+
+// Options configures a batch run.
+type Options struct {
+	// Dir receives the reports, named after the job IDs, and the index.
+	Dir string
+	// Concurrency is the number of queries researched at once.
+	Concurrency int
+	Format      report.Format
+	// ReportOptions configure the export of every report.
+	ReportOptions []report.Option
+}
+
+// Entry is the outcome of a job. Reports of incomplete and failed jobs are
+// researched again on rerun.
+type Entry struct {
+	Job
+	Status     string        `json:"status"`
+	Report     string        `json:"report,omitempty"`
+	Title      string        `json:"title,omitempty"`
+	Error      string        `json:"error,omitempty"`
+	Duration   time.Duration `json:"duration"`
+	ToolCalls  int           `json:"tool_calls"`
+	FinishedAt time.Time     `json:"finished_at"`
+}
+
+// Index lists the outcome of every job of a batch, in file order. It is
+// saved as index.json, which makes runs resumable, and index.md.
+type Index struct {
+	Entries []Entry `json:"entries"`
+}
+
+// Run researches the jobs that have no complete report in opts.Dir yet. The
+// index is saved after every job, so an interrupted run loses no finished
+// report. Failed jobs are recorded in the index rather than returned; the
+// error is only set when the run could not proceed or was canceled.
+func Run(ctx context.Context, researcher Researcher, jobs []Job, opts Options) (*Index, error) {
+	for _, job := range jobs {
+		if _, err := agent.LookupReportStyle(job.Style); err != nil {
+			return nil, fmt.Errorf("job %s: %w", job.ID, err)
+		}
+	}
+	if err := os.MkdirAll(opts.Dir, 0o755); err != nil {
+		return nil, err
+	}
+	previous, err := loadIndex(filepath.Join(opts.Dir, "index.json"))
+	if err != nil {
+		return nil, err
+	}
+
+	var (
+		mu      sync.Mutex
+		entries = make([]Entry, len(jobs))
+		pending []int
+	)
+	for i, job := range jobs {
+		// a job whose query, locale, style or format changed is researched
+		// again, since the report of its ID answers the old one
+		entry, ok := previous[job.ID]
+		if ok && entry.Status == StatusDone && entry.Job == job && entry.Report == reportName(job, opts.Format) && exists(filepath.Join(opts.Dir, entry.Report)) {
+			entries[i] = entry
+			slog.Info("skip completed query", "id", job.ID)
+			continue
+		}
+		entries[i] = Entry{Job: job}
+		pending = append(pending, i)
+	}
+	slog.Info("batch starts", "queries", len(jobs), "pending", len(pending), "concurrency", max(opts.Concurrency, 1))
+
+	var wg sync.WaitGroup
+	slots := make(chan struct{}, max(opts.Concurrency, 1))
+	for _, i := range pending {
+		select {
+		case slots <- struct{}{}:
+		case <-ctx.Done():
+		}
+		if ctx.Err() != nil {
+			break
+		}
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			defer func() { <-slots }()
+			entry := research(ctx, researcher, entries[i].Job, opts)
+
+			mu.Lock()
+			defer mu.Unlock()
+			entries[i] = entry
+			if err := saveIndex(opts.Dir, entries); err != nil {
+				slog.Error("save batch index", "error", err)
+			}
+		}(i)
+	}
+	wg.Wait()
+
+	index := &Index{Entries: entries}
+	if err := saveIndex(opts.Dir, entries); err != nil {
+		return index, err
+	}
+	done := 0
+	for _, entry := range entries {
+		if entry.Status == StatusDone {
+			done++
+		}
+	}
+	slog.Info("batch ends", "done", done, "queries", len(jobs))
+	return index, ctx.Err()
+}
+
+func research(ctx context.Context, researcher Researcher, job Job, opts Options) Entry {
+	slog.Info("research query", "id", job.ID, "query", job.Query)
+	start := time.Now()
+	var options []agent.ResearchOption
+	if job.Locale != "" {
+		options = append(options, agent.WithLocale(job.Locale))
+	}
+	if job.Style != "" {
+		options = append(options, agent.WithReportStyle(job.Style))
+	}
+	result, err := researcher.Research(ctx, job.Query, options...)
+
+	entry := Entry{Job: job, Status: StatusDone, Duration: time.Since(start), FinishedAt: time.Now()}
+	if err != nil {
+		slog.Error("research query", "id", job.ID, "error", err)
+		entry.Status, entry.Error = StatusFailed, err.Error()
+	}
+	if result == nil {
+		return entry
+	}
+	if err != nil {
+		entry.Status = StatusIncomplete
+	}
+	entry.Title = result.Title
+	entry.ToolCalls = result.Metadata.ToolCalls
+
+	entry.Report = reportName(job, opts.Format)
+	if err := util.WriteFile(filepath.Join(opts.Dir, entry.Report), func(f *os.File) error {
+		return result.Export(f, opts.Format, opts.ReportOptions...)
+	}); err != nil {
+		slog.Error("write report", "id", job.ID, "error", err)
+		entry.Status, entry.Error, entry.Report = StatusFailed, err.Error(), ""
+	}
+	return entry
+}
+
+// reportName returns the file name of the report of job in format.
+func reportName(job Job, format report.Format) string {
+	return job.ID + "." + string(format)
+}
+
+func loadIndex(path string) (map[string]Entry, error) {
+	b, err := os.ReadFile(path)
+	if errors.Is(err, os.ErrNotExist) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	var index Index
+	if err := json.Unmarshal(b, &index); err != nil {
+		return nil, fmt.Errorf("parse %s: %w", path, err)
+	}
+	entries := make(map[string]Entry, len(index.Entries))
+	for _, entry := range index.Entries {
+		entries[entry.ID] = entry
+	}
+	return entries, nil
+}
+
+// saveIndex writes index.json and its markdown rendering, index.md. Entries
+// of jobs not run yet are left out of both.
+func saveIndex(dir string, entries []Entry) error {
+	var index Index
+	for _, entry := range entries {
+		if entry.Status != "" {
+			index.Entries = append(index.Entries, entry)
+		}
+	}
+	if err := util.WriteFile(filepath.Join(dir, "index.json"), func(f *os.File) error {
+		encoder := json.NewEncoder(f)
+		encoder.SetEscapeHTML(false)
+		encoder.SetIndent("", "  ")
+		return encoder.Encode(index)
+	}); err != nil {
+		return err
+	}
+	return util.WriteFile(filepath.Join(dir, "index.md"), func(f *os.File) error {
+		_, err := f.WriteString(summary(entries).Markdown())
+		return err
+	})
+}
+
+// summary lays out the index as a report with a row per job.
+func summary(entries []Entry) *report.Report {
+	table := report.Table{Headers: []string{"#", "Query", "Status", "Report", "Duration", "Tool Calls"}}
+	counts := make(map[string]int)
+	for i, entry := range entries {
+		status, link := entry.Status, ""
+		if status == "" {
+			status = "pending"
+		}
+		counts[status]++
+		if entry.Report != "" {
+			title := entry.Title
+			if title == "" {
+				title = entry.Report
+			}
+			link = fmt.Sprintf("[%s](%s)", strings.NewReplacer("[", "\\[", "]", "\\]").Replace(title), entry.Report)
+		}
+		if entry.Error != "" {
+			status += ": " + entry.Error
+		}
+		table.Rows = append(table.Rows, []string{
+			strconv.Itoa(i + 1),
+			entry.Query,
+			status,
+			link,
+			entry.Duration.Round(time.Second).String(),
+			strconv.Itoa(entry.ToolCalls),
+		})
+	}
+	body := fmt.Sprintf("%d queries: %d done, %d incomplete, %d failed, %d pending.",
+		len(entries), counts[StatusDone], counts[StatusIncomplete], counts[StatusFailed], counts["pending"])
+	return &report.Report{Content: report.Content{
+		Title:    "Batch Research",
+		Sections: []report.Section{{Heading: "Results", Body: body, Tables: []report.Table{table}}},
+	}}
+}
+
+func exists(path string) bool {
+	_, err := os.Stat(path)
+	return err == nil
+}
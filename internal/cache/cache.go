@@ -0,0 +1,165 @@
+package cache
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/rickif/tiny-research/util"
+)
+
+const (
+	KindLLM    = "llm"
+	KindSearch = "search"
+	KindCrawl  = "crawl"
+)
+
+type entry struct {
+	CreatedAt time.Time       `json:"created_at"`
+	Value     json.RawMessage `json:"value"`
+}
+
+// Stat counts the lookups of one kind of cached call.
+type Stat struct {
+	Kind   string
+	Hits   int
+	Misses int
+}
+
+// HitRate returns the fraction of lookups served from the cache.
+func (s Stat) HitRate() float64 {
+	if s.Hits+s.Misses == 0 {
+		return 0
+	}
+	return float64(s.Hits) / float64(s.Hits+s.Misses)
+}
+
+// Cache is a content-addressed on-disk cache. Entries are stored under
+// dir/<kind>/<sha256 of key>.json and expire after the TTL of their kind.
+type Cache struct {
+	dir     string
+	ttls    map[string]time.Duration
+	refresh bool
+
+	mu    sync.Mutex
+	stats map[string]*Stat
+}
+
+// New creates a cache rooted at dir. A zero TTL means entries of that kind
+// never expire. When refresh is true lookups always miss but results are
+// still written, which refreshes stale entries.
+func New(dir string, ttls map[string]time.Duration, refresh bool) *Cache {
+	return &Cache{
+		dir:     dir,
+		ttls:    ttls,
+		refresh: refresh,
+		stats:   make(map[string]*Stat),
+	}
+}
+
+// Get loads the value stored for key into value and reports whether a fresh
+// entry was found.
+func (c *Cache) Get(kind string, key string, value any) bool {
+	hit := c.get(kind, key, value)
+	c.record(kind, hit)
+	return hit
+}
+
+func (c *Cache) get(kind string, key string, value any) bool {
+	if c.refresh {
+		return false
+	}
+	b, err := os.ReadFile(c.path(kind, key))
+	if err != nil {
+		if !errors.Is(err, os.ErrNotExist) {
+			slog.Warn("read cache entry", "kind", kind, "error", err)
+		}
+		return false
+	}
+
+	var e entry
+	if err := json.Unmarshal(b, &e); err != nil {
+		slog.Warn("decode cache entry", "kind", kind, "error", err)
+		return false
+	}
+	if ttl := c.ttls[kind]; ttl > 0 && time.Since(e.CreatedAt) > ttl {
+		return false
+	}
+	if err := json.Unmarshal(e.Value, value); err != nil {
+		slog.Warn("decode cache value", "kind", kind, "error", err)
+		return false
+	}
+	return true
+}
+
+// Put stores value for key. Failures are logged and otherwise ignored since
+// the cache is only an optimization.
+func (c *Cache) Put(kind string, key string, value any) {
+	raw, err := json.Marshal(value)
+	if err != nil {
+		slog.Warn("encode cache value", "kind", kind, "error", err)
+		return
+	}
+	b, err := json.Marshal(entry{CreatedAt: time.Now(), Value: raw})
+	if err != nil {
+		slog.Warn("encode cache entry", "kind", kind, "error", err)
+		return
+	}
+
+	path := c.path(kind, key)
+	if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+		slog.Warn("create cache dir", "kind", kind, "error", err)
+		return
+	}
+	// concurrent puts of one key each write their own temporary file
+	if err := util.WriteFile(path, func(f *os.File) error {
+		_, err := f.Write(b)
+		return err
+	}); err != nil {
+		slog.Warn("write cache entry", "kind", kind, "error", err)
+	}
+}
+
+// Stats returns the hit and miss counts per kind, sorted by kind.
+func (c *Cache) Stats() []Stat {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	stats := make([]Stat, 0, len(c.stats))
+	for _, stat := range c.stats {
+		stats = append(stats, *stat)
+	}
+	sort.Slice(stats, func(i, j int) bool {
+		return stats[i].Kind < stats[j].Kind
+	})
+	return stats
+}
+
+func (c *Cache) record(kind string, hit bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	stat, ok := c.stats[kind]
+	if !ok {
+		stat = &Stat{Kind: kind}
+		c.stats[kind] = stat
+	}
+	if hit {
+		stat.Hits++
+	} else {
+		stat.Misses++
+	}
+}
+
+func (c *Cache) path(kind string, key string) string {
+	sum := sha256.Sum256([]byte(kind + "\x00" + key))
+	name := hex.EncodeToString(sum[:])
+	return filepath.Join(c.dir, kind, name[:2], name+".json")
+}
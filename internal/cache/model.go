@@ -0,0 +1,50 @@
+package cache
+
+import (
+	"context"
+
+	"github.com/rickif/tiny-research/internal/llm"
+	"github.com/tmc/langchaingo/llms"
+)
+
+var _ llms.Model = (*Model)(nil)
+
+// Model wraps an llms.Model and caches GenerateContent responses keyed by the
+// model name, the base URL of the provider, the messages and call options.
+type Model struct {
+	model   llms.Model
+	cache   *Cache
+	name    string
+	baseURL string
+}
+
+// NewModel caches the responses of model, the model name served at baseURL.
+// The client is bound to both when it is created, so they are not part of
+// the call options and are added to the key here.
+func NewModel(model llms.Model, cache *Cache, name string, baseURL string) *Model {
+	return &Model{model: model, cache: cache, name: name, baseURL: baseURL}
+}
+
+func (m *Model) GenerateContent(ctx context.Context, messages []llms.MessageContent, options ...llms.CallOption) (*llms.ContentResponse, error) {
+	request, err := llm.EncodeRequest(messages, options)
+	if err != nil {
+		return nil, err
+	}
+	key := m.baseURL + "\n" + m.name + "\n" + request
+
+	var resp llm.Response
+	if m.cache.Get(KindLLM, key, &resp) {
+		return &resp.ContentResponse, nil
+	}
+
+	result, err := m.model.GenerateContent(ctx, messages, options...)
+	if err != nil {
+		return nil, err
+	}
+	m.cache.Put(KindLLM, key, result)
+	return result, nil
+}
+
+func (m *Model) Call(ctx context.Context, prompt string, options ...llms.CallOption) (string, error) {
+	return llms.GenerateFromSinglePrompt(ctx, m, prompt, options...)
+}
@@ -0,0 +1,58 @@
+package cache
+
+import (
+	"context"
+
+	"github.com/rickif/tiny-research/internal/tool"
+)
+
+var (
+	_ tool.Searcher = (*Searcher)(nil)
+	_ tool.Crawler  = (*Crawler)(nil)
+)
+
+// Searcher caches the results of a tool.Searcher by query.
+type Searcher struct {
+	searcher tool.Searcher
+	cache    *Cache
+}
+
+func NewSearcher(searcher tool.Searcher, cache *Cache) *Searcher {
+	return &Searcher{searcher: searcher, cache: cache}
+}
+
+func (s *Searcher) Search(ctx context.Context, query string) (string, error) {
+	var output string
+	if s.cache.Get(KindSearch, query, &output) {
+		return output, nil
+	}
+	output, err := s.searcher.Search(ctx, query)
+	if err != nil {
+		return "", err
+	}
+	s.cache.Put(KindSearch, query, output)
+	return output, nil
+}
+
+// Crawler caches the results of a tool.Crawler by URL.
+type Crawler struct {
+	crawler tool.Crawler
+	cache   *Cache
+}
+
+func NewCrawler(crawler tool.Crawler, cache *Cache) *Crawler {
+	return &Crawler{crawler: crawler, cache: cache}
+}
+
+func (c *Crawler) Crawl(ctx context.Context, url string) (string, error) {
+	var output string
+	if c.cache.Get(KindCrawl, url, &output) {
+		return output, nil
+	}
+	output, err := c.crawler.Crawl(ctx, url)
+	if err != nil {
+		return "", err
+	}
+	c.cache.Put(KindCrawl, url, output)
+	return output, nil
+}
@@ -3,6 +3,8 @@ package config
 import (
 	"fmt"
 	"os"
+	"strconv"
+	"time"
 
 	"github.com/joho/godotenv"
 )
@@ -11,8 +13,102 @@ type Config struct {
 	LLMModel   string
 	LLMBaseURL string
 	LLMToken   string
+	// LLMStructuredOutput enables strict JSON Schema constrained output,
+	// which is only supported by some providers.
+	LLMStructuredOutput bool
+	// LLMInputPrice and LLMOutputPrice are the prices of a million input and
+	// output tokens, used to show the cost of a run; zero hides it.
+	LLMInputPrice  float64
+	LLMOutputPrice float64
 
 	TavilyKey string
+
+	// LocalDocsDir is searched by local_search, which splits its documents
+	// into chunks of at most LocalDocsChunkSize characters sharing
+	// LocalDocsChunkOverlap lines and returns LocalDocsTopK of them.
+	LocalDocsDir          string
+	LocalDocsChunkSize    int
+	LocalDocsChunkOverlap int
+	LocalDocsTopK         int
+
+	EmbeddingModel string
+	VectorStoreDir string
+
+	CacheDir       string
+	CacheTTLLLM    time.Duration
+	CacheTTLSearch time.Duration
+	CacheTTLCrawl  time.Duration
+	NoCache        bool
+	RefreshCache   bool
+
+	RecordPath string
+	ReplayPath string
+
+	// MaxRetries is the number of retries of a failed LLM, search or crawl
+	// request. The concurrency and requests-per-minute limits are per
+	// provider; zero means unlimited.
+	MaxRetries           int
+	LLMMaxConcurrency    int
+	LLMRPM               int
+	SearchMaxConcurrency int
+	SearchRPM            int
+	CrawlMaxConcurrency  int
+	CrawlRPM             int
+
+	// NodeTimeout bounds each node execution and the tool timeouts bound
+	// each tool call; zero means no timeout.
+	NodeTimeout   time.Duration
+	SearchTimeout time.Duration
+	CrawlTimeout  time.Duration
+	PythonTimeout time.Duration
+
+	// MaxToolCalls is the tool call budget of a run; zero, the default,
+	// means unlimited.
+	// ReportTimeout bounds the report written from partial results after a
+	// failure or cancellation; zero means no timeout.
+	MaxToolCalls  int
+	ReportTimeout time.Duration
+
+	// VerifyClaims enables the fact-checking pass before reporting, which
+	// checks at most VerifyMaxClaims claims.
+	VerifyClaims    bool
+	VerifyMaxClaims int
+
+	// CritiqueReport enables the critic, which reviews the report and has it
+	// revised at most CritiqueMaxRevisions times.
+	CritiqueReport       bool
+	CritiqueMaxRevisions int
+
+	// TemplatesDir holds the research templates, see internal/templates.
+	TemplatesDir string
+
+	// ScheduleFile lists the scheduled research jobs, whose reports are
+	// stored under ScheduleDir.
+	ScheduleFile string
+	ScheduleDir  string
+
+	// TranscriptDir receives the transcript of every run, as JSONL and as an
+	// HTML timeline; empty disables transcripts.
+	TranscriptDir string
+
+	// HistoryDB is the SQLite database keeping every run for search and
+	// re-export; "off" disables the history.
+	HistoryDB string
+
+	// TraceExporter exports the OpenTelemetry spans of the runs: "otlp" to
+	// the collector of OTEL_EXPORTER_OTLP_ENDPOINT, "stdout", or "" for none.
+	TraceExporter string
+	// MetricsAddr is the listen address of the Prometheus /metrics endpoint,
+	// e.g. ":9090"; empty disables it.
+	MetricsAddr string
+
+	// ServeAddr is the listen address of the web UI, e.g. ":8080"; empty
+	// runs the research from the command line.
+	ServeAddr string
+
+	// ReportPDFFont is a TrueType font embedded in PDF reports, needed for
+	// text outside Western European scripts.
+	ReportPDFFont string
 }
 
 func LoadConfig() (Config, error) {
@@ -20,11 +116,216 @@ func LoadConfig() (Config, error) {
 		return Config{}, fmt.Errorf("failed to load env file: %w", err)
 	}
 
+	cacheTTLLLM, err := getDuration("CACHE_TTL_LLM", 7*24*time.Hour)
+	if err != nil {
+		return Config{}, err
+	}
+	cacheTTLSearch, err := getDuration("CACHE_TTL_SEARCH", 24*time.Hour)
+	if err != nil {
+		return Config{}, err
+	}
+	cacheTTLCrawl, err := getDuration("CACHE_TTL_CRAWL", 7*24*time.Hour)
+	if err != nil {
+		return Config{}, err
+	}
+
+	maxRetries, err := getInt("MAX_RETRIES", 3)
+	if err != nil {
+		return Config{}, err
+	}
+	llmMaxConcurrency, err := getInt("LLM_MAX_CONCURRENCY", 0)
+	if err != nil {
+		return Config{}, err
+	}
+	llmRPM, err := getInt("LLM_RPM", 0)
+	if err != nil {
+		return Config{}, err
+	}
+	searchMaxConcurrency, err := getInt("SEARCH_MAX_CONCURRENCY", 0)
+	if err != nil {
+		return Config{}, err
+	}
+	searchRPM, err := getInt("SEARCH_RPM", 0)
+	if err != nil {
+		return Config{}, err
+	}
+	crawlMaxConcurrency, err := getInt("CRAWL_MAX_CONCURRENCY", 0)
+	if err != nil {
+		return Config{}, err
+	}
+	crawlRPM, err := getInt("CRAWL_RPM", 0)
+	if err != nil {
+		return Config{}, err
+	}
+	nodeTimeout, err := getDuration("NODE_TIMEOUT", 10*time.Minute)
+	if err != nil {
+		return Config{}, err
+	}
+	searchTimeout, err := getDuration("SEARCH_TIMEOUT", 30*time.Second)
+	if err != nil {
+		return Config{}, err
+	}
+	crawlTimeout, err := getDuration("CRAWL_TIMEOUT", time.Minute)
+	if err != nil {
+		return Config{}, err
+	}
+	pythonTimeout, err := getDuration("PYTHON_TIMEOUT", 2*time.Minute)
+	if err != nil {
+		return Config{}, err
+	}
+	maxToolCalls, err := getInt("MAX_TOOL_CALLS", 0)
+	if err != nil {
+		return Config{}, err
+	}
+	reportTimeout, err := getDuration("REPORT_TIMEOUT", 3*time.Minute)
+	if err != nil {
+		return Config{}, err
+	}
+	verifyMaxClaims, err := getInt("VERIFY_MAX_CLAIMS", 10)
+	if err != nil {
+		return Config{}, err
+	}
+	if verifyMaxClaims < 0 {
+		return Config{}, fmt.Errorf("parse VERIFY_MAX_CLAIMS: %d is negative", verifyMaxClaims)
+	}
+	critiqueMaxRevisions, err := getInt("CRITIQUE_MAX_REVISIONS", 2)
+	if err != nil {
+		return Config{}, err
+	}
+	localDocsChunkSize, err := getInt("LOCAL_DOCS_CHUNK_SIZE", 1500)
+	if err != nil {
+		return Config{}, err
+	}
+	if localDocsChunkSize <= 0 {
+		return Config{}, fmt.Errorf("parse LOCAL_DOCS_CHUNK_SIZE: %d is not positive", localDocsChunkSize)
+	}
+	localDocsChunkOverlap, err := getInt("LOCAL_DOCS_CHUNK_OVERLAP", 3)
+	if err != nil {
+		return Config{}, err
+	}
+	if localDocsChunkOverlap < 0 {
+		return Config{}, fmt.Errorf("parse LOCAL_DOCS_CHUNK_OVERLAP: %d is negative", localDocsChunkOverlap)
+	}
+	localDocsTopK, err := getInt("LOCAL_DOCS_TOP_K", 5)
+	if err != nil {
+		return Config{}, err
+	}
+	if localDocsTopK <= 0 {
+		return Config{}, fmt.Errorf("parse LOCAL_DOCS_TOP_K: %d is not positive", localDocsTopK)
+	}
+
+	llmInputPrice, err := getFloat("LLM_INPUT_PRICE", 0)
+	if err != nil {
+		return Config{}, err
+	}
+	llmOutputPrice, err := getFloat("LLM_OUTPUT_PRICE", 0)
+	if err != nil {
+		return Config{}, err
+	}
+
 	return Config{
 		LLMModel:   os.Getenv("LLM_MODEL"),
 		LLMBaseURL: os.Getenv("LLM_BASE_URL"),
 		LLMToken:   os.Getenv("LLM_TOKEN"),
 
+		LLMStructuredOutput: os.Getenv("LLM_STRUCTURED_OUTPUT") == "true",
+		LLMInputPrice:       llmInputPrice,
+		LLMOutputPrice:      llmOutputPrice,
+
 		TavilyKey: os.Getenv("TAVILY_KEY"),
+
+		LocalDocsDir:          os.Getenv("LOCAL_DOCS_DIR"),
+		LocalDocsChunkSize:    localDocsChunkSize,
+		LocalDocsChunkOverlap: localDocsChunkOverlap,
+		LocalDocsTopK:         localDocsTopK,
+
+		EmbeddingModel: os.Getenv("EMBEDDING_MODEL"),
+		VectorStoreDir: getEnv("VECTOR_STORE_DIR", "./sessions"),
+
+		CacheDir:       getEnv("CACHE_DIR", "./.cache"),
+		CacheTTLLLM:    cacheTTLLLM,
+		CacheTTLSearch: cacheTTLSearch,
+		CacheTTLCrawl:  cacheTTLCrawl,
+
+		MaxRetries:           maxRetries,
+		LLMMaxConcurrency:    llmMaxConcurrency,
+		LLMRPM:               llmRPM,
+		SearchMaxConcurrency: searchMaxConcurrency,
+		SearchRPM:            searchRPM,
+		CrawlMaxConcurrency:  crawlMaxConcurrency,
+		CrawlRPM:             crawlRPM,
+
+		NodeTimeout:   nodeTimeout,
+		SearchTimeout: searchTimeout,
+		CrawlTimeout:  crawlTimeout,
+		PythonTimeout: pythonTimeout,
+
+		MaxToolCalls:  maxToolCalls,
+		ReportTimeout: reportTimeout,
+
+		VerifyClaims:    os.Getenv("VERIFY_CLAIMS") == "true",
+		VerifyMaxClaims: verifyMaxClaims,
+
+		CritiqueReport:       os.Getenv("CRITIQUE_REPORT") == "true",
+		CritiqueMaxRevisions: critiqueMaxRevisions,
+
+		TemplatesDir: getEnv("TEMPLATES_DIR", "./templates"),
+
+		ScheduleFile: getEnv("SCHEDULE_FILE", "./schedule.yaml"),
+		ScheduleDir:  getEnv("SCHEDULE_DIR", "./monitor"),
+
+		TranscriptDir: os.Getenv("TRANSCRIPT_DIR"),
+
+		HistoryDB: getEnv("HISTORY_DB", "./history.db"),
+
+		TraceExporter: os.Getenv("TRACE_EXPORTER"),
+		MetricsAddr:   os.Getenv("METRICS_ADDR"),
+
+		ServeAddr: os.Getenv("SERVE_ADDR"),
+
+		ReportPDFFont: os.Getenv("REPORT_PDF_FONT"),
 	}, nil
 }
+
+func getEnv(key string, fallback string) string {
+	if value := os.Getenv(key); value != "" {
+		return value
+	}
+	return fallback
+}
+
+func getDuration(key string, fallback time.Duration) (time.Duration, error) {
+	value := os.Getenv(key)
+	if value == "" {
+		return fallback, nil
+	}
+	d, err := time.ParseDuration(value)
+	if err != nil {
+		return 0, fmt.Errorf("parse %s: %w", key, err)
+	}
+	return d, nil
+}
+
+func getInt(key string, fallback int) (int, error) {
+	value := os.Getenv(key)
+	if value == "" {
+		return fallback, nil
+	}
+	n, err := strconv.Atoi(value)
+	if err != nil {
+		return 0, fmt.Errorf("parse %s: %w", key, err)
+	}
+	return n, nil
+}
+
+func getFloat(key string, fallback float64) (float64, error) {
+	value := os.Getenv(key)
+	if value == "" {
+		return fallback, nil
+	}
+	f, err := strconv.ParseFloat(value, 64)
+	if err != nil {
+		return 0, fmt.Errorf("parse %s: %w", key, err)
+	}
+	return f, nil
+}
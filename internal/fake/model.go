@@ -0,0 +1,116 @@
+// Package fake provides scripted stand-ins for the LLM and the research tools
+// so that agent nodes can be exercised without network access.
+package fake
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync"
+
+	"github.com/tmc/langchaingo/llms"
+)
+
+// ErrScriptExhausted is returned when the model is called more often than it
+// has scripted responses.
+var ErrScriptExhausted = errors.New("fake: no scripted response left")
+
+var _ llms.Model = (*Model)(nil)
+
+// Response is one scripted reply of a Model.
+type Response struct {
+	Content   string
+	ToolCalls []llms.ToolCall
+	Err       error
+}
+
+// Text scripts a plain text reply. It is also how malformed JSON is scripted.
+func Text(content string) Response {
+	return Response{Content: content}
+}
+
+// ToolCall scripts a reply that invokes the named tool with JSON arguments.
+func ToolCall(name string, arguments string) Response {
+	return ToolCalls(llms.ToolCall{
+		Type:         "function",
+		FunctionCall: &llms.FunctionCall{Name: name, Arguments: arguments},
+	})
+}
+
+// ToolCalls scripts a reply that invokes several tools at once. Missing call
+// IDs are filled in.
+func ToolCalls(calls ...llms.ToolCall) Response {
+	return Response{ToolCalls: calls}
+}
+
+// Error scripts a failed call.
+func Error(err error) Response {
+	return Response{Err: err}
+}
+
+// Call is a request received by a Model.
+type Call struct {
+	Messages []llms.MessageContent
+	Options  llms.CallOptions
+}
+
+// Model is an llms.Model that returns scripted responses in order and keeps
+// every request it receives.
+type Model struct {
+	mu        sync.Mutex
+	responses []Response
+	calls     []Call
+}
+
+func NewModel(responses ...Response) *Model {
+	return &Model{responses: responses}
+}
+
+func (m *Model) GenerateContent(ctx context.Context, messages []llms.MessageContent, options ...llms.CallOption) (*llms.ContentResponse, error) {
+	var opts llms.CallOptions
+	for _, option := range options {
+		option(&opts)
+	}
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.calls = append(m.calls, Call{Messages: messages, Options: opts})
+	if len(m.responses) == 0 {
+		return nil, ErrScriptExhausted
+	}
+	resp := m.responses[0]
+	m.responses = m.responses[1:]
+	if resp.Err != nil {
+		return nil, resp.Err
+	}
+
+	choice := &llms.ContentChoice{Content: resp.Content}
+	for i, call := range resp.ToolCalls {
+		if call.ID == "" {
+			call.ID = fmt.Sprintf("call_%d_%d", len(m.calls), i)
+		}
+		choice.ToolCalls = append(choice.ToolCalls, call)
+	}
+	if len(choice.ToolCalls) > 0 {
+		choice.FuncCall = choice.ToolCalls[0].FunctionCall
+	}
+	return &llms.ContentResponse{Choices: []*llms.ContentChoice{choice}}, nil
+}
+
+func (m *Model) Call(ctx context.Context, prompt string, options ...llms.CallOption) (string, error) {
+	return llms.GenerateFromSinglePrompt(ctx, m, prompt, options...)
+}
+
+// Calls returns the requests received so far.
+func (m *Model) Calls() []Call {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	return append([]Call(nil), m.calls...)
+}
+
+// Remaining returns the number of scripted responses not used yet.
+func (m *Model) Remaining() int {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	return len(m.responses)
+}
@@ -0,0 +1,63 @@
+package fake
+
+import (
+	"context"
+	"fmt"
+	"sync"
+
+	"github.com/rickif/tiny-research/internal/tool"
+)
+
+var (
+	_ tool.Searcher     = (*Tool)(nil)
+	_ tool.Crawler      = (*Tool)(nil)
+	_ tool.PythonRunner = (*Tool)(nil)
+)
+
+// Tool is a fake search, crawl or python tool. It answers each input from
+// Outputs, falling back to Default, and fails with Err when set. Every input
+// it receives is kept in order.
+type Tool struct {
+	Outputs map[string]string
+	Default string
+	Err     error
+
+	mu     sync.Mutex
+	inputs []string
+}
+
+func (t *Tool) Search(ctx context.Context, query string) (string, error) {
+	return t.call(query)
+}
+
+func (t *Tool) Crawl(ctx context.Context, url string) (string, error) {
+	return t.call(url)
+}
+
+func (t *Tool) Run(ctx context.Context, code string) (string, error) {
+	return t.call(code)
+}
+
+// Inputs returns the inputs received so far.
+func (t *Tool) Inputs() []string {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	return append([]string(nil), t.inputs...)
+}
+
+func (t *Tool) call(input string) (string, error) {
+	t.mu.Lock()
+	t.inputs = append(t.inputs, input)
+	t.mu.Unlock()
+
+	if t.Err != nil {
+		return "", t.Err
+	}
+	if output, ok := t.Outputs[input]; ok {
+		return output, nil
+	}
+	if t.Default != "" {
+		return t.Default, nil
+	}
+	return "", fmt.Errorf("fake: no output for %q", input)
+}
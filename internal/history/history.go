@@ -0,0 +1,391 @@
+// Package history keeps the research runs in an embedded SQLite database:
+// the report with its query, plan and findings, the full content of every
+// source, and the metrics of the run. The reports, findings and sources are
+// indexed for full-text search.
+package history
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/rickif/tiny-research/internal/report"
+	_ "modernc.org/sqlite"
+)
+
+// ErrNotFound is returned for an ID that matches no run.
+var ErrNotFound = errors.New("run not found")
+
+const schema = `
+CREATE TABLE IF NOT EXISTS runs (
+	id            TEXT PRIMARY KEY,
+	query         TEXT NOT NULL,
+	title         TEXT NOT NULL,
+	style         TEXT NOT NULL,
+	locale        TEXT NOT NULL,
+	model         TEXT NOT NULL,
+	status        TEXT NOT NULL,
+	error         TEXT NOT NULL,
+	created_at    INTEGER NOT NULL,
+	duration_ms   INTEGER NOT NULL,
+	tool_calls    INTEGER NOT NULL,
+	llm_calls     INTEGER NOT NULL,
+	input_tokens  INTEGER NOT NULL,
+	output_tokens INTEGER NOT NULL,
+	report        TEXT NOT NULL
+);
+CREATE INDEX IF NOT EXISTS runs_created_at ON runs (created_at);
+CREATE TABLE IF NOT EXISTS sources (
+	run_id  TEXT NOT NULL REFERENCES runs (id) ON DELETE CASCADE,
+	seq     INTEGER NOT NULL,
+	tool    TEXT NOT NULL,
+	input   TEXT NOT NULL,
+	content TEXT NOT NULL,
+	PRIMARY KEY (run_id, seq)
+);
+CREATE VIRTUAL TABLE IF NOT EXISTS search USING fts5 (
+	run_id UNINDEXED,
+	kind UNINDEXED,
+	label,
+	body
+);
+`
+
+// What a search hit is in.
+const (
+	KindReport  = "report"
+	KindFinding = "finding"
+	KindSource  = "source"
+)
+
+// Run is a research run as stored.
+type Run struct {
+	ID string `json:"id"`
+	// Status is completed, incomplete or canceled, and Error the error the
+	// run ended with, if any.
+	Status string `json:"status"`
+	Error  string `json:"error,omitempty"`
+	Locale string `json:"locale,omitempty"`
+	// LLMCalls, InputTokens and OutputTokens are the model usage of the run;
+	// its duration and tool calls are in the report metadata.
+	LLMCalls     int `json:"llm_calls"`
+	InputTokens  int `json:"input_tokens"`
+	OutputTokens int `json:"output_tokens"`
+	// Report holds the query, the plan with the findings of each step, and
+	// the list of sources in its metadata.
+	Report  *report.Report `json:"report"`
+	Sources []Source       `json:"sources,omitempty"`
+}
+
+// Source is the full output of a tool call of a run.
+type Source struct {
+	Tool    string `json:"tool"`
+	Input   string `json:"input"`
+	Content string `json:"content"`
+}
+
+// Summary describes a run in a list.
+type Summary struct {
+	ID           string        `json:"id"`
+	Query        string        `json:"query"`
+	Title        string        `json:"title"`
+	Style        string        `json:"style"`
+	Status       string        `json:"status"`
+	CreatedAt    time.Time     `json:"created_at"`
+	Duration     time.Duration `json:"duration"`
+	ToolCalls    int           `json:"tool_calls"`
+	LLMCalls     int           `json:"llm_calls"`
+	InputTokens  int           `json:"input_tokens"`
+	OutputTokens int           `json:"output_tokens"`
+}
+
+// Match is a run found by a search, with the passages that matched.
+type Match struct {
+	Summary
+	Snippets []Snippet `json:"snippets"`
+}
+
+// Snippet is a passage of a run that matched a search, with the matched
+// terms between [ and ].
+type Snippet struct {
+	// Kind is KindReport, KindFinding or KindSource, and Label the query of
+	// the report, the title of the step, or the input of the source.
+	Kind  string `json:"kind"`
+	Label string `json:"label"`
+	Text  string `json:"text"`
+}
+
+// maxSnippets bounds the snippets of a Match.
+const maxSnippets = 3
+
+// Store is a history database.
+type Store struct {
+	db *sql.DB
+}
+
+// Open opens the database at path, creating it if needed.
+func Open(path string) (*Store, error) {
+	if dir := filepath.Dir(path); dir != "" {
+		if err := os.MkdirAll(dir, 0o755); err != nil {
+			return nil, err
+		}
+	}
+	db, err := sql.Open("sqlite", "file:"+path+"?_pragma=foreign_keys(1)&_pragma=busy_timeout(5000)&_pragma=journal_mode(WAL)")
+	if err != nil {
+		return nil, err
+	}
+	// concurrent runs save one at a time rather than failing on a busy
+	// database
+	db.SetMaxOpenConns(1)
+	if _, err := db.Exec(schema); err != nil {
+		db.Close()
+		return nil, fmt.Errorf("create history schema in %s: %w", path, err)
+	}
+	return &Store{db: db}, nil
+}
+
+func (s *Store) Close() error {
+	return s.db.Close()
+}
+
+// Save stores run and indexes its report, findings and sources.
+func (s *Store) Save(ctx context.Context, run *Run) (err error) {
+	b, err := json.Marshal(run.Report)
+	if err != nil {
+		return err
+	}
+	metadata := run.Report.Metadata
+
+	tx, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer func() {
+		if err != nil {
+			tx.Rollback()
+		}
+	}()
+
+	if _, err = tx.ExecContext(ctx, `INSERT INTO runs VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?)`,
+		run.ID, metadata.Query, run.Report.Title, metadata.Style, run.Locale, metadata.Model, run.Status, run.Error,
+		metadata.CreatedAt.UnixMilli(), metadata.Duration.Milliseconds(), metadata.ToolCalls,
+		run.LLMCalls, run.InputTokens, run.OutputTokens, string(b)); err != nil {
+		return fmt.Errorf("save run %s: %w", run.ID, err)
+	}
+	index := func(kind string, label string, body string) error {
+		_, err := tx.ExecContext(ctx, `INSERT INTO search (run_id, kind, label, body) VALUES (?, ?, ?, ?)`, run.ID, kind, label, body)
+		return err
+	}
+	if err = index(KindReport, metadata.Query, run.Report.Markdown()); err != nil {
+		return fmt.Errorf("index run %s: %w", run.ID, err)
+	}
+	if plan := metadata.Plan; plan != nil {
+		for _, step := range plan.Steps {
+			if step.Result == "" {
+				continue
+			}
+			if err = index(KindFinding, step.Title, step.Result); err != nil {
+				return fmt.Errorf("index run %s: %w", run.ID, err)
+			}
+		}
+	}
+	for i, source := range run.Sources {
+		if _, err = tx.ExecContext(ctx, `INSERT INTO sources VALUES (?, ?, ?, ?, ?)`, run.ID, i, source.Tool, source.Input, source.Content); err != nil {
+			return fmt.Errorf("save sources of run %s: %w", run.ID, err)
+		}
+		if err = index(KindSource, source.Input, source.Content); err != nil {
+			return fmt.Errorf("index run %s: %w", run.ID, err)
+		}
+	}
+	return tx.Commit()
+}
+
+const summaryColumns = `id, query, title, style, status, created_at, duration_ms, tool_calls, llm_calls, input_tokens, output_tokens`
+
+// List returns the latest runs, newest first; limit <= 0 lists them all.
+func (s *Store) List(ctx context.Context, limit int) ([]Summary, error) {
+	if limit <= 0 {
+		limit = -1
+	}
+	rows, err := s.db.QueryContext(ctx, `SELECT `+summaryColumns+` FROM runs ORDER BY created_at DESC LIMIT ?`, limit)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	summaries := []Summary{}
+	for rows.Next() {
+		summary, err := scanSummary(rows)
+		if err != nil {
+			return nil, err
+		}
+		summaries = append(summaries, summary)
+	}
+	return summaries, rows.Err()
+}
+
+// Search returns the runs whose query, report, or one of whose findings or
+// sources, contains every word of keywords, best matches first: the words
+// must all match in the same document, not across documents of a run.
+// limit <= 0 returns them all.
+func (s *Store) Search(ctx context.Context, keywords string, limit int) ([]Match, error) {
+	query := matchQuery(keywords)
+	if query == "" {
+		return nil, fmt.Errorf("no keywords to search for")
+	}
+	rows, err := s.db.QueryContext(ctx, `SELECT run_id, kind, label, snippet(search, -1, '[', ']', '…', 16) FROM search WHERE search MATCH ? ORDER BY rank`, query)
+	if err != nil {
+		return nil, fmt.Errorf("search history: %w", err)
+	}
+	defer rows.Close()
+
+	var matches []Match
+	found := make(map[string]int)
+	for rows.Next() {
+		var id string
+		var snippet Snippet
+		if err := rows.Scan(&id, &snippet.Kind, &snippet.Label, &snippet.Text); err != nil {
+			return nil, err
+		}
+		i, ok := found[id]
+		if !ok {
+			if limit > 0 && len(matches) == limit {
+				continue
+			}
+			i = len(matches)
+			found[id] = i
+			matches = append(matches, Match{Summary: Summary{ID: id}})
+		}
+		if len(matches[i].Snippets) < maxSnippets {
+			matches[i].Snippets = append(matches[i].Snippets, snippet)
+		}
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	rows.Close()
+
+	for i := range matches {
+		row := s.db.QueryRowContext(ctx, `SELECT `+summaryColumns+` FROM runs WHERE id = ?`, matches[i].ID)
+		if matches[i].Summary, err = scanSummary(row); err != nil {
+			return nil, err
+		}
+	}
+	if matches == nil {
+		matches = []Match{}
+	}
+	return matches, nil
+}
+
+// Get returns the run whose ID is id or, when unambiguous, starts with id.
+func (s *Store) Get(ctx context.Context, id string) (*Run, error) {
+	id, err := s.resolve(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+	run := &Run{ID: id}
+	var b string
+	if err := s.db.QueryRowContext(ctx, `SELECT status, error, locale, llm_calls, input_tokens, output_tokens, report FROM runs WHERE id = ?`, id).
+		Scan(&run.Status, &run.Error, &run.Locale, &run.LLMCalls, &run.InputTokens, &run.OutputTokens, &b); err != nil {
+		return nil, err
+	}
+	if err := json.Unmarshal([]byte(b), &run.Report); err != nil {
+		return nil, fmt.Errorf("decode report of run %s: %w", id, err)
+	}
+
+	rows, err := s.db.QueryContext(ctx, `SELECT tool, input, content FROM sources WHERE run_id = ? ORDER BY seq`, id)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	for rows.Next() {
+		var source Source
+		if err := rows.Scan(&source.Tool, &source.Input, &source.Content); err != nil {
+			return nil, err
+		}
+		run.Sources = append(run.Sources, source)
+	}
+	return run, rows.Err()
+}
+
+// Delete deletes the run whose ID is id or, when unambiguous, starts with
+// id, and returns its full ID.
+func (s *Store) Delete(ctx context.Context, id string) (string, error) {
+	id, err := s.resolve(ctx, id)
+	if err != nil {
+		return "", err
+	}
+	tx, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		return "", err
+	}
+	defer tx.Rollback()
+	// the sources go with the run
+	for _, statement := range []string{`DELETE FROM search WHERE run_id = ?`, `DELETE FROM runs WHERE id = ?`} {
+		if _, err := tx.ExecContext(ctx, statement, id); err != nil {
+			return "", fmt.Errorf("delete run %s: %w", id, err)
+		}
+	}
+	return id, tx.Commit()
+}
+
+// resolve returns the ID of the run whose ID starts with prefix.
+func (s *Store) resolve(ctx context.Context, prefix string) (string, error) {
+	prefix = strings.TrimSpace(prefix)
+	if prefix == "" {
+		return "", ErrNotFound
+	}
+	rows, err := s.db.QueryContext(ctx, `SELECT id FROM runs WHERE substr(id, 1, length(?)) = ? LIMIT 2`, prefix, prefix)
+	if err != nil {
+		return "", err
+	}
+	defer rows.Close()
+	var ids []string
+	for rows.Next() {
+		var id string
+		if err := rows.Scan(&id); err != nil {
+			return "", err
+		}
+		ids = append(ids, id)
+	}
+	if err := rows.Err(); err != nil {
+		return "", err
+	}
+	switch len(ids) {
+	case 0:
+		return "", fmt.Errorf("%w: %s", ErrNotFound, prefix)
+	case 1:
+		return ids[0], nil
+	}
+	return "", fmt.Errorf("run ID %s is ambiguous", prefix)
+}
+
+type scanner interface {
+	Scan(dest ...any) error
+}
+
+func scanSummary(row scanner) (Summary, error) {
+	var summary Summary
+	var createdAt, duration int64
+	err := row.Scan(&summary.ID, &summary.Query, &summary.Title, &summary.Style, &summary.Status, &createdAt, &duration,
+		&summary.ToolCalls, &summary.LLMCalls, &summary.InputTokens, &summary.OutputTokens)
+	summary.CreatedAt = time.UnixMilli(createdAt)
+	summary.Duration = time.Duration(duration) * time.Millisecond
+	return summary, err
+}
+
+// matchQuery turns keywords into an FTS5 query matching every word, so
+// punctuation in them is not taken as query syntax.
+func matchQuery(keywords string) string {
+	var terms []string
+	for _, word := range strings.Fields(keywords) {
+		terms = append(terms, `"`+strings.ReplaceAll(word, `"`, `""`)+`"`)
+	}
+	return strings.Join(terms, " ")
+}
@@ -5,35 +5,151 @@ import (
 	"encoding/json"
 	"fmt"
 	"log/slog"
+	"reflect"
+	"strings"
 
 	"github.com/go-playground/validator/v10"
 	"github.com/rickif/tiny-research/util"
 	"github.com/tmc/langchaingo/llms"
 )
 
-func GenerateJSON(ctx context.Context, llm llms.Model, messages []llms.MessageContent, result any, maxRetries int, options ...llms.CallOption) (output string, err error) {
-	var resp *llms.ContentResponse
+// GenerateJSON asks llm for a JSON document and decodes it into result, which
+// must be a pointer to a struct. The JSON Schema derived from result is given
+// to the model; with strict set it is enforced by the provider through a
+// strict function call, otherwise JSON mode is used. When the output fails to
+// decode or validate, the error is fed back to the model as a repair request
+// on the next attempt.
+func GenerateJSON(ctx context.Context, llm llms.Model, messages []llms.MessageContent, result any, maxRetries int, strict bool, options ...llms.CallOption) (output string, err error) {
+	schema, err := Schema(result, strict)
+	if err != nil {
+		return "", err
+	}
+	name := strings.ToLower(reflect.TypeOf(result).Elem().Name())
+
+	messages = append([]llms.MessageContent(nil), messages...)
+	if strict {
+		options = append(options,
+			llms.WithTools([]llms.Tool{{
+				Type: "function",
+				Function: &llms.FunctionDefinition{
+					Name:        name,
+					Description: "Return the " + name + " as structured data.",
+					Parameters:  schema,
+					Strict:      true,
+				},
+			}}),
+			llms.WithToolChoice(llms.ToolChoice{Type: "function", Function: &llms.FunctionReference{Name: name}}),
+		)
+	} else {
+		b, _ := json.Marshal(schema)
+		messages = append(messages, llms.MessageContent{
+			Role:  llms.ChatMessageTypeSystem,
+			Parts: []llms.ContentPart{llms.TextContent{Text: fmt.Sprintf("Respond with a single JSON object that conforms to this JSON Schema:\n\n%s", b)}},
+		})
+		options = append(options, llms.WithJSONMode())
+	}
+
+	validate := validator.New()
 	for i := 0; i < maxRetries; i++ {
-		resp, err = llm.GenerateContent(ctx, messages, append(options, llms.WithJSONMode())...)
-		if err != nil {
-			slog.Error("generate from single prompt", "error", err)
+		resp, genErr := llm.GenerateContent(ctx, messages, options...)
+		if genErr != nil {
+			err = genErr
+			slog.Error("generate json", "error", err)
+			if ctx.Err() != nil {
+				break
+			}
+			continue
+		}
+		if len(resp.Choices) == 0 {
+			err = fmt.Errorf("empty response")
 			continue
 		}
-		output = resp.Choices[0].Content
 
-		output = util.FixJSON(output)
+		choice := resp.Choices[0]
+		output = choice.Content
+		if strict && len(choice.ToolCalls) > 0 {
+			output = choice.ToolCalls[0].FunctionCall.Arguments
+		}
+		var repairs []string
+		output, repairs = util.FixJSON(output)
+		if len(repairs) > 0 {
+			slog.Info("repaired json", "repairs", repairs)
+		}
 
+		reflect.ValueOf(result).Elem().SetZero()
 		if err = json.Unmarshal([]byte(output), result); err != nil {
 			slog.Error("unmarshal json", "error", err)
-			continue
+			err = fmt.Errorf("invalid JSON: %w", err)
+		} else if err = validate.Struct(result); err != nil {
+			slog.Error("validate json", "error", err)
+			err = fmt.Errorf("JSON does not satisfy the schema: %w", err)
+		} else {
+			return output, nil
 		}
 
-		validator := validator.New()
-		if err = validator.Struct(result); err != nil {
-			slog.Error("validate json", "error", err)
+		messages = append(messages,
+			llms.MessageContent{
+				Role:  llms.ChatMessageTypeAI,
+				Parts: []llms.ContentPart{llms.TextContent{Text: output}},
+			},
+			llms.MessageContent{
+				Role:  llms.ChatMessageTypeHuman,
+				Parts: []llms.ContentPart{llms.TextContent{Text: fmt.Sprintf("Your previous response could not be used: %v\n\nFix this problem and reply with the corrected JSON object only.", err)}},
+			},
+		)
+	}
+	return "", fmt.Errorf("generate json: %w", err)
+}
+
+// EncodeRequest serializes messages and the resolved call options into a
+// stable string that identifies a GenerateContent request.
+func EncodeRequest(messages []llms.MessageContent, options []llms.CallOption) (string, error) {
+	var opts llms.CallOptions
+	for _, option := range options {
+		option(&opts)
+	}
+	b, err := json.Marshal(struct {
+		Messages []llms.MessageContent `json:"messages"`
+		Options  llms.CallOptions      `json:"options"`
+	}{messages, opts})
+	if err != nil {
+		return "", fmt.Errorf("encode llm request: %w", err)
+	}
+	return string(b), nil
+}
+
+// Response is an llms.ContentResponse that survives a JSON round trip:
+// llms.ToolCall encodes its function call but drops it when decoded, so the
+// function calls of a recorded or cached response are restored here.
+type Response struct {
+	llms.ContentResponse
+}
+
+func (r *Response) UnmarshalJSON(data []byte) error {
+	if err := json.Unmarshal(data, &r.ContentResponse); err != nil {
+		return err
+	}
+	var encoded struct {
+		Choices []struct {
+			ToolCalls []struct {
+				ToolCall struct {
+					Function *llms.FunctionCall `json:"function"`
+				} `json:"tool_call"`
+			}
+		}
+	}
+	if err := json.Unmarshal(data, &encoded); err != nil {
+		return err
+	}
+	for i, choice := range r.Choices {
+		if choice == nil || i >= len(encoded.Choices) {
 			continue
 		}
-		return output, nil
+		for j := range choice.ToolCalls {
+			if j < len(encoded.Choices[i].ToolCalls) {
+				choice.ToolCalls[j].FunctionCall = encoded.Choices[i].ToolCalls[j].ToolCall.Function
+			}
+		}
 	}
-	return "", fmt.Errorf("generate json: %w", err)
+	return nil
 }
@@ -0,0 +1,179 @@
+package llm
+
+import (
+	"fmt"
+	"reflect"
+	"strconv"
+	"strings"
+)
+
+// Schema derives a JSON Schema from the Go type of v, using the json tags for
+// property names and the validator tags for constraints. With strict set the
+// schema follows the rules of OpenAI structured outputs: every property is
+// required, additional properties are rejected and keywords the provider does
+// not support are left out (they are still enforced by the validator).
+func Schema(v any, strict bool) (map[string]any, error) {
+	t := reflect.TypeOf(v)
+	for t != nil && t.Kind() == reflect.Pointer {
+		t = t.Elem()
+	}
+	if t == nil || t.Kind() != reflect.Struct {
+		return nil, fmt.Errorf("schema: want a struct, got %v", reflect.TypeOf(v))
+	}
+	return schemaOf(t, "", strict)
+}
+
+func schemaOf(t reflect.Type, tag string, strict bool) (map[string]any, error) {
+	for t.Kind() == reflect.Pointer {
+		t = t.Elem()
+	}
+
+	rules, itemRules, _ := strings.Cut(","+tag, ",dive")
+	rules = strings.TrimPrefix(rules, ",")
+	itemRules = strings.TrimPrefix(itemRules, ",")
+
+	schema := map[string]any{}
+	switch t.Kind() {
+	case reflect.String:
+		schema["type"] = "string"
+	case reflect.Bool:
+		schema["type"] = "boolean"
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64,
+		reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		schema["type"] = "integer"
+	case reflect.Float32, reflect.Float64:
+		schema["type"] = "number"
+	case reflect.Slice, reflect.Array:
+		items, err := schemaOf(t.Elem(), itemRules, strict)
+		if err != nil {
+			return nil, err
+		}
+		schema["type"] = "array"
+		schema["items"] = items
+	case reflect.Struct:
+		properties := map[string]any{}
+		var required []string
+		for i := 0; i < t.NumField(); i++ {
+			field := t.Field(i)
+			if !field.IsExported() {
+				continue
+			}
+			name, opts, _ := strings.Cut(field.Tag.Get("json"), ",")
+			if name == "-" {
+				continue
+			}
+			if name == "" {
+				name = field.Name
+			}
+			validate := field.Tag.Get("validate")
+			property, err := schemaOf(field.Type, validate, strict)
+			if err != nil {
+				return nil, fmt.Errorf("%s.%s: %w", t.Name(), field.Name, err)
+			}
+			properties[name] = property
+			if strict || hasRule(validate, "required") || (!strings.Contains(opts, "omitempty") && !hasRule(validate, "omitempty")) {
+				required = append(required, name)
+			}
+		}
+		schema["type"] = "object"
+		schema["properties"] = properties
+		schema["required"] = required
+		schema["additionalProperties"] = false
+	default:
+		return nil, fmt.Errorf("unsupported kind %v", t.Kind())
+	}
+
+	applyRules(schema, rules, strict)
+	return schema, nil
+}
+
+func applyRules(schema map[string]any, rules string, strict bool) {
+	if rules == "" {
+		return
+	}
+	typ := schema["type"]
+	for _, rule := range strings.Split(rules, ",") {
+		name, param, _ := strings.Cut(rule, "=")
+		switch name {
+		case "oneof":
+			var enum []any
+			for _, value := range strings.Fields(param) {
+				if typ == "integer" || typ == "number" {
+					if n, err := strconv.ParseFloat(value, 64); err == nil {
+						enum = append(enum, n)
+						continue
+					}
+				}
+				enum = append(enum, value)
+			}
+			schema["enum"] = enum
+		case "min", "gte", "max", "lte", "len", "gt", "lt":
+			n, err := strconv.ParseFloat(param, 64)
+			if err != nil {
+				continue
+			}
+			if typ != "integer" && typ != "number" {
+				// lengths are integers, so exclusive bounds become inclusive ones
+				if name == "gt" {
+					n++
+				} else if name == "lt" {
+					n--
+				}
+			}
+			for _, keyword := range boundKeywords(typ, name, strict) {
+				schema[keyword] = n
+			}
+		}
+	}
+}
+
+// boundKeywords maps a validator bound to the JSON Schema keywords of the
+// given type.
+func boundKeywords(typ any, rule string, strict bool) []string {
+	lower := rule == "min" || rule == "gte" || rule == "gt" || rule == "len"
+	upper := rule == "max" || rule == "lte" || rule == "lt" || rule == "len"
+	var keywords []string
+	switch typ {
+	case "integer", "number":
+		switch {
+		case rule == "gt":
+			keywords = append(keywords, "exclusiveMinimum")
+		case rule == "lt":
+			keywords = append(keywords, "exclusiveMaximum")
+		default:
+			if lower {
+				keywords = append(keywords, "minimum")
+			}
+			if upper {
+				keywords = append(keywords, "maximum")
+			}
+		}
+	case "array":
+		if lower {
+			keywords = append(keywords, "minItems")
+		}
+		if upper {
+			keywords = append(keywords, "maxItems")
+		}
+	case "string":
+		if strict {
+			return nil
+		}
+		if lower {
+			keywords = append(keywords, "minLength")
+		}
+		if upper {
+			keywords = append(keywords, "maxLength")
+		}
+	}
+	return keywords
+}
+
+func hasRule(rules string, name string) bool {
+	for _, rule := range strings.Split(rules, ",") {
+		if rule == name {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,12 @@
+// Package prompts embeds the system prompt templates of the agent nodes.
+package prompts
+
+import "embed"
+
+//go:embed *.md
+var files embed.FS
+
+// ReadFile returns the content of the named prompt template, e.g. "planner.md".
+func ReadFile(name string) ([]byte, error) {
+	return files.ReadFile(name)
+}
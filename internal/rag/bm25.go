@@ -0,0 +1,142 @@
+package rag
+
+import (
+	"math"
+	"sort"
+	"strings"
+	"unicode"
+)
+
+const (
+	bm25K1 = 1.2
+	bm25B  = 0.75
+)
+
+// Result is a chunk matched by a search together with its relevance score.
+type Result struct {
+	Chunk
+	Score float64 `json:"score"`
+}
+
+// Index is an in-memory BM25 index over document chunks.
+type Index struct {
+	chunks    []Chunk
+	termFreqs []map[string]int
+	docFreqs  map[string]int
+	lengths   []int
+	avgLength float64
+}
+
+// NewIndex builds a BM25 index over chunks.
+func NewIndex(chunks []Chunk) *Index {
+	index := &Index{
+		chunks:    chunks,
+		termFreqs: make([]map[string]int, len(chunks)),
+		docFreqs:  make(map[string]int),
+		lengths:   make([]int, len(chunks)),
+	}
+
+	total := 0
+	for i, chunk := range chunks {
+		tokens := Tokenize(chunk.Text)
+		freqs := make(map[string]int)
+		for _, token := range tokens {
+			freqs[token]++
+		}
+		for token := range freqs {
+			index.docFreqs[token]++
+		}
+		index.termFreqs[i] = freqs
+		index.lengths[i] = len(tokens)
+		total += len(tokens)
+	}
+	if len(chunks) > 0 {
+		index.avgLength = float64(total) / float64(len(chunks))
+	}
+	return index
+}
+
+// BuildIndex loads every supported document under dir and indexes it.
+func BuildIndex(dir string, chunkSize int, overlap int) (*Index, error) {
+	docs, err := LoadDir(dir)
+	if err != nil {
+		return nil, err
+	}
+	var chunks []Chunk
+	for _, doc := range docs {
+		for _, chunk := range SplitLines(doc.Path, doc.Text, chunkSize, overlap) {
+			if len(doc.Pages) > 0 {
+				// the page whose first line is the last one not after the chunk
+				chunk.Page = sort.Search(len(doc.Pages), func(i int) bool { return doc.Pages[i] > chunk.StartLine })
+			}
+			chunks = append(chunks, chunk)
+		}
+	}
+	return NewIndex(chunks), nil
+}
+
+// Len returns the number of indexed chunks.
+func (index *Index) Len() int {
+	return len(index.chunks)
+}
+
+// Search returns up to k chunks ranked by BM25 score against query.
+func (index *Index) Search(query string, k int) []Result {
+	terms := Tokenize(query)
+	if len(terms) == 0 || len(index.chunks) == 0 {
+		return nil
+	}
+
+	n := float64(len(index.chunks))
+	var results []Result
+	for i, freqs := range index.termFreqs {
+		score := 0.0
+		for _, term := range terms {
+			tf := float64(freqs[term])
+			if tf == 0 {
+				continue
+			}
+			df := float64(index.docFreqs[term])
+			idf := math.Log(1 + (n-df+0.5)/(df+0.5))
+			norm := tf + bm25K1*(1-bm25B+bm25B*float64(index.lengths[i])/index.avgLength)
+			score += idf * tf * (bm25K1 + 1) / norm
+		}
+		if score > 0 {
+			results = append(results, Result{Chunk: index.chunks[i], Score: score})
+		}
+	}
+
+	sort.Slice(results, func(i, j int) bool {
+		return results[i].Score > results[j].Score
+	})
+	if len(results) > k {
+		results = results[:k]
+	}
+	return results
+}
+
+// Tokenize lowercases text and splits it into letter/digit runs. Han
+// characters are emitted one per token since they are not space separated.
+func Tokenize(text string) []string {
+	var tokens []string
+	var current strings.Builder
+	flush := func() {
+		if current.Len() > 0 {
+			tokens = append(tokens, current.String())
+			current.Reset()
+		}
+	}
+	for _, r := range strings.ToLower(text) {
+		switch {
+		case unicode.Is(unicode.Han, r):
+			flush()
+			tokens = append(tokens, string(r))
+		case unicode.IsLetter(r) || unicode.IsDigit(r):
+			current.WriteRune(r)
+		default:
+			flush()
+		}
+	}
+	flush()
+	return tokens
+}
@@ -0,0 +1,51 @@
+package rag
+
+import "strings"
+
+// Chunk is a contiguous range of lines taken from a source document.
+type Chunk struct {
+	Source    string `json:"source"`
+	StartLine int    `json:"start_line"`
+	EndLine   int    `json:"end_line"`
+	// Page is the page of a PDF the chunk starts on; zero for other
+	// documents.
+	Page int    `json:"page,omitempty"`
+	Text string `json:"text"`
+}
+
+// SplitLines splits text into chunks of at most maxChars characters. Chunks
+// never break a line, and consecutive chunks share up to overlap lines so that
+// passages spanning a boundary can still be found. Line numbers are 1-based.
+func SplitLines(source string, text string, maxChars int, overlap int) []Chunk {
+	lines := strings.Split(strings.ReplaceAll(text, "\r\n", "\n"), "\n")
+
+	var chunks []Chunk
+	start := 0
+	for start < len(lines) {
+		end, size := start, 0
+		for end < len(lines) && (end == start || size+len(lines[end])+1 <= maxChars) {
+			size += len(lines[end]) + 1
+			end++
+		}
+
+		chunkText := strings.TrimSpace(strings.Join(lines[start:end], "\n"))
+		if chunkText != "" {
+			chunks = append(chunks, Chunk{
+				Source:    source,
+				StartLine: start + 1,
+				EndLine:   end,
+				Text:      chunkText,
+			})
+		}
+		if end >= len(lines) {
+			break
+		}
+
+		next := end - overlap
+		if next <= start {
+			next = end
+		}
+		start = next
+	}
+	return chunks
+}
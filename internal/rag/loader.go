@@ -0,0 +1,171 @@
+package rag
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"io/fs"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/ledongthuc/pdf"
+	"golang.org/x/net/html"
+)
+
+// Document is the plain text extracted from a single file.
+type Document struct {
+	Path string
+	Text string
+	// Pages holds the first line of every page of a PDF, 1-based.
+	Pages []int
+}
+
+// LoadDir walks dir and extracts text from every Markdown, text, HTML and PDF
+// file it contains. Files that cannot be parsed are skipped with a warning.
+func LoadDir(dir string) ([]Document, error) {
+	var docs []Document
+	err := filepath.WalkDir(dir, func(path string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		if d.IsDir() {
+			if path != dir && strings.HasPrefix(d.Name(), ".") {
+				return filepath.SkipDir
+			}
+			return nil
+		}
+
+		text, pages, err := loadFile(path)
+		if err != nil {
+			slog.Warn("load local document", "path", path, "error", err)
+			return nil
+		}
+		if text == "" {
+			return nil
+		}
+		docs = append(docs, Document{Path: path, Text: text, Pages: pages})
+		return nil
+	})
+	if err != nil {
+		return nil, fmt.Errorf("walk %s: %w", dir, err)
+	}
+	return docs, nil
+}
+
+// LoadFile extracts the text of a single file based on its extension. It
+// returns an empty string for unsupported file types.
+func LoadFile(path string) (string, error) {
+	text, _, err := loadFile(path)
+	return text, err
+}
+
+// loadFile extracts the text of path along with the first line of every page
+// of a PDF.
+func loadFile(path string) (string, []int, error) {
+	switch strings.ToLower(filepath.Ext(path)) {
+	case ".md", ".markdown", ".txt", ".text":
+		b, err := os.ReadFile(path)
+		if err != nil {
+			return "", nil, err
+		}
+		return string(b), nil, nil
+	case ".html", ".htm":
+		f, err := os.Open(path)
+		if err != nil {
+			return "", nil, err
+		}
+		defer f.Close()
+		text, err := htmlText(f)
+		return text, nil, err
+	case ".pdf":
+		return pdfText(path)
+	default:
+		return "", nil, nil
+	}
+}
+
+// IsPlainText reports whether path is a Markdown or text file, whose chunk
+// line numbers match the lines of the file.
+func IsPlainText(path string) bool {
+	switch strings.ToLower(filepath.Ext(path)) {
+	case ".md", ".markdown", ".txt", ".text":
+		return true
+	}
+	return false
+}
+
+var htmlBlockElements = map[string]bool{
+	"p": true, "div": true, "br": true, "li": true, "tr": true, "section": true, "article": true,
+	"h1": true, "h2": true, "h3": true, "h4": true, "h5": true, "h6": true, "pre": true, "table": true,
+}
+
+func htmlText(r io.Reader) (string, error) {
+	root, err := html.Parse(r)
+	if err != nil {
+		return "", err
+	}
+
+	var buf strings.Builder
+	var walk func(n *html.Node)
+	walk = func(n *html.Node) {
+		if n.Type == html.ElementNode && (n.Data == "script" || n.Data == "style" || n.Data == "noscript") {
+			return
+		}
+		if n.Type == html.TextNode {
+			if text := strings.TrimSpace(n.Data); text != "" {
+				buf.WriteString(text)
+				buf.WriteString(" ")
+			}
+		}
+		for c := n.FirstChild; c != nil; c = c.NextSibling {
+			walk(c)
+		}
+		if n.Type == html.ElementNode && htmlBlockElements[n.Data] {
+			buf.WriteString("\n")
+		}
+	}
+	walk(root)
+
+	var lines []string
+	for _, line := range strings.Split(buf.String(), "\n") {
+		if line = strings.TrimSpace(line); line != "" {
+			lines = append(lines, line)
+		}
+	}
+	return strings.Join(lines, "\n"), nil
+}
+
+func pdfText(path string) (string, []int, error) {
+	f, r, err := pdf.Open(path)
+	if err != nil {
+		return "", nil, err
+	}
+	defer f.Close()
+
+	var buf bytes.Buffer
+	var pages []int
+	line := 1
+	for i := 1; i <= r.NumPage(); i++ {
+		pages = append(pages, line)
+		page := r.Page(i)
+		if page.V.IsNull() {
+			continue
+		}
+		rows, err := page.GetTextByRow()
+		if err != nil {
+			return "", nil, fmt.Errorf("page %d: %w", i, err)
+		}
+		line += len(rows)
+		for _, row := range rows {
+			var words []string
+			for _, word := range row.Content {
+				words = append(words, word.S)
+			}
+			buf.WriteString(strings.Join(words, " "))
+			buf.WriteString("\n")
+		}
+	}
+	return buf.String(), pages, nil
+}
@@ -0,0 +1,137 @@
+package rag
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math"
+	"os"
+	"path/filepath"
+	"sort"
+	"sync"
+
+	"github.com/rickif/tiny-research/util"
+	"github.com/tmc/langchaingo/embeddings"
+)
+
+type vectorEntry struct {
+	Chunk  Chunk     `json:"chunk"`
+	Vector []float32 `json:"vector"`
+}
+
+// VectorStore is an in-process vector index persisted as a JSON file.
+type VectorStore struct {
+	mu        sync.RWMutex
+	embedder  embeddings.Embedder
+	path      string
+	chunkSize int
+	overlap   int
+	entries   []vectorEntry
+}
+
+// NewVectorStore opens the store persisted at path, creating an empty one if
+// the file does not exist yet.
+func NewVectorStore(embedder embeddings.Embedder, path string, chunkSize int, overlap int) (*VectorStore, error) {
+	store := &VectorStore{
+		embedder:  embedder,
+		path:      path,
+		chunkSize: chunkSize,
+		overlap:   overlap,
+	}
+
+	b, err := os.ReadFile(path)
+	if errors.Is(err, os.ErrNotExist) {
+		return store, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	if err := json.Unmarshal(b, &store.entries); err != nil {
+		return nil, fmt.Errorf("load vector store %s: %w", path, err)
+	}
+	return store, nil
+}
+
+// Len returns the number of stored chunks.
+func (s *VectorStore) Len() int {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	return len(s.entries)
+}
+
+// Add chunks text, embeds the chunks and persists the store. It returns the
+// number of chunks added.
+func (s *VectorStore) Add(ctx context.Context, source string, text string) (int, error) {
+	chunks := SplitLines(source, text, s.chunkSize, s.overlap)
+	if len(chunks) == 0 {
+		return 0, nil
+	}
+
+	texts := make([]string, len(chunks))
+	for i, chunk := range chunks {
+		texts[i] = chunk.Text
+	}
+	vectors, err := s.embedder.EmbedDocuments(ctx, texts)
+	if err != nil {
+		return 0, fmt.Errorf("embed documents: %w", err)
+	}
+	if len(vectors) != len(chunks) {
+		return 0, fmt.Errorf("embed documents: got %d vectors for %d chunks", len(vectors), len(chunks))
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for i, chunk := range chunks {
+		s.entries = append(s.entries, vectorEntry{Chunk: chunk, Vector: vectors[i]})
+	}
+	return len(chunks), s.save()
+}
+
+// Search returns up to k chunks ranked by cosine similarity to query.
+func (s *VectorStore) Search(ctx context.Context, query string, k int) ([]Result, error) {
+	vector, err := s.embedder.EmbedQuery(ctx, query)
+	if err != nil {
+		return nil, fmt.Errorf("embed query: %w", err)
+	}
+
+	s.mu.RLock()
+	results := make([]Result, 0, len(s.entries))
+	for _, entry := range s.entries {
+		results = append(results, Result{Chunk: entry.Chunk, Score: cosine(vector, entry.Vector)})
+	}
+	s.mu.RUnlock()
+
+	sort.Slice(results, func(i, j int) bool {
+		return results[i].Score > results[j].Score
+	})
+	if len(results) > k {
+		results = results[:k]
+	}
+	return results, nil
+}
+
+func (s *VectorStore) save() error {
+	if err := os.MkdirAll(filepath.Dir(s.path), 0o755); err != nil {
+		return err
+	}
+	return util.WriteFile(s.path, func(f *os.File) error {
+		return json.NewEncoder(f).Encode(s.entries)
+	})
+}
+
+func cosine(a, b []float32) float64 {
+	if len(a) != len(b) {
+		return 0
+	}
+	var dot, normA, normB float64
+	for i := range a {
+		dot += float64(a[i]) * float64(b[i])
+		normA += float64(a[i]) * float64(a[i])
+		normB += float64(b[i]) * float64(b[i])
+	}
+	if normA == 0 || normB == 0 {
+		return 0
+	}
+	return dot / (math.Sqrt(normA) * math.Sqrt(normB))
+}
@@ -0,0 +1,58 @@
+// Package replay records the outbound calls of a research run to a cassette
+// file and serves them back deterministically, so the agent graph can run
+// without network access.
+package replay
+
+import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"os"
+)
+
+const (
+	KindClock          = "clock"
+	KindLLM            = "llm"
+	KindSearch         = "search"
+	KindCrawl          = "crawl"
+	KindPython         = "python"
+	KindEmbedQuery     = "embed_query"
+	KindEmbedDocuments = "embed_documents"
+)
+
+// Interaction is one recorded call. Request identifies the call and Response
+// holds its JSON encoded result, or Error if the call failed.
+type Interaction struct {
+	Kind     string          `json:"kind"`
+	Request  string          `json:"request"`
+	Response json.RawMessage `json:"response,omitempty"`
+	Error    string          `json:"error,omitempty"`
+}
+
+// Load reads a cassette written by a Recorder. Cassettes are JSONL files with
+// one Interaction per line, in call order.
+func Load(path string) ([]Interaction, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	var interactions []Interaction
+	scanner := bufio.NewScanner(f)
+	scanner.Buffer(make([]byte, 0, 64*1024), 64*1024*1024)
+	for line := 1; scanner.Scan(); line++ {
+		if len(scanner.Bytes()) == 0 {
+			continue
+		}
+		var interaction Interaction
+		if err := json.Unmarshal(scanner.Bytes(), &interaction); err != nil {
+			return nil, fmt.Errorf("%s:%d: %w", path, line, err)
+		}
+		interactions = append(interactions, interaction)
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, err
+	}
+	return interactions, nil
+}
@@ -0,0 +1,169 @@
+package replay
+
+import (
+	"context"
+	"encoding/json"
+	"os"
+	"sync"
+	"time"
+
+	"github.com/rickif/tiny-research/internal/llm"
+	"github.com/rickif/tiny-research/internal/tool"
+	"github.com/tmc/langchaingo/embeddings"
+	"github.com/tmc/langchaingo/llms"
+)
+
+// Recorder appends every call made through its wrappers to a cassette file.
+type Recorder struct {
+	mu   sync.Mutex
+	file *os.File
+}
+
+// NewRecorder creates or truncates the cassette at path.
+func NewRecorder(path string) (*Recorder, error) {
+	f, err := os.Create(path)
+	if err != nil {
+		return nil, err
+	}
+	return &Recorder{file: f}, nil
+}
+
+// Close closes the cassette file.
+func (r *Recorder) Close() error {
+	return r.file.Close()
+}
+
+func (r *Recorder) record(kind string, request string, response any, callErr error) error {
+	interaction := Interaction{Kind: kind, Request: request}
+	if callErr != nil {
+		interaction.Error = callErr.Error()
+	} else {
+		b, err := json.Marshal(response)
+		if err != nil {
+			return err
+		}
+		interaction.Response = b
+	}
+
+	b, err := json.Marshal(interaction)
+	if err != nil {
+		return err
+	}
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	_, err = r.file.Write(append(b, '\n'))
+	return err
+}
+
+// Now returns the current time and records it, so that prompts built from it
+// are identical on replay.
+func (r *Recorder) Now() time.Time {
+	now := time.Now()
+	_ = r.record(KindClock, "", now, nil)
+	return now
+}
+
+func (r *Recorder) Model(model llms.Model) llms.Model {
+	return &recordModel{model: model, recorder: r}
+}
+
+func (r *Recorder) Searcher(searcher tool.Searcher) tool.Searcher {
+	return &recordSearcher{searcher: searcher, recorder: r}
+}
+
+func (r *Recorder) Crawler(crawler tool.Crawler) tool.Crawler {
+	return &recordCrawler{crawler: crawler, recorder: r}
+}
+
+func (r *Recorder) Python(python tool.PythonRunner) tool.PythonRunner {
+	return &recordPython{python: python, recorder: r}
+}
+
+func (r *Recorder) Embedder(embedder embeddings.Embedder) embeddings.Embedder {
+	return &recordEmbedder{embedder: embedder, recorder: r}
+}
+
+type recordModel struct {
+	model    llms.Model
+	recorder *Recorder
+}
+
+func (m *recordModel) GenerateContent(ctx context.Context, messages []llms.MessageContent, options ...llms.CallOption) (*llms.ContentResponse, error) {
+	request, err := llm.EncodeRequest(messages, options)
+	if err != nil {
+		return nil, err
+	}
+	resp, err := m.model.GenerateContent(ctx, messages, options...)
+	if recordErr := m.recorder.record(KindLLM, request, resp, err); recordErr != nil {
+		return nil, recordErr
+	}
+	return resp, err
+}
+
+func (m *recordModel) Call(ctx context.Context, prompt string, options ...llms.CallOption) (string, error) {
+	return llms.GenerateFromSinglePrompt(ctx, m, prompt, options...)
+}
+
+type recordSearcher struct {
+	searcher tool.Searcher
+	recorder *Recorder
+}
+
+func (s *recordSearcher) Search(ctx context.Context, query string) (string, error) {
+	output, err := s.searcher.Search(ctx, query)
+	if recordErr := s.recorder.record(KindSearch, query, output, err); recordErr != nil {
+		return "", recordErr
+	}
+	return output, err
+}
+
+type recordCrawler struct {
+	crawler  tool.Crawler
+	recorder *Recorder
+}
+
+func (c *recordCrawler) Crawl(ctx context.Context, url string) (string, error) {
+	output, err := c.crawler.Crawl(ctx, url)
+	if recordErr := c.recorder.record(KindCrawl, url, output, err); recordErr != nil {
+		return "", recordErr
+	}
+	return output, err
+}
+
+type recordPython struct {
+	python   tool.PythonRunner
+	recorder *Recorder
+}
+
+func (p *recordPython) Run(ctx context.Context, code string) (string, error) {
+	output, err := p.python.Run(ctx, code)
+	if recordErr := p.recorder.record(KindPython, code, output, err); recordErr != nil {
+		return "", recordErr
+	}
+	return output, err
+}
+
+type recordEmbedder struct {
+	embedder embeddings.Embedder
+	recorder *Recorder
+}
+
+func (e *recordEmbedder) EmbedDocuments(ctx context.Context, texts []string) ([][]float32, error) {
+	request, err := json.Marshal(texts)
+	if err != nil {
+		return nil, err
+	}
+	vectors, err := e.embedder.EmbedDocuments(ctx, texts)
+	if recordErr := e.recorder.record(KindEmbedDocuments, string(request), vectors, err); recordErr != nil {
+		return nil, recordErr
+	}
+	return vectors, err
+}
+
+func (e *recordEmbedder) EmbedQuery(ctx context.Context, text string) ([]float32, error) {
+	vector, err := e.embedder.EmbedQuery(ctx, text)
+	if recordErr := e.recorder.record(KindEmbedQuery, text, vector, err); recordErr != nil {
+		return nil, recordErr
+	}
+	return vector, err
+}
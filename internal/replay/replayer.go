@@ -0,0 +1,173 @@
+package replay
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/rickif/tiny-research/internal/llm"
+	"github.com/rickif/tiny-research/internal/tool"
+	"github.com/tmc/langchaingo/embeddings"
+	"github.com/tmc/langchaingo/llms"
+)
+
+// ErrExhausted is returned when a call is made after all recorded calls of
+// its kind have been served.
+var ErrExhausted = errors.New("replay: cassette exhausted")
+
+// Replayer serves recorded interactions back in the order they were
+// recorded. Each kind of call is replayed independently, and every request
+// must match the recorded one exactly.
+type Replayer struct {
+	mu    sync.Mutex
+	queue map[string][]Interaction
+}
+
+// NewReplayer loads the cassette at path.
+func NewReplayer(path string) (*Replayer, error) {
+	interactions, err := Load(path)
+	if err != nil {
+		return nil, err
+	}
+	r := &Replayer{queue: make(map[string][]Interaction)}
+	for _, interaction := range interactions {
+		r.queue[interaction.Kind] = append(r.queue[interaction.Kind], interaction)
+	}
+	return r, nil
+}
+
+func (r *Replayer) next(kind string, request string, response any) error {
+	r.mu.Lock()
+	queue := r.queue[kind]
+	if len(queue) == 0 {
+		r.mu.Unlock()
+		return fmt.Errorf("%w: no more %s calls", ErrExhausted, kind)
+	}
+	interaction := queue[0]
+	r.queue[kind] = queue[1:]
+	r.mu.Unlock()
+
+	if interaction.Request != request {
+		return fmt.Errorf("replay: %s request does not match the cassette", kind)
+	}
+	if interaction.Error != "" {
+		return errors.New(interaction.Error)
+	}
+	if err := json.Unmarshal(interaction.Response, response); err != nil {
+		return fmt.Errorf("replay: decode %s response: %w", kind, err)
+	}
+	return nil
+}
+
+// Remaining returns the number of recorded calls not served yet.
+func (r *Replayer) Remaining() int {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	n := 0
+	for _, queue := range r.queue {
+		n += len(queue)
+	}
+	return n
+}
+
+// Now returns the next recorded time, or the current time if none is left.
+func (r *Replayer) Now() time.Time {
+	var now time.Time
+	if err := r.next(KindClock, "", &now); err != nil {
+		return time.Now()
+	}
+	return now
+}
+
+func (r *Replayer) Model() llms.Model {
+	return &replayModel{replayer: r}
+}
+
+func (r *Replayer) Searcher() tool.Searcher {
+	return &replaySearcher{replayer: r}
+}
+
+func (r *Replayer) Crawler() tool.Crawler {
+	return &replayCrawler{replayer: r}
+}
+
+func (r *Replayer) Python() tool.PythonRunner {
+	return &replayPython{replayer: r}
+}
+
+func (r *Replayer) Embedder() embeddings.Embedder {
+	return &replayEmbedder{replayer: r}
+}
+
+type replayModel struct {
+	replayer *Replayer
+}
+
+func (m *replayModel) GenerateContent(ctx context.Context, messages []llms.MessageContent, options ...llms.CallOption) (*llms.ContentResponse, error) {
+	request, err := llm.EncodeRequest(messages, options)
+	if err != nil {
+		return nil, err
+	}
+	var resp llm.Response
+	if err := m.replayer.next(KindLLM, request, &resp); err != nil {
+		return nil, err
+	}
+	return &resp.ContentResponse, nil
+}
+
+func (m *replayModel) Call(ctx context.Context, prompt string, options ...llms.CallOption) (string, error) {
+	return llms.GenerateFromSinglePrompt(ctx, m, prompt, options...)
+}
+
+type replaySearcher struct {
+	replayer *Replayer
+}
+
+func (s *replaySearcher) Search(ctx context.Context, query string) (string, error) {
+	var output string
+	err := s.replayer.next(KindSearch, query, &output)
+	return output, err
+}
+
+type replayCrawler struct {
+	replayer *Replayer
+}
+
+func (c *replayCrawler) Crawl(ctx context.Context, url string) (string, error) {
+	var output string
+	err := c.replayer.next(KindCrawl, url, &output)
+	return output, err
+}
+
+type replayPython struct {
+	replayer *Replayer
+}
+
+func (p *replayPython) Run(ctx context.Context, code string) (string, error) {
+	var output string
+	err := p.replayer.next(KindPython, code, &output)
+	return output, err
+}
+
+type replayEmbedder struct {
+	replayer *Replayer
+}
+
+func (e *replayEmbedder) EmbedDocuments(ctx context.Context, texts []string) ([][]float32, error) {
+	request, err := json.Marshal(texts)
+	if err != nil {
+		return nil, err
+	}
+	var vectors [][]float32
+	err = e.replayer.next(KindEmbedDocuments, string(request), &vectors)
+	return vectors, err
+}
+
+func (e *replayEmbedder) EmbedQuery(ctx context.Context, text string) ([]float32, error) {
+	var vector []float32
+	err := e.replayer.next(KindEmbedQuery, text, &vector)
+	return vector, err
+}
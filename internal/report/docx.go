@@ -0,0 +1,257 @@
+package report
+
+import (
+	"archive/zip"
+	"bytes"
+	"encoding/xml"
+	"fmt"
+	"io"
+	"strings"
+	"time"
+)
+
+// A DOCX file is a zip of WordprocessingML parts. The report only needs the
+// document, its styles and the relationships of its hyperlinks, so they are
+// written by hand rather than through a library.
+
+const docxContentTypes = `<?xml version="1.0" encoding="UTF-8" standalone="yes"?>
+<Types xmlns="http://schemas.openxmlformats.org/package/2006/content-types">
+<Default Extension="rels" ContentType="application/vnd.openxmlformats-package.relationships+xml"/>
+<Default Extension="xml" ContentType="application/xml"/>
+<Override PartName="/word/document.xml" ContentType="application/vnd.openxmlformats-officedocument.wordprocessingml.document.main+xml"/>
+<Override PartName="/word/styles.xml" ContentType="application/vnd.openxmlformats-officedocument.wordprocessingml.styles+xml"/>
+<Override PartName="/docProps/core.xml" ContentType="application/vnd.openxmlformats-package.core-properties+xml"/>
+</Types>`
+
+const docxPackageRels = `<?xml version="1.0" encoding="UTF-8" standalone="yes"?>
+<Relationships xmlns="http://schemas.openxmlformats.org/package/2006/relationships">
+<Relationship Id="rId1" Type="http://schemas.openxmlformats.org/officeDocument/2006/relationships/officeDocument" Target="word/document.xml"/>
+<Relationship Id="rId2" Type="http://schemas.openxmlformats.org/package/2006/relationships/metadata/core-properties" Target="docProps/core.xml"/>
+</Relationships>`
+
+const docxStyles = `<?xml version="1.0" encoding="UTF-8" standalone="yes"?>
+<w:styles xmlns:w="http://schemas.openxmlformats.org/wordprocessingml/2006/main">
+<w:docDefaults>
+<w:rPrDefault><w:rPr><w:rFonts w:ascii="Calibri" w:hAnsi="Calibri" w:eastAsia="Calibri" w:cs="Calibri"/><w:sz w:val="22"/></w:rPr></w:rPrDefault>
+<w:pPrDefault><w:pPr><w:spacing w:after="160" w:line="276" w:lineRule="auto"/></w:pPr></w:pPrDefault>
+</w:docDefaults>
+<w:style w:type="paragraph" w:default="1" w:styleId="Normal"><w:name w:val="Normal"/></w:style>
+<w:style w:type="paragraph" w:styleId="Title"><w:name w:val="Title"/><w:basedOn w:val="Normal"/><w:next w:val="Normal"/><w:pPr><w:spacing w:after="240"/></w:pPr><w:rPr><w:b/><w:sz w:val="48"/></w:rPr></w:style>
+<w:style w:type="paragraph" w:styleId="Heading1"><w:name w:val="heading 1"/><w:basedOn w:val="Normal"/><w:next w:val="Normal"/><w:pPr><w:keepNext/><w:spacing w:before="360" w:after="120"/><w:outlineLvl w:val="0"/></w:pPr><w:rPr><w:b/><w:sz w:val="32"/></w:rPr></w:style>
+<w:style w:type="paragraph" w:styleId="Heading2"><w:name w:val="heading 2"/><w:basedOn w:val="Normal"/><w:next w:val="Normal"/><w:pPr><w:keepNext/><w:spacing w:before="240" w:after="80"/><w:outlineLvl w:val="1"/></w:pPr><w:rPr><w:b/><w:sz w:val="26"/></w:rPr></w:style>
+<w:style w:type="paragraph" w:styleId="Heading3"><w:name w:val="heading 3"/><w:basedOn w:val="Normal"/><w:next w:val="Normal"/><w:pPr><w:keepNext/><w:spacing w:before="200" w:after="60"/><w:outlineLvl w:val="2"/></w:pPr><w:rPr><w:b/><w:sz w:val="24"/></w:rPr></w:style>
+<w:style w:type="paragraph" w:styleId="Quote"><w:name w:val="Quote"/><w:basedOn w:val="Normal"/><w:pPr><w:ind w:left="567"/><w:pBdr><w:left w:val="single" w:sz="18" w:space="8" w:color="D1D9E0"/></w:pBdr></w:pPr><w:rPr><w:i/><w:color w:val="59636E"/></w:rPr></w:style>
+<w:style w:type="paragraph" w:styleId="Code"><w:name w:val="Code"/><w:basedOn w:val="Normal"/><w:pPr><w:shd w:val="clear" w:color="auto" w:fill="F6F8FA"/><w:spacing w:after="0" w:line="240" w:lineRule="auto"/></w:pPr><w:rPr><w:rFonts w:ascii="Consolas" w:hAnsi="Consolas"/><w:sz w:val="18"/></w:rPr></w:style>
+<w:style w:type="character" w:styleId="Hyperlink"><w:name w:val="Hyperlink"/><w:rPr><w:color w:val="0969DA"/><w:u w:val="single"/></w:rPr></w:style>
+<w:style w:type="table" w:styleId="TableGrid"><w:name w:val="Table Grid"/><w:tblPr><w:tblBorders><w:top w:val="single" w:sz="4" w:color="D1D9E0"/><w:left w:val="single" w:sz="4" w:color="D1D9E0"/><w:bottom w:val="single" w:sz="4" w:color="D1D9E0"/><w:right w:val="single" w:sz="4" w:color="D1D9E0"/><w:insideH w:val="single" w:sz="4" w:color="D1D9E0"/><w:insideV w:val="single" w:sz="4" w:color="D1D9E0"/></w:tblBorders><w:tblCellMar><w:left w:w="108" w:type="dxa"/><w:right w:w="108" w:type="dxa"/></w:tblCellMar></w:tblPr></w:style>
+</w:styles>`
+
+type docxWriter struct {
+	body      bytes.Buffer
+	links     []string
+	bookmarks int
+}
+
+func writeDOCX(w io.Writer, r *Report) error {
+	dw := &docxWriter{}
+	for _, b := range parse(r) {
+		dw.block(b)
+	}
+
+	var rels bytes.Buffer
+	rels.WriteString(`<?xml version="1.0" encoding="UTF-8" standalone="yes"?>` + "\n")
+	rels.WriteString(`<Relationships xmlns="http://schemas.openxmlformats.org/package/2006/relationships">`)
+	rels.WriteString(`<Relationship Id="rIdStyles" Type="http://schemas.openxmlformats.org/officeDocument/2006/relationships/styles" Target="styles.xml"/>`)
+	for i, link := range dw.links {
+		fmt.Fprintf(&rels, `<Relationship Id="rIdLink%d" Type="http://schemas.openxmlformats.org/officeDocument/2006/relationships/hyperlink" Target="%s" TargetMode="External"/>`, i+1, escapeXML(link))
+	}
+	rels.WriteString(`</Relationships>`)
+
+	var document bytes.Buffer
+	document.WriteString(`<?xml version="1.0" encoding="UTF-8" standalone="yes"?>` + "\n")
+	document.WriteString(`<w:document xmlns:w="http://schemas.openxmlformats.org/wordprocessingml/2006/main" xmlns:r="http://schemas.openxmlformats.org/officeDocument/2006/relationships"><w:body>`)
+	document.Write(dw.body.Bytes())
+	document.WriteString(`<w:sectPr><w:pgSz w:w="11906" w:h="16838"/><w:pgMar w:top="1134" w:right="1134" w:bottom="1134" w:left="1134" w:header="708" w:footer="708" w:gutter="0"/></w:sectPr>`)
+	document.WriteString(`</w:body></w:document>`)
+
+	created := r.Metadata.CreatedAt
+	if created.IsZero() {
+		created = time.Now()
+	}
+	core := fmt.Sprintf(`<?xml version="1.0" encoding="UTF-8" standalone="yes"?>
+<cp:coreProperties xmlns:cp="http://schemas.openxmlformats.org/package/2006/metadata/core-properties" xmlns:dc="http://purl.org/dc/elements/1.1/" xmlns:dcterms="http://purl.org/dc/terms/" xmlns:xsi="http://www.w3.org/2001/XMLSchema-instance"><dc:title>%s</dc:title><dc:creator>tiny-research</dc:creator><dcterms:created xsi:type="dcterms:W3CDTF">%s</dcterms:created></cp:coreProperties>`,
+		escapeXML(r.Title), created.UTC().Format(time.RFC3339))
+
+	zw := zip.NewWriter(w)
+	for _, part := range []struct {
+		name    string
+		content []byte
+	}{
+		{"[Content_Types].xml", []byte(docxContentTypes)},
+		{"_rels/.rels", []byte(docxPackageRels)},
+		{"docProps/core.xml", []byte(core)},
+		{"word/document.xml", document.Bytes()},
+		{"word/styles.xml", []byte(docxStyles)},
+		{"word/_rels/document.xml.rels", rels.Bytes()},
+	} {
+		f, err := zw.CreateHeader(&zip.FileHeader{Name: part.name, Method: zip.Deflate, Modified: created})
+		if err != nil {
+			return err
+		}
+		if _, err := f.Write(part.content); err != nil {
+			return err
+		}
+	}
+	return zw.Close()
+}
+
+func (dw *docxWriter) block(b block) {
+	switch b.kind {
+	case blockHeading:
+		style := "Title"
+		if b.level > 1 {
+			style = fmt.Sprintf("Heading%d", min(b.level-1, 3))
+		}
+		dw.paragraph(fmt.Sprintf(`<w:pStyle w:val="%s"/>`, style), "", b.runs)
+	case blockParagraph:
+		dw.paragraph("", "", b.runs)
+	case blockListItem:
+		indent := 360 * b.level
+		runs := append([]run{{text: b.marker + "\t"}}, b.runs...)
+		dw.paragraph(fmt.Sprintf(`<w:spacing w:after="60"/><w:tabs><w:tab w:val="left" w:pos="%d"/></w:tabs><w:ind w:left="%d" w:hanging="360"/>`, indent, indent), b.anchor, runs)
+	case blockQuote:
+		dw.paragraph(`<w:pStyle w:val="Quote"/>`, "", b.runs)
+	case blockCode:
+		for _, line := range strings.Split(b.code, "\n") {
+			dw.paragraph(`<w:pStyle w:val="Code"/>`, "", []run{{text: line}})
+		}
+		dw.paragraph("", "", nil)
+	case blockTable:
+		dw.table(b.rows)
+	case blockRule:
+		dw.body.WriteString(`<w:p><w:pPr><w:pBdr><w:bottom w:val="single" w:sz="6" w:space="1" w:color="D1D9E0"/></w:pBdr></w:pPr></w:p>`)
+	}
+}
+
+// paragraph writes runs as a paragraph, bookmarked as anchor if it is not
+// empty.
+func (dw *docxWriter) paragraph(properties, anchor string, runs []run) {
+	dw.body.WriteString(`<w:p>`)
+	if properties != "" {
+		dw.body.WriteString(`<w:pPr>` + properties + `</w:pPr>`)
+	}
+	if anchor != "" {
+		dw.bookmarks++
+		fmt.Fprintf(&dw.body, `<w:bookmarkStart w:id="%d" w:name="%s"/><w:bookmarkEnd w:id="%d"/>`, dw.bookmarks, bookmarkName(anchor), dw.bookmarks)
+	}
+	dw.runs(runs)
+	dw.body.WriteString(`</w:p>`)
+}
+
+func (dw *docxWriter) runs(runs []run) {
+	for i := 0; i < len(runs); i++ {
+		link := runs[i].link
+		if link == "" {
+			dw.run(runs[i], false)
+			continue
+		}
+		if anchor, ok := strings.CutPrefix(link, "#"); ok {
+			fmt.Fprintf(&dw.body, `<w:hyperlink w:anchor="%s">`, bookmarkName(anchor))
+		} else {
+			dw.links = append(dw.links, link)
+			fmt.Fprintf(&dw.body, `<w:hyperlink r:id="rIdLink%d">`, len(dw.links))
+		}
+		for ; i < len(runs) && runs[i].link == link; i++ {
+			dw.run(runs[i], true)
+		}
+		i--
+		dw.body.WriteString(`</w:hyperlink>`)
+	}
+}
+
+func (dw *docxWriter) run(r run, link bool) {
+	var properties strings.Builder
+	if link {
+		properties.WriteString(`<w:rStyle w:val="Hyperlink"/>`)
+	}
+	if r.code {
+		properties.WriteString(`<w:rFonts w:ascii="Consolas" w:hAnsi="Consolas"/>`)
+	}
+	if r.bold {
+		properties.WriteString(`<w:b/>`)
+	}
+	if r.italic {
+		properties.WriteString(`<w:i/>`)
+	}
+
+	for i, line := range strings.Split(r.text, "\n") {
+		dw.body.WriteString(`<w:r>`)
+		if properties.Len() > 0 {
+			dw.body.WriteString(`<w:rPr>` + properties.String() + `</w:rPr>`)
+		}
+		if i > 0 {
+			dw.body.WriteString(`<w:br/>`)
+		}
+		for j, part := range strings.Split(line, "\t") {
+			if j > 0 {
+				dw.body.WriteString(`<w:tab/>`)
+			}
+			if part != "" {
+				dw.body.WriteString(`<w:t xml:space="preserve">` + escapeXML(part) + `</w:t>`)
+			}
+		}
+		dw.body.WriteString(`</w:r>`)
+	}
+}
+
+func (dw *docxWriter) table(rows [][][]run) {
+	columns := 0
+	for _, row := range rows {
+		columns = max(columns, len(row))
+	}
+	if columns == 0 {
+		return
+	}
+	// 9638 twips is the text width of an A4 page with the margins above
+	width := 9638 / columns
+
+	dw.body.WriteString(`<w:tbl><w:tblPr><w:tblStyle w:val="TableGrid"/><w:tblW w:w="5000" w:type="pct"/></w:tblPr><w:tblGrid>`)
+	for i := 0; i < columns; i++ {
+		fmt.Fprintf(&dw.body, `<w:gridCol w:w="%d"/>`, width)
+	}
+	dw.body.WriteString(`</w:tblGrid>`)
+	for i, row := range rows {
+		dw.body.WriteString(`<w:tr>`)
+		if i == 0 {
+			dw.body.WriteString(`<w:trPr><w:tblHeader/></w:trPr>`)
+		}
+		for j := 0; j < columns; j++ {
+			fmt.Fprintf(&dw.body, `<w:tc><w:tcPr><w:tcW w:w="%d" w:type="dxa"/>`, width)
+			if i == 0 {
+				dw.body.WriteString(`<w:shd w:val="clear" w:color="auto" w:fill="F6F8FA"/>`)
+			}
+			dw.body.WriteString(`</w:tcPr>`)
+			var cell []run
+			if j < len(row) {
+				cell = row[j]
+			}
+			dw.paragraph(`<w:spacing w:after="0"/>`, "", cell)
+			dw.body.WriteString(`</w:tc>`)
+		}
+		dw.body.WriteString(`</w:tr>`)
+	}
+	dw.body.WriteString(`</w:tbl>`)
+	dw.paragraph("", "", nil)
+}
+
+// bookmarkName turns an anchor into a bookmark name, which Word limits to
+// letters, digits and underscores.
+func bookmarkName(anchor string) string {
+	return strings.ReplaceAll(anchor, "-", "_")
+}
+
+func escapeXML(s string) string {
+	var b strings.Builder
+	_ = xml.EscapeText(&b, []byte(s))
+	return b.String()
+}
@@ -0,0 +1,75 @@
+package report
+
+import (
+	"bytes"
+	"html/template"
+	"io"
+
+	"github.com/yuin/goldmark"
+	"github.com/yuin/goldmark/ast"
+	"github.com/yuin/goldmark/parser"
+	"github.com/yuin/goldmark/text"
+	"github.com/yuin/goldmark/util"
+)
+
+var htmlTemplate = template.Must(template.New("report").Parse(`<!DOCTYPE html>
+<html lang="en">
+<head>
+<meta charset="utf-8">
+<meta name="viewport" content="width=device-width, initial-scale=1">
+<title>{{ .Title }}</title>
+<style>
+body { max-width: 52rem; margin: 2rem auto; padding: 0 1rem; font: 16px/1.6 -apple-system, "Segoe UI", Helvetica, Arial, sans-serif; color: #1f2328; }
+h1, h2, h3 { line-height: 1.25; margin-top: 1.6em; }
+h1 { border-bottom: 1px solid #d1d9e0; padding-bottom: .3em; }
+a { color: #0969da; text-decoration: none; }
+a:hover { text-decoration: underline; }
+table { border-collapse: collapse; margin: 1em 0; width: 100%; }
+th, td { border: 1px solid #d1d9e0; padding: .4em .8em; text-align: left; vertical-align: top; }
+th { background: #f6f8fa; }
+tr:nth-child(even) td { background: #fbfcfd; }
+code { font-family: ui-monospace, Menlo, Consolas, monospace; background: #f6f8fa; padding: .1em .3em; border-radius: 4px; font-size: 90%; }
+pre { background: #f6f8fa; padding: 1em; overflow-x: auto; border-radius: 6px; }
+pre code { padding: 0; background: none; }
+blockquote { margin: 1em 0; padding: 0 1em; color: #59636e; border-left: 4px solid #d1d9e0; }
+footer { margin-top: 3em; color: #59636e; font-size: 85%; }
+</style>
+</head>
+<body>
+<article>
+{{ .Body }}
+</article>
+{{ if not .Metadata.CreatedAt.IsZero }}<footer>Generated {{ .Metadata.CreatedAt.Format "2006-01-02 15:04 MST" }}</footer>{{ end }}
+</body>
+</html>
+`))
+
+// externalLinks makes links to other pages open in a new tab, so sources do
+// not navigate away from the report.
+type externalLinks struct{}
+
+func (externalLinks) Transform(node *ast.Document, reader text.Reader, pc parser.Context) {
+	_ = ast.Walk(node, func(n ast.Node, entering bool) (ast.WalkStatus, error) {
+		if link, ok := n.(*ast.Link); ok && bytes.HasPrefix(link.Destination, []byte("#")) {
+			return ast.WalkContinue, nil
+		}
+		if entering && (n.Kind() == ast.KindLink || n.Kind() == ast.KindAutoLink) {
+			n.SetAttributeString("target", []byte("_blank"))
+			n.SetAttributeString("rel", []byte("noopener noreferrer"))
+		}
+		return ast.WalkContinue, nil
+	})
+}
+
+func writeHTML(w io.Writer, r *Report) error {
+	md := newReportMarkdown(r, goldmark.WithParserOptions(parser.WithASTTransformers(util.Prioritized(externalLinks{}, 100))))
+
+	var body bytes.Buffer
+	if err := md.Convert([]byte(r.Markdown()), &body); err != nil {
+		return err
+	}
+	return htmlTemplate.Execute(w, struct {
+		*Report
+		Body template.HTML
+	}{r, template.HTML(body.String())})
+}
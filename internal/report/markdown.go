@@ -0,0 +1,343 @@
+package report
+
+import (
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+
+	"github.com/yuin/goldmark"
+	"github.com/yuin/goldmark/ast"
+	"github.com/yuin/goldmark/extension"
+	east "github.com/yuin/goldmark/extension/ast"
+	"github.com/yuin/goldmark/parser"
+	"github.com/yuin/goldmark/text"
+	"github.com/yuin/goldmark/util"
+)
+
+// The PDF and DOCX writers lay out a flat list of blocks rather than the
+// markdown tree: reports only use headings, paragraphs, lists, quotes, code
+// and tables, and none of them nest in ways these formats need to keep.
+
+type blockKind int
+
+const (
+	blockHeading blockKind = iota
+	blockParagraph
+	blockListItem
+	blockQuote
+	blockCode
+	blockTable
+	blockRule
+)
+
+type block struct {
+	kind blockKind
+	// level is the heading level, or the nesting depth of a list item
+	// starting at 1.
+	level  int
+	marker string
+	// anchor names a list item that links point to, e.g. a reference.
+	anchor string
+	runs   []run
+	code   string
+	// rows holds the cells of a table, the first row being the header.
+	rows [][][]run
+}
+
+// run is a piece of inline text sharing one style.
+type run struct {
+	text   string
+	bold   bool
+	italic bool
+	code   bool
+	// link is a URL, or an anchor of the report starting with #.
+	link string
+}
+
+func newMarkdown(options ...goldmark.Option) goldmark.Markdown {
+	return goldmark.New(append([]goldmark.Option{goldmark.WithExtensions(extension.GFM)}, options...)...)
+}
+
+// newReportMarkdown returns a markdown converter of r that links its
+// citation markers to its references.
+func newReportMarkdown(r *Report, options ...goldmark.Option) goldmark.Markdown {
+	links := parser.WithASTTransformers(util.Prioritized(citationLinks{citations: r.sortedCitations()}, 200))
+	return newMarkdown(append([]goldmark.Option{goldmark.WithParserOptions(links)}, options...)...)
+}
+
+func parse(r *Report) []block {
+	source := []byte(r.Markdown())
+	root := newReportMarkdown(r).Parser().Parse(text.NewReader(source))
+	p := &blockParser{source: source}
+	p.walk(root, 0, false)
+	return p.blocks
+}
+
+type blockParser struct {
+	source []byte
+	blocks []block
+}
+
+func (p *blockParser) walk(n ast.Node, depth int, quote bool) {
+	for child := n.FirstChild(); child != nil; child = child.NextSibling() {
+		p.visit(child, depth, quote)
+	}
+}
+
+func (p *blockParser) visit(n ast.Node, depth int, quote bool) {
+	switch node := n.(type) {
+	case *ast.Heading:
+		p.blocks = append(p.blocks, block{kind: blockHeading, level: node.Level, runs: p.inline(node, run{})})
+	case *ast.Paragraph, *ast.TextBlock:
+		kind := blockParagraph
+		if quote {
+			kind = blockQuote
+		}
+		p.blocks = append(p.blocks, block{kind: kind, runs: p.inline(node, run{})})
+	case *ast.List:
+		number := node.Start
+		for item := node.FirstChild(); item != nil; item = item.NextSibling() {
+			marker := "•"
+			if node.IsOrdered() {
+				marker = strconv.Itoa(number) + "."
+				number++
+			}
+			p.listItem(item, depth+1, marker, quote)
+		}
+	case *ast.Blockquote:
+		p.walk(node, depth, true)
+	case *ast.FencedCodeBlock, *ast.CodeBlock:
+		p.blocks = append(p.blocks, block{kind: blockCode, code: strings.TrimRight(p.lines(node), "\n")})
+	case *ast.ThematicBreak:
+		p.blocks = append(p.blocks, block{kind: blockRule})
+	case *east.Table:
+		p.blocks = append(p.blocks, p.table(node))
+	case *ast.HTMLBlock:
+		p.blocks = append(p.blocks, block{kind: blockParagraph, runs: []run{{text: strings.TrimSpace(p.lines(node))}}})
+	default:
+		p.walk(node, depth, quote)
+	}
+}
+
+// listItem adds the first paragraph of item as a list item block and visits
+// the rest of its content, so nested lists follow it.
+func (p *blockParser) listItem(item ast.Node, depth int, marker string, quote bool) {
+	first := item.FirstChild()
+	if first == nil || (first.Kind() != ast.KindParagraph && first.Kind() != ast.KindTextBlock) {
+		p.blocks = append(p.blocks, block{kind: blockListItem, level: depth, marker: marker})
+		p.walk(item, depth, quote)
+		return
+	}
+	anchor, _ := item.AttributeString("id")
+	name, _ := anchor.([]byte)
+	p.blocks = append(p.blocks, block{kind: blockListItem, level: depth, marker: marker, anchor: string(name), runs: p.inline(first, run{})})
+	for rest := first.NextSibling(); rest != nil; rest = rest.NextSibling() {
+		p.visit(rest, depth, quote)
+	}
+}
+
+func (p *blockParser) table(node *east.Table) block {
+	b := block{kind: blockTable}
+	for row := node.FirstChild(); row != nil; row = row.NextSibling() {
+		header := row.Kind() == east.KindTableHeader
+		var cells [][]run
+		for cell := row.FirstChild(); cell != nil; cell = cell.NextSibling() {
+			cells = append(cells, p.inline(cell, run{bold: header}))
+		}
+		b.rows = append(b.rows, cells)
+	}
+	return b
+}
+
+func (p *blockParser) lines(n ast.Node) string {
+	var b strings.Builder
+	lines := n.Lines()
+	for i := 0; i < lines.Len(); i++ {
+		segment := lines.At(i)
+		b.Write(segment.Value(p.source))
+	}
+	return b.String()
+}
+
+// inline flattens the inline children of n into runs styled after style.
+func (p *blockParser) inline(n ast.Node, style run) []run {
+	var runs []run
+	for child := n.FirstChild(); child != nil; child = child.NextSibling() {
+		switch node := child.(type) {
+		case *ast.Text:
+			r := style
+			r.text = string(node.Segment.Value(p.source))
+			if !style.code {
+				r.text = string(util.UnescapePunctuations([]byte(r.text)))
+			}
+			runs = append(runs, r)
+			if node.SoftLineBreak() || node.HardLineBreak() {
+				r.text = " "
+				if node.HardLineBreak() {
+					r.text = "\n"
+				}
+				runs = append(runs, r)
+			}
+		case *ast.String:
+			r := style
+			r.text = string(node.Value)
+			runs = append(runs, r)
+		case *ast.Emphasis:
+			s := style
+			if node.Level >= 2 {
+				s.bold = true
+			} else {
+				s.italic = true
+			}
+			runs = append(runs, p.inline(node, s)...)
+		case *ast.CodeSpan:
+			s := style
+			s.code = true
+			runs = append(runs, p.inline(node, s)...)
+		case *ast.Link:
+			s := style
+			s.link = string(node.Destination)
+			runs = append(runs, p.inline(node, s)...)
+		case *ast.AutoLink:
+			r := style
+			r.text = string(node.Label(p.source))
+			r.link = string(node.URL(p.source))
+			runs = append(runs, r)
+		case *ast.Image:
+			r := style
+			r.text = plainText(p.inline(node, style))
+			r.link = string(node.Destination)
+			runs = append(runs, r)
+		case *ast.RawHTML:
+			continue
+		default:
+			runs = append(runs, p.inline(node, style)...)
+		}
+	}
+	return runs
+}
+
+func plainText(runs []run) string {
+	var b strings.Builder
+	for _, r := range runs {
+		b.WriteString(r.text)
+	}
+	return b.String()
+}
+
+var citationMarker = regexp.MustCompile(`\[(\d+)\]`)
+
+// citationAnchor names the reference of the citation id.
+func citationAnchor(id int) string {
+	return fmt.Sprintf("ref-%d", id)
+}
+
+// citationLinks gives every reference of a report an anchor and turns the
+// citation markers of its text, e.g. [2], into links to them.
+type citationLinks struct {
+	// citations are in the order of the references, which end the report.
+	citations []Citation
+}
+
+func (c citationLinks) Transform(node *ast.Document, reader text.Reader, pc parser.Context) {
+	if len(c.citations) == 0 {
+		return
+	}
+	references, ok := node.LastChild().(*ast.List)
+	if !ok {
+		return
+	}
+	ids := make(map[int]bool, len(c.citations))
+	item := references.FirstChild()
+	for _, citation := range c.citations {
+		ids[citation.ID] = true
+		if item != nil {
+			item.SetAttributeString("id", []byte(citationAnchor(citation.ID)))
+			item = item.NextSibling()
+		}
+	}
+
+	// the parser splits text at every bracket, so the markers are looked
+	// for in the text nodes of a parent joined back together.
+	var parents []ast.Node
+	_ = ast.Walk(node, func(n ast.Node, entering bool) (ast.WalkStatus, error) {
+		if !entering {
+			return ast.WalkContinue, nil
+		}
+		switch n.Kind() {
+		case ast.KindLink, ast.KindCodeSpan, ast.KindCodeBlock, ast.KindFencedCodeBlock:
+			return ast.WalkSkipChildren, nil
+		case ast.KindText:
+			if parent := n.Parent(); len(parents) == 0 || parents[len(parents)-1] != parent {
+				parents = append(parents, parent)
+			}
+		}
+		if n == ast.Node(references) {
+			return ast.WalkSkipChildren, nil
+		}
+		return ast.WalkContinue, nil
+	})
+	source := reader.Source()
+	for _, parent := range parents {
+		c.link(parent, source, ids)
+	}
+}
+
+// link replaces the markers of cited ids among the children of parent.
+func (c citationLinks) link(parent ast.Node, source []byte, ids map[int]bool) {
+	child := parent.FirstChild()
+	for child != nil {
+		first, ok := child.(*ast.Text)
+		if !ok {
+			child = child.NextSibling()
+			continue
+		}
+		// join the run of text nodes that follow each other in the source
+		last := first
+		for !last.SoftLineBreak() && !last.HardLineBreak() {
+			next, ok := last.NextSibling().(*ast.Text)
+			if !ok || next.Segment.Start != last.Segment.Stop {
+				break
+			}
+			last = next
+		}
+		child = last.NextSibling()
+
+		start, stop := first.Segment.Start, last.Segment.Stop
+		var nodes []ast.Node
+		offset := start
+		for _, match := range citationMarker.FindAllSubmatchIndex(source[start:stop], -1) {
+			id, err := strconv.Atoi(string(source[start+match[2] : start+match[3]]))
+			if err != nil || !ids[id] {
+				continue
+			}
+			if start+match[0] > offset {
+				nodes = append(nodes, ast.NewTextSegment(text.NewSegment(offset, start+match[0])))
+			}
+			link := ast.NewLink()
+			link.Destination = []byte("#" + citationAnchor(id))
+			link.AppendChild(link, ast.NewTextSegment(text.NewSegment(start+match[0], start+match[1])))
+			nodes = append(nodes, link)
+			offset = start + match[1]
+		}
+		if len(nodes) == 0 {
+			continue
+		}
+		if offset < stop || last.SoftLineBreak() || last.HardLineBreak() {
+			rest := ast.NewTextSegment(text.NewSegment(offset, stop))
+			rest.SetSoftLineBreak(last.SoftLineBreak())
+			rest.SetHardLineBreak(last.HardLineBreak())
+			nodes = append(nodes, rest)
+		}
+
+		for _, n := range nodes {
+			parent.InsertBefore(parent, first, n)
+		}
+		for n := ast.Node(first); n != child; {
+			next := n.NextSibling()
+			parent.RemoveChild(parent, n)
+			n = next
+		}
+	}
+}
@@ -0,0 +1,263 @@
+package report
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"strings"
+
+	"github.com/go-pdf/fpdf"
+)
+
+const (
+	pdfBodySize   = 11
+	pdfCodeSize   = 9
+	pdfIndent     = 6
+	pdfLineFactor = 0.5 // line height in mm per point of font size
+)
+
+var pdfHeadingSizes = map[int]float64{1: 20, 2: 16, 3: 13}
+
+type pdfWriter struct {
+	pdf *fpdf.Fpdf
+	// family is the font of the text; code always uses Courier.
+	family  string
+	unicode bool
+	cp1252  func(string) string
+	margin  float64
+	// anchors maps the anchors of the report to their internal links.
+	anchors map[string]int
+}
+
+func writePDF(w io.Writer, r *Report, opts options) error {
+	pdf := fpdf.New("P", "mm", "A4", "")
+	pw := &pdfWriter{
+		pdf:     pdf,
+		family:  "Helvetica",
+		cp1252:  pdf.UnicodeTranslatorFromDescriptor(""),
+		anchors: make(map[string]int),
+	}
+	if opts.pdfFont != "" {
+		// the core fonts only cover cp1252, a TrueType font is needed for
+		// anything else. AddUTF8Font resolves paths against the font
+		// directory, so the font is read here instead.
+		font, err := os.ReadFile(opts.pdfFont)
+		if err != nil {
+			return fmt.Errorf("read pdf font: %w", err)
+		}
+		for _, style := range []string{"", "B", "I", "BI"} {
+			pdf.AddUTF8FontFromBytes("report", style, font)
+		}
+		if err := pdf.Error(); err != nil {
+			return fmt.Errorf("load pdf font %s: %w", opts.pdfFont, err)
+		}
+		pw.family, pw.unicode = "report", true
+	}
+	pdf.SetTitle(r.Title, true)
+	pdf.SetCreator("tiny-research", true)
+	if !r.Metadata.CreatedAt.IsZero() {
+		pdf.SetCreationDate(r.Metadata.CreatedAt)
+	}
+	pdf.SetMargins(20, 20, 20)
+	pdf.SetAutoPageBreak(true, 20)
+	pdf.AddPage()
+	pw.margin, _, _, _ = pdf.GetMargins()
+
+	for _, b := range parse(r) {
+		pw.block(b)
+		if pdf.Err() {
+			break
+		}
+	}
+	return pdf.Output(w)
+}
+
+func (pw *pdfWriter) block(b block) {
+	pdf := pw.pdf
+	switch b.kind {
+	case blockHeading:
+		size, ok := pdfHeadingSizes[b.level]
+		if !ok {
+			size = 12
+		}
+		pdf.Ln(size * 0.3)
+		pw.runs(b.runs, size, run{bold: true})
+		pdf.Ln(size*pdfLineFactor + 1)
+	case blockParagraph:
+		pw.runs(b.runs, pdfBodySize, run{})
+		pdf.Ln(pdfBodySize*pdfLineFactor + 2)
+	case blockListItem:
+		if b.anchor != "" {
+			pdf.SetLink(pw.anchor(b.anchor), -1, -1)
+		}
+		indent := pw.margin + float64(b.level-1)*pdfIndent
+		pdf.SetLeftMargin(indent)
+		pdf.SetX(indent)
+		pw.runs([]run{{text: b.marker}}, pdfBodySize, run{})
+		pdf.SetLeftMargin(indent + pdfIndent)
+		pdf.SetX(indent + pdfIndent)
+		pw.runs(b.runs, pdfBodySize, run{})
+		pdf.Ln(pdfBodySize*pdfLineFactor + 1)
+		pdf.SetLeftMargin(pw.margin)
+	case blockQuote:
+		pdf.SetLeftMargin(pw.margin + pdfIndent)
+		pdf.SetX(pw.margin + pdfIndent)
+		top, page := pdf.GetY(), pdf.PageNo()
+		pdf.SetTextColor(89, 99, 110)
+		pw.runs(b.runs, pdfBodySize, run{italic: true})
+		pdf.SetTextColor(0, 0, 0)
+		pdf.Ln(pdfBodySize * pdfLineFactor)
+		if pdf.PageNo() == page {
+			pdf.SetDrawColor(209, 217, 224)
+			pdf.Line(pw.margin+2, top, pw.margin+2, pdf.GetY())
+		}
+		pdf.Ln(2)
+		pdf.SetLeftMargin(pw.margin)
+	case blockCode:
+		pdf.SetFont("Courier", "", pdfCodeSize)
+		pdf.SetFillColor(246, 248, 250)
+		pdf.MultiCell(0, pdfCodeSize*pdfLineFactor, pw.cp1252(b.code), "", "L", true)
+		pdf.Ln(3)
+	case blockTable:
+		pw.table(b.rows)
+		pdf.Ln(3)
+	case blockRule:
+		pageWidth, _ := pdf.GetPageSize()
+		y := pdf.GetY() + 2
+		pdf.SetDrawColor(209, 217, 224)
+		pdf.Line(pw.margin, y, pageWidth-pw.margin, y)
+		pdf.SetY(y + 4)
+	}
+}
+
+// runs writes inline text, wrapping at the left margin.
+func (pw *pdfWriter) runs(runs []run, size float64, base run) {
+	pdf := pw.pdf
+	height := size * pdfLineFactor
+	for _, r := range runs {
+		r.bold = r.bold || base.bold
+		r.italic = r.italic || base.italic
+		pw.font(r, size)
+		if r.link != "" {
+			pdf.SetTextColor(9, 105, 218)
+			if anchor, ok := strings.CutPrefix(r.link, "#"); ok {
+				pdf.WriteLinkID(height, pw.text(r), pw.anchor(anchor))
+			} else {
+				pdf.WriteLinkString(height, pw.text(r), r.link)
+			}
+			pdf.SetTextColor(0, 0, 0)
+			continue
+		}
+		pdf.Write(height, pw.text(r))
+	}
+}
+
+// anchor returns the internal link of the anchor name, which is placed once
+// the writer reaches it.
+func (pw *pdfWriter) anchor(name string) int {
+	link, ok := pw.anchors[name]
+	if !ok {
+		link = pw.pdf.AddLink()
+		pw.anchors[name] = link
+	}
+	return link
+}
+
+func (pw *pdfWriter) table(rows [][][]run) {
+	pdf := pw.pdf
+	columns := 0
+	for _, row := range rows {
+		columns = max(columns, len(row))
+	}
+	if columns == 0 {
+		return
+	}
+	pageWidth, pageHeight := pdf.GetPageSize()
+	_, bottom := pdf.GetAutoPageBreak()
+	width := (pageWidth - 2*pw.margin) / float64(columns)
+	height := pdfBodySize * pdfLineFactor * 0.9
+	pdf.SetDrawColor(209, 217, 224)
+	pdf.SetFillColor(246, 248, 250)
+
+	for i, row := range rows {
+		header := i == 0
+		cells := make([][]string, columns)
+		lines := 1
+		for j := range cells {
+			var r run
+			if j < len(row) {
+				r = run{text: plainText(row[j]), bold: header}
+			}
+			pw.font(r, pdfBodySize-1)
+			cells[j] = pw.split(r, width-2)
+			lines = max(lines, len(cells[j]))
+		}
+		rowHeight := float64(lines)*height + 2
+		if pdf.GetY()+rowHeight > pageHeight-bottom {
+			pdf.AddPage()
+		}
+		y := pdf.GetY()
+		for j, cell := range cells {
+			x := pw.margin + float64(j)*width
+			style := "D"
+			if header {
+				style = "FD"
+			}
+			pdf.Rect(x, y, width, rowHeight, style)
+			pw.font(run{bold: header}, pdfBodySize-1)
+			pdf.SetXY(x+1, y+1)
+			pdf.MultiCell(width-2, height, strings.Join(cell, "\n"), "", "L", false)
+		}
+		pdf.SetXY(pw.margin, y+rowHeight)
+	}
+}
+
+func (pw *pdfWriter) font(r run, size float64) {
+	style := ""
+	if r.bold {
+		style += "B"
+	}
+	if r.italic {
+		style += "I"
+	}
+	if r.code {
+		pw.pdf.SetFont("Courier", style, size*0.9)
+		return
+	}
+	pw.pdf.SetFont(pw.family, style, size)
+}
+
+// text encodes the text of r for its font.
+func (pw *pdfWriter) text(r run) string {
+	if pw.unicode && !r.code {
+		return r.text
+	}
+	return pw.cp1252(r.text)
+}
+
+// split wraps the text of r to width. SplitText indexes the character widths
+// by rune, so cp1252 text goes through it one byte per rune.
+func (pw *pdfWriter) split(r run, width float64) []string {
+	if pw.unicode && !r.code {
+		return pw.pdf.SplitText(strings.Map(func(c rune) rune {
+			if c > 0xffff {
+				return '?'
+			}
+			return c
+		}, r.text), width)
+	}
+	encoded := pw.cp1252(r.text)
+	runes := make([]rune, len(encoded))
+	for i := 0; i < len(encoded); i++ {
+		runes[i] = rune(encoded[i])
+	}
+	lines := pw.pdf.SplitText(string(runes), width)
+	for i, line := range lines {
+		bytes := make([]byte, 0, len(line))
+		for _, c := range line {
+			bytes = append(bytes, byte(c))
+		}
+		lines[i] = string(bytes)
+	}
+	return lines
+}
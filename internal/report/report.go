@@ -0,0 +1,260 @@
+package report
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+)
+
+// Format is an output format of a report.
+type Format string
+
+const (
+	FormatMarkdown Format = "md"
+	FormatHTML     Format = "html"
+	FormatPDF      Format = "pdf"
+	FormatDOCX     Format = "docx"
+	FormatJSON     Format = "json"
+)
+
+// Formats lists the supported output formats.
+var Formats = []Format{FormatMarkdown, FormatHTML, FormatPDF, FormatDOCX, FormatJSON}
+
+// ParseFormat returns the format named s, accepting the usual aliases.
+func ParseFormat(s string) (Format, error) {
+	switch strings.ToLower(strings.TrimPrefix(s, ".")) {
+	case "md", "markdown":
+		return FormatMarkdown, nil
+	case "html", "htm":
+		return FormatHTML, nil
+	case "pdf":
+		return FormatPDF, nil
+	case "docx", "word":
+		return FormatDOCX, nil
+	case "json":
+		return FormatJSON, nil
+	}
+	return "", fmt.Errorf("unknown report format %q, expected one of %v", s, Formats)
+}
+
+// FormatFromPath returns the format matching the extension of path.
+func FormatFromPath(path string) (Format, error) {
+	return ParseFormat(filepath.Ext(path))
+}
+
+// Content is what the reporter writes. The validator tags double as the JSON
+// Schema the reporter is asked to follow.
+type Content struct {
+	Title     string     `json:"title" validate:"required"`
+	KeyPoints []string   `json:"key_points"`
+	Sections  []Section  `json:"sections" validate:"required,min=1,dive"`
+	Citations []Citation `json:"citations" validate:"dive"`
+}
+
+// Section is a heading of the report and the markdown under it.
+type Section struct {
+	Heading string  `json:"heading" validate:"required"`
+	Body    string  `json:"body"`
+	Tables  []Table `json:"tables" validate:"dive"`
+}
+
+// Table is tabular data shown after the body of a section.
+type Table struct {
+	Headers []string   `json:"headers" validate:"min=1"`
+	Rows    [][]string `json:"rows"`
+}
+
+// Citation is a source of the report. Sections refer to it by ID, e.g. [2].
+type Citation struct {
+	ID    int    `json:"id" validate:"gt=0"`
+	Title string `json:"title" validate:"required"`
+	URL   string `json:"url" validate:"required"`
+}
+
+// Step is a plan step along with what its execution found.
+type Step struct {
+	Title       string `json:"title"`
+	Description string `json:"description"`
+	Type        string `json:"type"`
+	Result      string `json:"result,omitempty"`
+}
+
+// Plan is the research plan the report was written from.
+type Plan struct {
+	Title   string `json:"title"`
+	Thought string `json:"thought"`
+	Steps   []Step `json:"steps"`
+}
+
+// Source is a search query, crawled URL or local search of the research.
+type Source struct {
+	Tool  string `json:"tool"`
+	Input string `json:"input"`
+}
+
+// Claim is a key claim of the findings and how it held up against the
+// sources.
+type Claim struct {
+	Claim       string   `json:"claim"`
+	Verdict     string   `json:"verdict"`
+	Explanation string   `json:"explanation,omitempty"`
+	Sources     []string `json:"sources,omitempty"`
+}
+
+// Metadata describes the research run that produced a report.
+type Metadata struct {
+	Query      string        `json:"query,omitempty"`
+	Style      string        `json:"style,omitempty"`
+	Model      string        `json:"model,omitempty"`
+	SessionID  string        `json:"session_id,omitempty"`
+	Turn       int           `json:"turn,omitempty"`
+	CreatedAt  time.Time     `json:"created_at"`
+	Duration   time.Duration `json:"duration"`
+	ToolCalls  int           `json:"tool_calls"`
+	Incomplete bool          `json:"incomplete"`
+	Revisions  int           `json:"revisions,omitempty"`
+	Transcript string        `json:"transcript,omitempty"`
+	Plan       *Plan         `json:"plan,omitempty"`
+	Claims     []Claim       `json:"claims,omitempty"`
+	Sources    []Source      `json:"sources,omitempty"`
+}
+
+// Report is a finished report along with the research that produced it.
+type Report struct {
+	Content
+	// KeyPointsHeading and CitationsHeading name the sections the key points
+	// and citations are rendered under; the key points are left out of the
+	// markdown without a heading.
+	KeyPointsHeading string `json:"key_points_heading,omitempty"`
+	CitationsHeading string `json:"citations_heading,omitempty"`
+	// Notice is shown above the report, e.g. when the research stopped early.
+	Notice   string   `json:"notice,omitempty"`
+	Metadata Metadata `json:"metadata"`
+}
+
+// Markdown renders the report.
+func (r *Report) Markdown() string {
+	var b strings.Builder
+	if r.Title != "" {
+		fmt.Fprintf(&b, "# %s\n\n", r.Title)
+	}
+	if r.Notice != "" {
+		fmt.Fprintf(&b, "> %s\n\n", strings.ReplaceAll(r.Notice, "\n", "\n> "))
+	}
+	if r.KeyPointsHeading != "" && len(r.KeyPoints) > 0 {
+		fmt.Fprintf(&b, "## %s\n\n", r.KeyPointsHeading)
+		for _, point := range r.KeyPoints {
+			fmt.Fprintf(&b, "- %s\n", point)
+		}
+		b.WriteString("\n")
+	}
+	for _, section := range r.Sections {
+		if section.Heading != "" {
+			fmt.Fprintf(&b, "## %s\n\n", section.Heading)
+		}
+		if body := strings.TrimSpace(section.Body); body != "" {
+			b.WriteString(body + "\n\n")
+		}
+		for _, table := range section.Tables {
+			writeTable(&b, table)
+		}
+	}
+	if len(r.Citations) > 0 {
+		heading := r.CitationsHeading
+		if heading == "" {
+			heading = "References"
+		}
+		fmt.Fprintf(&b, "## %s\n\n", heading)
+		for _, citation := range r.sortedCitations() {
+			fmt.Fprintf(&b, "%d. [%s](%s)\n", citation.ID, escapeLinkText(citation.Title), citation.URL)
+		}
+	}
+	return strings.TrimSpace(b.String()) + "\n"
+}
+
+// sortedCitations returns the citations in the order of the references.
+func (r *Report) sortedCitations() []Citation {
+	citations := append([]Citation(nil), r.Citations...)
+	sort.SliceStable(citations, func(i, j int) bool { return citations[i].ID < citations[j].ID })
+	return citations
+}
+
+func writeTable(b *strings.Builder, table Table) {
+	columns := len(table.Headers)
+	if columns == 0 {
+		return
+	}
+	row := func(cells []string) {
+		b.WriteString("|")
+		for i := 0; i < columns; i++ {
+			cell := ""
+			if i < len(cells) {
+				cell = cells[i]
+			}
+			fmt.Fprintf(b, " %s |", escapeCell(cell))
+		}
+		b.WriteString("\n")
+	}
+	row(table.Headers)
+	b.WriteString("|" + strings.Repeat("---|", columns) + "\n")
+	for _, cells := range table.Rows {
+		row(cells)
+	}
+	b.WriteString("\n")
+}
+
+func escapeCell(s string) string {
+	s = strings.ReplaceAll(s, "|", "\\|")
+	return strings.Join(strings.Fields(s), " ")
+}
+
+func escapeLinkText(s string) string {
+	return strings.NewReplacer("[", "\\[", "]", "\\]").Replace(s)
+}
+
+type options struct {
+	pdfFont string
+}
+
+// Option configures how a document is exported.
+type Option func(*options)
+
+// WithPDFFont embeds the TrueType font at path in PDF reports. Without it
+// PDFs use the core Helvetica font, which only covers Western European
+// characters.
+func WithPDFFont(path string) Option {
+	return func(o *options) {
+		o.pdfFont = path
+	}
+}
+
+// Export writes the report to w in the given format. Every format but JSON is
+// rendered from the markdown of the report.
+func (r *Report) Export(w io.Writer, format Format, opts ...Option) error {
+	var o options
+	for _, opt := range opts {
+		opt(&o)
+	}
+
+	switch format {
+	case FormatMarkdown:
+		_, err := io.WriteString(w, r.Markdown())
+		return err
+	case FormatHTML:
+		return writeHTML(w, r)
+	case FormatPDF:
+		return writePDF(w, r, o)
+	case FormatDOCX:
+		return writeDOCX(w, r)
+	case FormatJSON:
+		encoder := json.NewEncoder(w)
+		encoder.SetEscapeHTML(false)
+		encoder.SetIndent("", "  ")
+		return encoder.Encode(r)
+	}
+	return fmt.Errorf("unknown report format %q, expected one of %v", format, Formats)
+}
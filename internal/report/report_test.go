@@ -0,0 +1,240 @@
+package report
+
+import (
+	"archive/zip"
+	"bytes"
+	"encoding/json"
+	"io"
+	"reflect"
+	"regexp"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/yuin/goldmark/text"
+	"golang.org/x/net/html"
+)
+
+func testReport() *Report {
+	return &Report{
+		Content: Content{
+			Title:     "Solar in 2024",
+			KeyPoints: []string{"Capacity grew [1]"},
+			Sections: []Section{
+				{
+					Heading: "Growth",
+					Body:    "Capacity grew by a third [1], led by China [3][2].\nCosts fell **[2]**, see `[1]` and [9].",
+					Tables: []Table{{
+						Headers: []string{"Country", "GW"},
+						Rows:    [][]string{{"China", "277 [3]"}, {"US | EU", "40"}},
+					}},
+				},
+				{Heading: "Outlook", Body: "Growth slows [2]."},
+			},
+			Citations: []Citation{
+				{ID: 3, Title: "Ember [review]", URL: "https://ember.example/review"},
+				{ID: 1, Title: "IEA Renewables", URL: "https://iea.example/renewables"},
+				{ID: 2, Title: "BNEF", URL: "https://bnef.example/costs?a=1&b=2"},
+			},
+		},
+		KeyPointsHeading: "Key Points",
+		Notice:           "The research stopped early.",
+		Metadata: Metadata{
+			Query:     "solar capacity 2024",
+			CreatedAt: time.Date(2024, 5, 1, 12, 0, 0, 0, time.UTC),
+			Duration:  90 * time.Second,
+			ToolCalls: 7,
+			Plan:      &Plan{Title: "Solar", Steps: []Step{{Title: "Search", Type: "research", Result: "found"}}},
+			Sources:   []Source{{Tool: "web_search", Input: "solar 2024"}},
+		},
+	}
+}
+
+func export(t *testing.T, r *Report, format Format) []byte {
+	t.Helper()
+	var b bytes.Buffer
+	if err := r.Export(&b, format); err != nil {
+		t.Fatalf("export %s: %v", format, err)
+	}
+	return b.Bytes()
+}
+
+func TestExportMarkdown(t *testing.T) {
+	r := testReport()
+	source := export(t, r, FormatMarkdown)
+	p := &blockParser{source: source}
+	p.walk(newMarkdown().Parser().Parse(text.NewReader(source)), 0, false)
+
+	var headings []string
+	var table [][]string
+	references := map[string]string{}
+	for _, b := range p.blocks {
+		switch b.kind {
+		case blockHeading:
+			headings = append(headings, plainText(b.runs))
+		case blockTable:
+			for _, row := range b.rows {
+				var cells []string
+				for _, cell := range row {
+					cells = append(cells, plainText(cell))
+				}
+				table = append(table, cells)
+			}
+		case blockListItem:
+			if b.marker != "•" {
+				references[b.marker] = plainText(b.runs) + " " + b.runs[0].link
+			}
+		}
+	}
+
+	wantHeadings := []string{"Solar in 2024", "Key Points", "Growth", "Outlook", "References"}
+	if !reflect.DeepEqual(headings, wantHeadings) {
+		t.Errorf("headings = %q, want %q", headings, wantHeadings)
+	}
+	wantTable := [][]string{{"Country", "GW"}, {"China", "277 [3]"}, {"US | EU", "40"}}
+	if !reflect.DeepEqual(table, wantTable) {
+		t.Errorf("table = %q, want %q", table, wantTable)
+	}
+	wantReferences := map[string]string{
+		"1.": "IEA Renewables https://iea.example/renewables",
+		"2.": "BNEF https://bnef.example/costs?a=1&b=2",
+		"3.": "Ember [review] https://ember.example/review",
+	}
+	if !reflect.DeepEqual(references, wantReferences) {
+		t.Errorf("references = %q, want %q", references, wantReferences)
+	}
+	if !bytes.Contains(source, []byte("Capacity grew by a third [1], led by China [3][2].")) {
+		t.Errorf("markdown lost the citation markers:\n%s", source)
+	}
+}
+
+func TestExportHTML(t *testing.T) {
+	r := testReport()
+	doc, err := html.Parse(bytes.NewReader(export(t, r, FormatHTML)))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var title string
+	var markers []string
+	anchors := map[string]string{}
+	var visit func(n *html.Node)
+	visit = func(n *html.Node) {
+		if n.Type == html.ElementNode {
+			switch n.Data {
+			case "title":
+				title = textContent(n)
+			case "a":
+				if href := attr(n, "href"); strings.HasPrefix(href, "#") {
+					markers = append(markers, textContent(n)+href)
+					if attr(n, "target") != "" {
+						t.Errorf("link to %s opens a new tab", href)
+					}
+				} else if attr(n, "target") != "_blank" {
+					t.Errorf("link to %s does not open a new tab", href)
+				}
+			case "li":
+				if id := attr(n, "id"); id != "" {
+					anchors[id] = textContent(n)
+				}
+			}
+		}
+		for child := n.FirstChild; child != nil; child = child.NextSibling {
+			visit(child)
+		}
+	}
+	visit(doc)
+
+	if title != r.Title {
+		t.Errorf("title = %q, want %q", title, r.Title)
+	}
+	wantAnchors := map[string]string{"ref-1": "IEA Renewables", "ref-2": "BNEF", "ref-3": "Ember [review]"}
+	if !reflect.DeepEqual(anchors, wantAnchors) {
+		t.Errorf("anchors = %q, want %q", anchors, wantAnchors)
+	}
+	// the marker in code and the one without a source stay plain text
+	wantMarkers := []string{"[1]#ref-1", "[1]#ref-1", "[3]#ref-3", "[2]#ref-2", "[2]#ref-2", "[3]#ref-3", "[2]#ref-2"}
+	if !reflect.DeepEqual(markers, wantMarkers) {
+		t.Errorf("markers = %q, want %q", markers, wantMarkers)
+	}
+}
+
+func TestExportJSON(t *testing.T) {
+	r := testReport()
+	var got Report
+	if err := json.Unmarshal(export(t, r, FormatJSON), &got); err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(&got, r) {
+		t.Errorf("round trip = %+v, want %+v", got, *r)
+	}
+}
+
+func TestExportDOCX(t *testing.T) {
+	data := export(t, testReport(), FormatDOCX)
+	archive, err := zip.NewReader(bytes.NewReader(data), int64(len(data)))
+	if err != nil {
+		t.Fatal(err)
+	}
+	f, err := archive.Open("word/document.xml")
+	if err != nil {
+		t.Fatal(err)
+	}
+	document, err := io.ReadAll(f)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	bookmarks := map[string]bool{}
+	for _, match := range regexp.MustCompile(`<w:bookmarkStart w:id="\d+" w:name="([^"]+)"/>`).FindAllSubmatch(document, -1) {
+		bookmarks[string(match[1])] = true
+	}
+	if len(bookmarks) != 3 {
+		t.Errorf("bookmarks = %v, want one per reference", bookmarks)
+	}
+	links := regexp.MustCompile(`<w:hyperlink w:anchor="([^"]+)">`).FindAllSubmatch(document, -1)
+	if len(links) != 7 {
+		t.Errorf("got %d links to references, want 7", len(links))
+	}
+	for _, match := range links {
+		if !bookmarks[string(match[1])] {
+			t.Errorf("link to missing bookmark %s", match[1])
+		}
+	}
+}
+
+func TestExportPDF(t *testing.T) {
+	data := export(t, testReport(), FormatPDF)
+	if !bytes.HasPrefix(data, []byte("%PDF-")) {
+		t.Fatalf("not a pdf: %.20q", data)
+	}
+	// tables are written as plain text, so the marker in the table is not
+	// linked
+	if n := bytes.Count(data, []byte("/Dest ")); n != 6 {
+		t.Errorf("got %d links to references, want 6", n)
+	}
+}
+
+func attr(n *html.Node, key string) string {
+	for _, a := range n.Attr {
+		if a.Key == key {
+			return a.Val
+		}
+	}
+	return ""
+}
+
+func textContent(n *html.Node) string {
+	var b strings.Builder
+	var visit func(n *html.Node)
+	visit = func(n *html.Node) {
+		if n.Type == html.TextNode {
+			b.WriteString(n.Data)
+		}
+		for child := n.FirstChild; child != nil; child = child.NextSibling {
+			visit(child)
+		}
+	}
+	visit(n)
+	return b.String()
+}
@@ -0,0 +1,78 @@
+// Package resilience wraps outbound HTTP calls with retries, rate limiting
+// and circuit breaking, configured per provider.
+package resilience
+
+import (
+	"math/rand"
+	"net/http"
+	"strconv"
+	"time"
+)
+
+// Policy configures how calls to one provider are retried and limited. Zero
+// values disable the corresponding limit.
+type Policy struct {
+	// MaxRetries is the number of retries after the first attempt.
+	MaxRetries int
+	// BaseDelay and MaxDelay bound the exponential backoff between retries.
+	BaseDelay time.Duration
+	MaxDelay  time.Duration
+	// MaxConcurrency caps the number of requests in flight.
+	MaxConcurrency int
+	// RequestsPerMinute caps the request rate.
+	RequestsPerMinute int
+	// FailureThreshold consecutive failures open the circuit for Cooldown.
+	FailureThreshold int
+	Cooldown         time.Duration
+}
+
+// DefaultPolicy returns the policy used when nothing else is configured.
+func DefaultPolicy() Policy {
+	return Policy{
+		MaxRetries:       3,
+		BaseDelay:        500 * time.Millisecond,
+		MaxDelay:         30 * time.Second,
+		FailureThreshold: 5,
+		Cooldown:         30 * time.Second,
+	}
+}
+
+// backoff returns the delay before retry attempt (starting at 1), using
+// exponential backoff with full jitter.
+func (p Policy) backoff(attempt int) time.Duration {
+	delay := p.BaseDelay << (attempt - 1)
+	if delay <= 0 || (p.MaxDelay > 0 && delay > p.MaxDelay) {
+		delay = p.MaxDelay
+	}
+	if delay <= 0 {
+		return 0
+	}
+	return time.Duration(rand.Int63n(int64(delay)) + 1)
+}
+
+// retryable reports whether a response status is worth retrying.
+func retryable(status int) bool {
+	switch status {
+	case http.StatusRequestTimeout, http.StatusTooManyRequests,
+		http.StatusInternalServerError, http.StatusBadGateway,
+		http.StatusServiceUnavailable, http.StatusGatewayTimeout:
+		return true
+	}
+	return false
+}
+
+// retryAfter parses the Retry-After header, given either in seconds or as an
+// HTTP date.
+func retryAfter(resp *http.Response) time.Duration {
+	value := resp.Header.Get("Retry-After")
+	if value == "" {
+		return 0
+	}
+	if seconds, err := strconv.Atoi(value); err == nil && seconds > 0 {
+		return time.Duration(seconds) * time.Second
+	}
+	if at, err := http.ParseTime(value); err == nil {
+		return time.Until(at)
+	}
+	return 0
+}
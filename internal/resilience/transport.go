@@ -0,0 +1,133 @@
+package resilience
+
+import (
+	"fmt"
+	"io"
+	"log/slog"
+	"net/http"
+	"time"
+)
+
+var _ http.RoundTripper = (*Transport)(nil)
+
+// Transport is an http.RoundTripper that applies a Policy to every request
+// sent to one provider.
+type Transport struct {
+	name    string
+	policy  Policy
+	base    http.RoundTripper
+	slots   chan struct{}
+	limiter *rateLimiter
+	breaker *breaker
+}
+
+// NewTransport wraps base, or http.DefaultTransport if base is nil. name
+// identifies the provider in logs and errors.
+func NewTransport(name string, policy Policy, base http.RoundTripper) *Transport {
+	if base == nil {
+		base = http.DefaultTransport
+	}
+	t := &Transport{
+		name:    name,
+		policy:  policy,
+		base:    base,
+		limiter: newRateLimiter(policy.RequestsPerMinute),
+		breaker: newBreaker(policy.FailureThreshold, policy.Cooldown),
+	}
+	if policy.MaxConcurrency > 0 {
+		t.slots = make(chan struct{}, policy.MaxConcurrency)
+	}
+	return t
+}
+
+// NewClient returns an http.Client that sends requests through a Transport.
+func NewClient(name string, policy Policy) *http.Client {
+	return &http.Client{Transport: NewTransport(name, policy, nil)}
+}
+
+// RoundTrip sends a clone of req for every attempt, leaving req untouched. A
+// request whose body cannot be replayed through GetBody is not retried. The
+// circuit breaker counts one failure per request, once its retries are
+// exhausted.
+func (t *Transport) RoundTrip(req *http.Request) (*http.Response, error) {
+	if err := t.breaker.allow(); err != nil {
+		return nil, fmt.Errorf("%s: %w", t.name, err)
+	}
+	resp, err := t.retry(req)
+	if req.Context().Err() != nil {
+		t.breaker.abort()
+	} else {
+		t.breaker.record(err == nil && !retryable(resp.StatusCode))
+	}
+	return resp, err
+}
+
+// retry sends req until it succeeds, fails for good or runs out of retries.
+func (t *Transport) retry(req *http.Request) (*http.Response, error) {
+	ctx := req.Context()
+	replayable := req.Body == nil || req.Body == http.NoBody || req.GetBody != nil
+	for attempt := 0; ; attempt++ {
+		attemptReq := req.Clone(ctx)
+		if attempt > 0 && req.Body != nil && req.Body != http.NoBody {
+			body, err := req.GetBody()
+			if err != nil {
+				return nil, err
+			}
+			attemptReq.Body = body
+		}
+
+		resp, err := t.send(attemptReq)
+		if ctx.Err() != nil {
+			return resp, err
+		}
+
+		var wait time.Duration
+		switch {
+		case err != nil:
+		case retryable(resp.StatusCode):
+			wait = retryAfter(resp)
+		default:
+			return resp, nil
+		}
+		if attempt >= t.policy.MaxRetries || !replayable {
+			return resp, err
+		}
+
+		if resp != nil {
+			_, _ = io.Copy(io.Discard, resp.Body)
+			resp.Body.Close()
+		}
+		if backoff := t.policy.backoff(attempt + 1); backoff > wait {
+			wait = backoff
+		}
+		slog.Warn("retry request", "provider", t.name, "attempt", attempt+1, "error", err, "status", statusCode(resp), "delay", wait)
+		if err := sleep(ctx, wait); err != nil {
+			return nil, err
+		}
+	}
+}
+
+// send performs a single attempt under the concurrency and rate limits.
+func (t *Transport) send(req *http.Request) (*http.Response, error) {
+	ctx := req.Context()
+	if t.slots != nil {
+		select {
+		case t.slots <- struct{}{}:
+			defer func() { <-t.slots }()
+		case <-ctx.Done():
+			return nil, ctx.Err()
+		}
+	}
+	if err := t.limiter.wait(ctx); err != nil {
+		return nil, err
+	}
+
+	return t.base.RoundTrip(req)
+}
+
+func statusCode(resp *http.Response) int {
+	if resp == nil {
+		return 0
+	}
+	return resp.StatusCode
+}
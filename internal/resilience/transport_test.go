@@ -0,0 +1,208 @@
+package resilience
+
+import (
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+// server answers its requests with statuses in turn, repeating the last one,
+// and keeps the body of every request.
+type server struct {
+	statuses   []int
+	retryAfter string
+
+	mu     sync.Mutex
+	bodies []string
+}
+
+func (s *server) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	b, _ := io.ReadAll(r.Body)
+	s.mu.Lock()
+	status := s.statuses[min(len(s.bodies), len(s.statuses)-1)]
+	s.bodies = append(s.bodies, string(b))
+	s.mu.Unlock()
+	if s.retryAfter != "" {
+		w.Header().Set("Retry-After", s.retryAfter)
+	}
+	w.WriteHeader(status)
+}
+
+func (s *server) attempts() int {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return len(s.bodies)
+}
+
+func TestTransportRetries(t *testing.T) {
+	tests := []struct {
+		name         string
+		statuses     []int
+		retryAfter   string
+		body         func() io.Reader
+		wantStatus   int
+		wantAttempts int
+		wantWait     time.Duration
+	}{
+		{name: "succeeds at once", statuses: []int{200}, wantStatus: 200, wantAttempts: 1},
+		{name: "retries a server error", statuses: []int{503, 502, 200}, wantStatus: 200, wantAttempts: 3},
+		{name: "gives up after the last retry", statuses: []int{500}, wantStatus: 500, wantAttempts: 3},
+		{name: "does not retry a client error", statuses: []int{400, 200}, wantStatus: 400, wantAttempts: 1},
+		{name: "honours Retry-After", statuses: []int{429, 200}, retryAfter: "1", wantStatus: 200, wantAttempts: 2, wantWait: time.Second},
+		{
+			name:         "replays the body",
+			statuses:     []int{503, 200},
+			body:         func() io.Reader { return strings.NewReader("query") },
+			wantStatus:   200,
+			wantAttempts: 2,
+		},
+		{
+			name:     "does not retry a body it cannot replay",
+			statuses: []int{503, 200},
+			// a reader of unknown type leaves GetBody unset
+			body:         func() io.Reader { return io.MultiReader(strings.NewReader("query")) },
+			wantStatus:   503,
+			wantAttempts: 1,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			s := &server{statuses: tt.statuses, retryAfter: tt.retryAfter}
+			ts := httptest.NewServer(s)
+			defer ts.Close()
+			client := &http.Client{Transport: NewTransport("test", Policy{MaxRetries: 2}, nil)}
+
+			var body io.Reader
+			if tt.body != nil {
+				body = tt.body()
+			}
+			req, err := http.NewRequest(http.MethodPost, ts.URL, body)
+			if err != nil {
+				t.Fatal(err)
+			}
+			start := time.Now()
+			resp, err := client.Do(req)
+			if err != nil {
+				t.Fatal(err)
+			}
+			resp.Body.Close()
+
+			if resp.StatusCode != tt.wantStatus {
+				t.Errorf("status = %d, want %d", resp.StatusCode, tt.wantStatus)
+			}
+			if got := s.attempts(); got != tt.wantAttempts {
+				t.Errorf("%d attempts, want %d", got, tt.wantAttempts)
+			}
+			if elapsed := time.Since(start); elapsed < tt.wantWait {
+				t.Errorf("took %s, want at least %s", elapsed, tt.wantWait)
+			}
+			if tt.body != nil {
+				for i, got := range s.bodies {
+					if got != "query" {
+						t.Errorf("attempt %d sent body %q, want %q", i+1, got, "query")
+					}
+				}
+			}
+		})
+	}
+}
+
+func TestTransportLeavesTheRequestUntouched(t *testing.T) {
+	s := &server{statuses: []int{503, 200}}
+	ts := httptest.NewServer(s)
+	defer ts.Close()
+	req, err := http.NewRequest(http.MethodPost, ts.URL, strings.NewReader("query"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	body := req.Body
+
+	resp, err := NewTransport("test", Policy{MaxRetries: 2}, nil).RoundTrip(req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp.Body.Close()
+	if req.Body != body {
+		t.Error("the body of the request was replaced")
+	}
+}
+
+func TestTransportBreaker(t *testing.T) {
+	s := &server{statuses: []int{503}}
+	ts := httptest.NewServer(s)
+	defer ts.Close()
+	transport := NewTransport("test", Policy{MaxRetries: 3, FailureThreshold: 2, Cooldown: 50 * time.Millisecond}, nil)
+	get := func() (*http.Response, error) {
+		req, err := http.NewRequest(http.MethodGet, ts.URL, nil)
+		if err != nil {
+			t.Fatal(err)
+		}
+		resp, err := transport.RoundTrip(req)
+		if err == nil {
+			resp.Body.Close()
+		}
+		return resp, err
+	}
+
+	steps := []struct {
+		name         string
+		wait         time.Duration
+		statuses     []int
+		wantOpen     bool
+		wantAttempts int
+	}{
+		// the retries of one request count as a single failure
+		{name: "first failed request", wantAttempts: 4},
+		{name: "second failed request opens the circuit", wantAttempts: 8},
+		{name: "open circuit fails fast", wantOpen: true, wantAttempts: 8},
+		{name: "failed trial after the cooldown opens it again", wait: 60 * time.Millisecond, wantAttempts: 12},
+		{name: "reopened circuit fails fast", wantOpen: true, wantAttempts: 12},
+		{name: "successful trial closes it", wait: 60 * time.Millisecond, statuses: []int{200}, wantAttempts: 13},
+		{name: "closed circuit lets requests through", wantAttempts: 14},
+	}
+	for _, step := range steps {
+		time.Sleep(step.wait)
+		if step.statuses != nil {
+			s.mu.Lock()
+			s.statuses = step.statuses
+			s.mu.Unlock()
+		}
+		_, err := get()
+		if open := errors.Is(err, ErrCircuitOpen); open != step.wantOpen {
+			t.Errorf("%s: error = %v, want circuit open %v", step.name, err, step.wantOpen)
+		}
+		if got := s.attempts(); got != step.wantAttempts {
+			t.Errorf("%s: %d attempts in all, want %d", step.name, got, step.wantAttempts)
+		}
+	}
+}
+
+func TestRetryAfter(t *testing.T) {
+	tests := []struct {
+		name  string
+		value string
+		min   time.Duration
+		max   time.Duration
+	}{
+		{name: "none", value: "", max: 0},
+		{name: "seconds", value: "3", min: 3 * time.Second, max: 3 * time.Second},
+		{name: "date", value: time.Now().Add(time.Minute).UTC().Format(http.TimeFormat), min: 58 * time.Second, max: time.Minute},
+		{name: "invalid", value: "soon", max: 0},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			resp := &http.Response{Header: http.Header{}}
+			if tt.value != "" {
+				resp.Header.Set("Retry-After", tt.value)
+			}
+			if got := retryAfter(resp); got < tt.min || got > tt.max {
+				t.Errorf("retryAfter(%q) = %s, want between %s and %s", tt.value, got, tt.min, tt.max)
+			}
+		})
+	}
+}
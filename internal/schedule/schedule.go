@@ -0,0 +1,124 @@
+// Package schedule reruns saved research queries on cron schedules, stores
+// every report and adds what changed since the previous run to it.
+package schedule
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"os"
+	"regexp"
+	"time"
+
+	"github.com/rickif/tiny-research/internal/agent"
+	"github.com/rickif/tiny-research/internal/report"
+	"github.com/rickif/tiny-research/internal/templates"
+	"github.com/robfig/cron/v3"
+	"gopkg.in/yaml.v3"
+)
+
+// Job is a scheduled research. It researches either Query or the query of
+// Template bound with Vars.
+type Job struct {
+	Name string `yaml:"name"`
+	// Cron is a standard five field cron expression, e.g. "0 8 * * MON", or
+	// a descriptor such as "@daily" or "@every 6h".
+	Cron     string            `yaml:"cron"`
+	Query    string            `yaml:"query"`
+	Style    string            `yaml:"style"`
+	Locale   string            `yaml:"locale"`
+	Template string            `yaml:"template"`
+	Vars     map[string]string `yaml:"vars"`
+	// Format is the format the reports are exported in, besides JSON.
+	Format string `yaml:"format"`
+
+	schedule cron.Schedule
+	query    string
+	options  []agent.ResearchOption
+	format   report.Format
+}
+
+var jobName = regexp.MustCompile(`^[A-Za-z0-9][A-Za-z0-9_.-]*$`)
+
+// Load reads and validates the jobs of a schedule file, a YAML list of Job.
+// Templates are looked up in templatesDir and bound, so that a bad job fails
+// before the scheduler starts.
+func Load(path string, templatesDir string) ([]*Job, error) {
+	b, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	var jobs []*Job
+	decoder := yaml.NewDecoder(bytes.NewReader(b))
+	decoder.KnownFields(true)
+	if err := decoder.Decode(&jobs); err != nil {
+		return nil, fmt.Errorf("parse schedule %s: %w", path, err)
+	}
+	if len(jobs) == 0 {
+		return nil, fmt.Errorf("schedule %s has no jobs", path)
+	}
+
+	names := make(map[string]bool, len(jobs))
+	for i, job := range jobs {
+		if err := job.prepare(templatesDir); err != nil {
+			return nil, fmt.Errorf("schedule %s: job %d: %w", path, i+1, err)
+		}
+		if names[job.Name] {
+			return nil, fmt.Errorf("schedule %s: job name %q is used twice", path, job.Name)
+		}
+		names[job.Name] = true
+	}
+	return jobs, nil
+}
+
+func (job *Job) prepare(templatesDir string) error {
+	if !jobName.MatchString(job.Name) {
+		return fmt.Errorf("invalid name %q, expected letters, digits, '.', '_' or '-'", job.Name)
+	}
+	schedule, err := cron.ParseStandard(job.Cron)
+	if err != nil {
+		return fmt.Errorf("parse cron %q: %w", job.Cron, err)
+	}
+	if schedule.Next(time.Now()).IsZero() {
+		return fmt.Errorf("cron %q never fires", job.Cron)
+	}
+	job.schedule = schedule
+
+	switch {
+	case job.Query != "" && job.Template != "":
+		return errors.New("set either query or template, not both")
+	case job.Template != "":
+		t, err := templates.Find(templatesDir, job.Template)
+		if err != nil {
+			return err
+		}
+		if job.query, job.options, err = t.Bind(job.Vars); err != nil {
+			return err
+		}
+	case job.Query != "":
+		if len(job.Vars) > 0 {
+			return errors.New("vars need a template")
+		}
+		job.query = job.Query
+	default:
+		return errors.New("no query or template")
+	}
+	// like the -style flag, an explicit style overrides the template style
+	if job.Style != "" {
+		if _, err := agent.LookupReportStyle(job.Style); err != nil {
+			return err
+		}
+		job.options = append(job.options, agent.WithReportStyle(job.Style))
+	}
+	if job.Locale != "" {
+		job.options = append(job.options, agent.WithLocale(job.Locale))
+	}
+
+	job.format = report.FormatMarkdown
+	if job.Format != "" {
+		if job.format, err = report.ParseFormat(job.Format); err != nil {
+			return err
+		}
+	}
+	return nil
+}
@@ -0,0 +1,207 @@
+package schedule
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/rickif/tiny-research/internal/agent"
+	"github.com/rickif/tiny-research/internal/report"
+	"github.com/rickif/tiny-research/util"
+)
+
+// stampLayout names the stored reports by creation time, so that they sort
+// chronologically.
+const stampLayout = "20060102T150405Z"
+
+var errNoPrevious = errors.New("no previous report")
+
+// Researcher researches a query and compares two of its reports, like
+// agent.Agent.
+type Researcher interface {
+	Research(ctx context.Context, query string, options ...agent.ResearchOption) (*report.Report, error)
+	Changes(ctx context.Context, previous *report.Report, current *report.Report) (report.Section, error)
+}
+
+// Scheduler runs jobs on their schedules. The reports of a job are stored
+// under dir/<job name>/ as JSON, which is what later runs are compared with,
+// and in the format of the job; latest.<format> is the most recent one.
+type Scheduler struct {
+	researcher    Researcher
+	jobs          []*Job
+	dir           string
+	reportOptions []report.Option
+}
+
+// New creates a scheduler storing the reports of jobs under dir.
+// reportOptions configure their export.
+func New(researcher Researcher, jobs []*Job, dir string, reportOptions ...report.Option) *Scheduler {
+	return &Scheduler{
+		researcher:    researcher,
+		jobs:          jobs,
+		dir:           dir,
+		reportOptions: reportOptions,
+	}
+}
+
+// Run runs the jobs as they come due until ctx is canceled, then waits for
+// the running ones, which are canceled too. A job still running when it comes
+// due again skips that run, and runs missed while the scheduler was not
+// running are not caught up.
+func (s *Scheduler) Run(ctx context.Context) error {
+	var wg sync.WaitGroup
+	defer wg.Wait()
+
+	running := make([]atomic.Bool, len(s.jobs))
+	next := make([]time.Time, len(s.jobs))
+	for i, job := range s.jobs {
+		next[i] = job.schedule.Next(time.Now())
+		slog.Info("job scheduled", "job", job.Name, "cron", job.Cron, "next", next[i])
+	}
+
+	for {
+		i := 0
+		for j := range next {
+			if next[j].Before(next[i]) {
+				i = j
+			}
+		}
+		timer := time.NewTimer(time.Until(next[i]))
+		select {
+		case <-ctx.Done():
+			timer.Stop()
+			slog.Info("scheduler stops")
+			return nil
+		case <-timer.C:
+		}
+
+		job := s.jobs[i]
+		next[i] = job.schedule.Next(time.Now())
+		if !running[i].CompareAndSwap(false, true) {
+			slog.Warn("skip job, previous run still running", "job", job.Name, "next", next[i])
+			continue
+		}
+		wg.Add(1)
+		go func(i int, next time.Time) {
+			defer wg.Done()
+			defer running[i].Store(false)
+			if _, err := s.RunJob(ctx, job.Name); err != nil {
+				slog.Error("run job", "job", job.Name, "error", err)
+			}
+			slog.Info("job scheduled", "job", job.Name, "next", next)
+		}(i, next[i])
+	}
+}
+
+// RunJob runs the job named name now, stores its report and returns it. The
+// report starts with what changed since the last complete run of the job, if
+// any. Incomplete reports are stored but never compared with.
+func (s *Scheduler) RunJob(ctx context.Context, name string) (*report.Report, error) {
+	var job *Job
+	for _, j := range s.jobs {
+		if j.Name == name {
+			job = j
+		}
+	}
+	if job == nil {
+		return nil, fmt.Errorf("no job %q", name)
+	}
+
+	slog.Info("job starts", "job", job.Name, "query", job.query)
+	dir := filepath.Join(s.dir, job.Name)
+	if err := os.MkdirAll(dir, 0o755); err != nil {
+		return nil, err
+	}
+	result, researchErr := s.researcher.Research(ctx, job.query, job.options...)
+	if result == nil {
+		return nil, researchErr
+	}
+
+	if researchErr == nil && !result.Metadata.Incomplete {
+		previous, err := latest(dir)
+		switch {
+		case errors.Is(err, errNoPrevious):
+			slog.Info("first run of job, nothing to compare with", "job", job.Name)
+		case err != nil:
+			slog.Error("load previous report", "job", job.Name, "error", err)
+		default:
+			// the report is complete, a failed comparison only loses the
+			// changes section
+			changes, err := s.researcher.Changes(ctx, previous, result)
+			if err != nil {
+				slog.Error("compare with previous report", "job", job.Name, "error", err)
+				break
+			}
+			result.Sections = append([]report.Section{changes}, result.Sections...)
+		}
+	}
+
+	if err := s.save(dir, job.format, result); err != nil {
+		return result, errors.Join(researchErr, err)
+	}
+	slog.Info("job ends", "job", job.Name, "incomplete", result.Metadata.Incomplete)
+	return result, researchErr
+}
+
+func (s *Scheduler) save(dir string, format report.Format, result *report.Report) error {
+	createdAt := result.Metadata.CreatedAt
+	if createdAt.IsZero() {
+		createdAt = time.Now()
+	}
+	stamp := createdAt.UTC().Format(stampLayout)
+	if err := util.WriteFile(filepath.Join(dir, stamp+".json"), func(f *os.File) error {
+		return result.Export(f, report.FormatJSON)
+	}); err != nil {
+		return err
+	}
+	for _, name := range []string{stamp, "latest"} {
+		if format == report.FormatJSON && name == stamp {
+			continue
+		}
+		if err := util.WriteFile(filepath.Join(dir, name+"."+string(format)), func(f *os.File) error {
+			return result.Export(f, format, s.reportOptions...)
+		}); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// latest loads the most recent complete report stored in dir.
+func latest(dir string) (*report.Report, error) {
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		return nil, err
+	}
+	var stamps []string
+	for _, entry := range entries {
+		stamp, ok := strings.CutSuffix(entry.Name(), ".json")
+		if _, err := time.Parse(stampLayout, stamp); ok && err == nil {
+			stamps = append(stamps, stamp)
+		}
+	}
+	sort.Sort(sort.Reverse(sort.StringSlice(stamps)))
+	for _, stamp := range stamps {
+		b, err := os.ReadFile(filepath.Join(dir, stamp+".json"))
+		if err != nil {
+			return nil, err
+		}
+		var r report.Report
+		if err := json.Unmarshal(b, &r); err != nil {
+			return nil, fmt.Errorf("parse %s.json: %w", stamp, err)
+		}
+		if !r.Metadata.Incomplete {
+			return &r, nil
+		}
+	}
+	return nil, errNoPrevious
+}
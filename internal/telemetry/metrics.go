@@ -0,0 +1,181 @@
+package telemetry
+
+import (
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/collectors"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
+	"github.com/rickif/tiny-research/internal/cache"
+)
+
+// Run statuses, as reported by RunFinished.
+const (
+	RunCompleted  = "completed"
+	RunIncomplete = "incomplete"
+	RunCanceled   = "canceled"
+	RunFailed     = "failed"
+)
+
+const namespace = "tiny_research"
+
+var (
+	registry = prometheus.NewRegistry()
+
+	runsStarted = prometheus.NewCounter(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "runs_started_total",
+		Help:      "Research runs started.",
+	})
+	runsFinished = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "runs_finished_total",
+		Help:      "Research runs finished, by status: completed, incomplete, canceled or failed.",
+	}, []string{"status"})
+	runsActive = prometheus.NewGauge(prometheus.GaugeOpts{
+		Namespace: namespace,
+		Name:      "runs_active",
+		Help:      "Research runs in progress.",
+	})
+	runDuration = prometheus.NewHistogramVec(prometheus.HistogramOpts{
+		Namespace: namespace,
+		Name:      "run_duration_seconds",
+		Help:      "Duration of the research runs, by status.",
+		Buckets:   []float64{10, 30, 60, 120, 300, 600, 1200, 1800, 3600},
+	}, []string{"status"})
+
+	nodeDuration = prometheus.NewHistogramVec(prometheus.HistogramOpts{
+		Namespace: namespace,
+		Name:      "node_duration_seconds",
+		Help:      "Duration of the node executions, by step.",
+		Buckets:   []float64{0.5, 1, 2.5, 5, 10, 30, 60, 120, 300, 600},
+	}, []string{"step"})
+	nodeErrors = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "node_errors_total",
+		Help:      "Failed node executions, by step.",
+	}, []string{"step"})
+
+	llmDuration = prometheus.NewHistogramVec(prometheus.HistogramOpts{
+		Namespace: namespace,
+		Name:      "llm_request_duration_seconds",
+		Help:      "Latency of the LLM calls, by model.",
+		Buckets:   []float64{0.25, 0.5, 1, 2.5, 5, 10, 20, 30, 60, 120},
+	}, []string{"model"})
+	llmErrors = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "llm_errors_total",
+		Help:      "Failed LLM calls, by model.",
+	}, []string{"model"})
+	llmTokens = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "llm_tokens_total",
+		Help:      "Tokens used by the LLM calls, by model and type: input or output.",
+	}, []string{"model", "type"})
+
+	toolCalls = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "tool_calls_total",
+		Help:      "Tool calls, by tool.",
+	}, []string{"tool"})
+	toolErrors = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "tool_errors_total",
+		Help:      "Failed tool calls, by tool.",
+	}, []string{"tool"})
+	toolDuration = prometheus.NewHistogramVec(prometheus.HistogramOpts{
+		Namespace: namespace,
+		Name:      "tool_duration_seconds",
+		Help:      "Duration of the tool calls, by tool.",
+		Buckets:   []float64{0.1, 0.25, 0.5, 1, 2.5, 5, 10, 30, 60, 120},
+	}, []string{"tool"})
+
+	caches = &cacheCollector{
+		hits: prometheus.NewDesc(prometheus.BuildFQName(namespace, "cache", "hits_total"),
+			"Lookups served from the response cache, by kind: llm, search or crawl.", []string{"kind"}, nil),
+		misses: prometheus.NewDesc(prometheus.BuildFQName(namespace, "cache", "misses_total"),
+			"Lookups missing the response cache, by kind: llm, search or crawl.", []string{"kind"}, nil),
+	}
+)
+
+func init() {
+	registry.MustRegister(
+		collectors.NewGoCollector(),
+		collectors.NewProcessCollector(collectors.ProcessCollectorOpts{}),
+		runsStarted, runsFinished, runsActive, runDuration,
+		nodeDuration, nodeErrors,
+		llmDuration, llmErrors, llmTokens,
+		toolCalls, toolErrors, toolDuration,
+		caches,
+	)
+}
+
+// MetricsHandler serves the metrics in the Prometheus exposition format.
+func MetricsHandler() http.Handler {
+	return promhttp.HandlerFor(registry, promhttp.HandlerOpts{Registry: registry})
+}
+
+// RunStarted counts a research run started, which is active until
+// RunFinished.
+func RunStarted() {
+	runsStarted.Inc()
+	runsActive.Inc()
+}
+
+// RunFinished counts a research run finished with status after duration.
+func RunFinished(status string, duration time.Duration) {
+	runsActive.Dec()
+	runsFinished.WithLabelValues(status).Inc()
+	runDuration.WithLabelValues(status).Observe(duration.Seconds())
+}
+
+// NodeFinished records a node execution of step.
+func NodeFinished(step string, duration time.Duration, err error) {
+	nodeDuration.WithLabelValues(step).Observe(duration.Seconds())
+	if err != nil {
+		nodeErrors.WithLabelValues(step).Inc()
+	}
+}
+
+// ObserveCache exports the hit and miss counts of a response cache, summed
+// with those of the caches observed before.
+func ObserveCache(stats func() []cache.Stat) {
+	caches.mu.Lock()
+	defer caches.mu.Unlock()
+	caches.stats = append(caches.stats, stats)
+}
+
+type cacheCollector struct {
+	hits   *prometheus.Desc
+	misses *prometheus.Desc
+
+	mu    sync.Mutex
+	stats []func() []cache.Stat
+}
+
+func (c *cacheCollector) Describe(ch chan<- *prometheus.Desc) {
+	ch <- c.hits
+	ch <- c.misses
+}
+
+func (c *cacheCollector) Collect(ch chan<- prometheus.Metric) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	hits := make(map[string]int)
+	misses := make(map[string]int)
+	for _, stats := range c.stats {
+		for _, stat := range stats() {
+			hits[stat.Kind] += stat.Hits
+			misses[stat.Kind] += stat.Misses
+		}
+	}
+	for kind, n := range hits {
+		ch <- prometheus.MustNewConstMetric(c.hits, prometheus.CounterValue, float64(n), kind)
+	}
+	for kind, n := range misses {
+		ch <- prometheus.MustNewConstMetric(c.misses, prometheus.CounterValue, float64(n), kind)
+	}
+}
@@ -0,0 +1,164 @@
+package telemetry
+
+import (
+	"context"
+	"time"
+
+	"github.com/rickif/tiny-research/internal/transcript"
+	"github.com/tmc/langchaingo/llms"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/trace"
+)
+
+var (
+	_ llms.Model = (*Model)(nil)
+	_ llms.Model = (*ProviderModel)(nil)
+)
+
+// Model wraps an llms.Model and traces every GenerateContent call with the
+// model name, the token usage and the latency. The prompt and the output go
+// to the transcript of the run. It sits above the cache and the cassette, so
+// every call of the nodes is traced and recorded; a call that never reached
+// the ProviderModel beneath them is marked as cached and its tokens are not
+// counted.
+type Model struct {
+	model llms.Model
+	name  string
+}
+
+// NewModel traces the calls to model, whose name is recorded on the spans.
+func NewModel(model llms.Model, name string) *Model {
+	return &Model{model: model, name: name}
+}
+
+func (m *Model) GenerateContent(ctx context.Context, messages []llms.MessageContent, options ...llms.CallOption) (resp *llms.ContentResponse, err error) {
+	ctx, span := Tracer().Start(ctx, "llm.generate_content", trace.WithSpanKind(trace.SpanKindClient), trace.WithAttributes(
+		attribute.String("gen_ai.request.model", m.name),
+		attribute.Int("llm.messages", len(messages)),
+	))
+	defer func() { End(span, err) }()
+
+	if transcript.Enabled(ctx) {
+		transcript.Record(ctx, transcript.Event{
+			Kind:     transcript.KindLLMRequest,
+			Messages: transcript.Messages(messages),
+			Tools:    toolNames(options),
+		})
+	}
+
+	call := &providerCall{}
+	start := time.Now()
+	resp, err = m.model.GenerateContent(context.WithValue(ctx, providerCallKey{}, call), messages, options...)
+	latency := time.Since(start)
+	cached := !call.reached
+	span.SetAttributes(attribute.Int64("llm.latency_ms", latency.Milliseconds()), attribute.Bool("llm.cached", cached))
+	if err != nil {
+		transcript.Record(ctx, transcript.Event{Kind: transcript.KindLLMResponse, Duration: latency, Cached: cached, Error: err.Error()})
+		return nil, err
+	}
+	input, output, total, requested := responseUsage(resp)
+	if transcript.Enabled(ctx) {
+		event := transcript.Event{Kind: transcript.KindLLMResponse, Duration: latency, Cached: cached}
+		if !cached {
+			event.InputTokens, event.OutputTokens = input, output
+		}
+		for _, choice := range resp.Choices {
+			event.Content += choice.Content
+			event.ToolCalls = append(event.ToolCalls, transcript.ToolCalls(choice.ToolCalls)...)
+		}
+		transcript.Record(ctx, event)
+	}
+
+	span.SetAttributes(
+		attribute.Int("gen_ai.usage.input_tokens", input),
+		attribute.Int("gen_ai.usage.output_tokens", output),
+		attribute.Int("gen_ai.usage.total_tokens", total),
+		attribute.Int("llm.tool_calls", requested),
+	)
+	return resp, nil
+}
+
+// ProviderModel wraps the model of the provider, beneath the cache and the
+// cassette, and exports the latency, errors and token usage of the calls
+// that reach it as metrics.
+type ProviderModel struct {
+	model llms.Model
+	name  string
+}
+
+// NewProviderModel measures the calls to model, whose name labels the
+// metrics.
+func NewProviderModel(model llms.Model, name string) *ProviderModel {
+	return &ProviderModel{model: model, name: name}
+}
+
+func (m *ProviderModel) GenerateContent(ctx context.Context, messages []llms.MessageContent, options ...llms.CallOption) (*llms.ContentResponse, error) {
+	if call, ok := ctx.Value(providerCallKey{}).(*providerCall); ok {
+		call.reached = true
+	}
+	start := time.Now()
+	resp, err := m.model.GenerateContent(ctx, messages, options...)
+	llmDuration.WithLabelValues(m.name).Observe(time.Since(start).Seconds())
+	if err != nil {
+		llmErrors.WithLabelValues(m.name).Inc()
+		return nil, err
+	}
+	input, output, _, _ := responseUsage(resp)
+	llmTokens.WithLabelValues(m.name, "input").Add(float64(input))
+	llmTokens.WithLabelValues(m.name, "output").Add(float64(output))
+	return resp, nil
+}
+
+func (m *ProviderModel) Call(ctx context.Context, prompt string, options ...llms.CallOption) (string, error) {
+	return llms.GenerateFromSinglePrompt(ctx, m, prompt, options...)
+}
+
+// providerCall tells a Model whether its call reached the ProviderModel.
+type providerCall struct {
+	reached bool
+}
+
+type providerCallKey struct{}
+
+// responseUsage sums the token usage and the tool calls of the choices of
+// resp.
+func responseUsage(resp *llms.ContentResponse) (input, output, total, toolCalls int) {
+	for _, choice := range resp.Choices {
+		input += usage(choice.GenerationInfo, "PromptTokens")
+		output += usage(choice.GenerationInfo, "CompletionTokens")
+		total += usage(choice.GenerationInfo, "TotalTokens")
+		toolCalls += len(choice.ToolCalls)
+	}
+	return input, output, total, toolCalls
+}
+
+func (m *Model) Call(ctx context.Context, prompt string, options ...llms.CallOption) (string, error) {
+	return llms.GenerateFromSinglePrompt(ctx, m, prompt, options...)
+}
+
+// toolNames returns the names of the tools offered by options.
+func toolNames(options []llms.CallOption) []string {
+	var opts llms.CallOptions
+	for _, option := range options {
+		option(&opts)
+	}
+	var names []string
+	for _, t := range opts.Tools {
+		if t.Function != nil {
+			names = append(names, t.Function.Name)
+		}
+	}
+	return names
+}
+
+// usage reads a token count of the generation info, which holds an int, or a
+// float64 once it went through the JSON of the cache or a cassette.
+func usage(info map[string]any, key string) int {
+	switch n := info[key].(type) {
+	case int:
+		return n
+	case float64:
+		return int(n)
+	}
+	return 0
+}
@@ -0,0 +1,83 @@
+// Package telemetry traces research runs with OpenTelemetry: a span per run,
+// node execution, LLM call and tool call, exported via OTLP or to stdout. It
+// also keeps the Prometheus metrics of the runs, served by MetricsHandler,
+// and writes the LLM and tool calls to the transcript of the run, if any.
+package telemetry
+
+import (
+	"context"
+	"fmt"
+	"os"
+
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/exporters/otlp/otlptrace/otlptracehttp"
+	"go.opentelemetry.io/otel/exporters/stdout/stdouttrace"
+	"go.opentelemetry.io/otel/propagation"
+	"go.opentelemetry.io/otel/sdk/resource"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	semconv "go.opentelemetry.io/otel/semconv/v1.26.0"
+	"go.opentelemetry.io/otel/trace"
+)
+
+const (
+	ExporterNone   = ""
+	ExporterOTLP   = "otlp"
+	ExporterStdout = "stdout"
+
+	serviceName = "tiny-research"
+)
+
+// Tracer returns the tracer of the spans of this module. Until Setup installs
+// an exporter, its spans are not recorded.
+func Tracer() trace.Tracer {
+	return otel.Tracer("github.com/rickif/tiny-research")
+}
+
+// Setup installs the global tracer provider exporting to exporter: "otlp"
+// sends the spans over HTTP to the collector configured by the standard
+// OTEL_EXPORTER_OTLP_* variables (default localhost:4318), "stdout" prints
+// them to stderr, keeping stdout for the report. The returned function flushes the pending spans and must be called
+// before exit.
+func Setup(ctx context.Context, exporter string) (shutdown func(context.Context) error, err error) {
+	var spanExporter sdktrace.SpanExporter
+	switch exporter {
+	case ExporterNone:
+		return func(context.Context) error { return nil }, nil
+	case ExporterOTLP:
+		spanExporter, err = otlptracehttp.New(ctx)
+	case ExporterStdout:
+		spanExporter, err = stdouttrace.New(stdouttrace.WithPrettyPrint(), stdouttrace.WithWriter(os.Stderr))
+	default:
+		return nil, fmt.Errorf("unknown trace exporter %q, expected %s or %s", exporter, ExporterOTLP, ExporterStdout)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("create %s trace exporter: %w", exporter, err)
+	}
+
+	// OTEL_SERVICE_NAME and OTEL_RESOURCE_ATTRIBUTES override the defaults
+	res, err := resource.New(ctx,
+		resource.WithAttributes(semconv.ServiceName(serviceName)),
+		resource.WithFromEnv(),
+		resource.WithTelemetrySDK(),
+	)
+	if err != nil {
+		return nil, fmt.Errorf("create trace resource: %w", err)
+	}
+	provider := sdktrace.NewTracerProvider(
+		sdktrace.WithBatcher(spanExporter),
+		sdktrace.WithResource(res),
+	)
+	otel.SetTracerProvider(provider)
+	otel.SetTextMapPropagator(propagation.NewCompositeTextMapPropagator(propagation.TraceContext{}, propagation.Baggage{}))
+	return provider.Shutdown, nil
+}
+
+// End records err, if any, on span and ends it.
+func End(span trace.Span, err error) {
+	if err != nil {
+		span.RecordError(err)
+		span.SetStatus(codes.Error, err.Error())
+	}
+	span.End()
+}
@@ -0,0 +1,87 @@
+package telemetry
+
+import (
+	"context"
+	"time"
+
+	"github.com/rickif/tiny-research/internal/tool"
+	"github.com/rickif/tiny-research/internal/transcript"
+	"go.opentelemetry.io/otel/attribute"
+)
+
+var (
+	_ tool.Searcher     = (*Searcher)(nil)
+	_ tool.Crawler      = (*Crawler)(nil)
+	_ tool.PythonRunner = (*Python)(nil)
+)
+
+// TraceTool runs call in a span of the tool name, recording its arguments,
+// the size of its output and its error, and counts it in the tool metrics.
+// The full output goes to the transcript of the run.
+func TraceTool(ctx context.Context, name string, args string, call func(ctx context.Context) (string, error)) (output string, err error) {
+	ctx, span := Tracer().Start(ctx, "tool "+name)
+	span.SetAttributes(
+		attribute.String("tool.name", name),
+		attribute.String("tool.args", args),
+	)
+	defer func() { End(span, err) }()
+
+	start := time.Now()
+	output, err = call(ctx)
+	duration := time.Since(start)
+	toolCalls.WithLabelValues(name).Inc()
+	toolDuration.WithLabelValues(name).Observe(duration.Seconds())
+	event := transcript.Event{Kind: transcript.KindToolCall, Tool: name, Args: args, Output: output, Duration: duration}
+	if err != nil {
+		toolErrors.WithLabelValues(name).Inc()
+		event.Error = err.Error()
+	}
+	transcript.Record(ctx, event)
+	span.SetAttributes(attribute.Int("tool.output_bytes", len(output)))
+	return output, err
+}
+
+// Searcher traces the searches of a tool.Searcher.
+type Searcher struct {
+	searcher tool.Searcher
+}
+
+func NewSearcher(searcher tool.Searcher) *Searcher {
+	return &Searcher{searcher: searcher}
+}
+
+func (s *Searcher) Search(ctx context.Context, query string) (string, error) {
+	return TraceTool(ctx, tool.SearchTool.Function.Name, query, func(ctx context.Context) (string, error) {
+		return s.searcher.Search(ctx, query)
+	})
+}
+
+// Crawler traces the crawls of a tool.Crawler.
+type Crawler struct {
+	crawler tool.Crawler
+}
+
+func NewCrawler(crawler tool.Crawler) *Crawler {
+	return &Crawler{crawler: crawler}
+}
+
+func (c *Crawler) Crawl(ctx context.Context, url string) (string, error) {
+	return TraceTool(ctx, tool.CrawlTool.Function.Name, url, func(ctx context.Context) (string, error) {
+		return c.crawler.Crawl(ctx, url)
+	})
+}
+
+// Python traces the runs of a tool.PythonRunner.
+type Python struct {
+	python tool.PythonRunner
+}
+
+func NewPython(python tool.PythonRunner) *Python {
+	return &Python{python: python}
+}
+
+func (p *Python) Run(ctx context.Context, code string) (string, error) {
+	return TraceTool(ctx, tool.PythonTool.Function.Name, code, func(ctx context.Context) (string, error) {
+		return p.python.Run(ctx, code)
+	})
+}
@@ -0,0 +1,207 @@
+// Package templates loads saved research templates: a parameterized query
+// with the report style, planner constraints and tools to research it with.
+package templates
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"regexp"
+	"slices"
+	"sort"
+	"strings"
+	"text/template"
+
+	"github.com/rickif/tiny-research/internal/agent"
+	"gopkg.in/yaml.v3"
+)
+
+// Variable is a parameter of the query of a template.
+type Variable struct {
+	Name        string `yaml:"name"`
+	Description string `yaml:"description"`
+	// Default is used when the variable is not bound; a variable without a
+	// default must be bound.
+	Default string `yaml:"default"`
+	// Values restricts the variable to a set of values when not empty.
+	Values []string `yaml:"values"`
+}
+
+// Planner constrains the planner, see agent.WithPlanLimits and
+// agent.WithPlannerInstructions.
+type Planner struct {
+	MaxSteps      int    `yaml:"max_steps"`
+	MaxIterations int    `yaml:"max_iterations"`
+	Instructions  string `yaml:"instructions"`
+}
+
+// Template is a saved research. Query is a text/template referring to the
+// variables as {{ .name }}.
+type Template struct {
+	Name        string     `yaml:"name"`
+	Description string     `yaml:"description"`
+	Query       string     `yaml:"query"`
+	Variables   []Variable `yaml:"variables"`
+	Style       string     `yaml:"style"`
+	Locale      string     `yaml:"locale"`
+	Planner     Planner    `yaml:"planner"`
+	// Tools restricts the run to the tools named, see agent.ToolNames; all
+	// tools are allowed when empty.
+	Tools []string `yaml:"tools"`
+
+	query *template.Template
+}
+
+var variableName = regexp.MustCompile(`^[A-Za-z_][A-Za-z0-9_]*$`)
+
+// Load reads and validates the template file at path. A template without a
+// name is named after its file.
+func Load(path string) (*Template, error) {
+	b, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	var t Template
+	decoder := yaml.NewDecoder(bytes.NewReader(b))
+	decoder.KnownFields(true)
+	if err := decoder.Decode(&t); err != nil {
+		return nil, fmt.Errorf("parse template %s: %w", path, err)
+	}
+	if t.Name == "" {
+		t.Name = strings.TrimSuffix(filepath.Base(path), filepath.Ext(path))
+	}
+	if err := t.validate(); err != nil {
+		return nil, fmt.Errorf("template %s: %w", path, err)
+	}
+	return &t, nil
+}
+
+// Find loads the template named name from dir, stored as name.yaml or
+// name.yml.
+func Find(dir string, name string) (*Template, error) {
+	for _, ext := range []string{".yaml", ".yml"} {
+		path := filepath.Join(dir, name+ext)
+		if _, err := os.Stat(path); err == nil {
+			return Load(path)
+		}
+	}
+	return nil, fmt.Errorf("no template %q in %s", name, dir)
+}
+
+// List loads every template of dir, sorted by name.
+func List(dir string) ([]*Template, error) {
+	entries, err := os.ReadDir(dir)
+	if errors.Is(err, os.ErrNotExist) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	var list []*Template
+	for _, entry := range entries {
+		if ext := filepath.Ext(entry.Name()); entry.IsDir() || (ext != ".yaml" && ext != ".yml") {
+			continue
+		}
+		t, err := Load(filepath.Join(dir, entry.Name()))
+		if err != nil {
+			return nil, err
+		}
+		list = append(list, t)
+	}
+	sort.Slice(list, func(i, j int) bool { return list[i].Name < list[j].Name })
+	return list, nil
+}
+
+// validate checks the template and compiles its query, so that errors are
+// reported when it is loaded rather than when it is run.
+func (t *Template) validate() error {
+	if strings.TrimSpace(t.Query) == "" {
+		return errors.New("no query")
+	}
+	declared := make(map[string]string, len(t.Variables))
+	for _, v := range t.Variables {
+		if !variableName.MatchString(v.Name) {
+			return fmt.Errorf("invalid variable name %q", v.Name)
+		}
+		if _, ok := declared[v.Name]; ok {
+			return fmt.Errorf("variable %q is declared twice", v.Name)
+		}
+		if v.Default != "" && len(v.Values) > 0 && !slices.Contains(v.Values, v.Default) {
+			return fmt.Errorf("default %q of variable %q is not one of its values", v.Default, v.Name)
+		}
+		declared[v.Name] = "x"
+	}
+
+	query, err := template.New(t.Name).Option("missingkey=error").Parse(t.Query)
+	if err != nil {
+		return fmt.Errorf("parse query: %w", err)
+	}
+	// every variable the query refers to must be declared
+	if err := query.Execute(&strings.Builder{}, declared); err != nil {
+		return fmt.Errorf("query refers to an undeclared variable: %w", err)
+	}
+	t.query = query
+
+	if _, err := agent.LookupReportStyle(t.Style); err != nil {
+		return err
+	}
+	if t.Planner.MaxSteps < 0 || t.Planner.MaxIterations < 0 {
+		return errors.New("planner limits must not be negative")
+	}
+	for _, name := range t.Tools {
+		if !slices.Contains(agent.ToolNames(), name) {
+			return fmt.Errorf("unknown tool %q, expected one of %s", name, strings.Join(agent.ToolNames(), ", "))
+		}
+	}
+	return nil
+}
+
+// Bind renders the query of the template with vars and returns it along
+// with the options to research it with. Every variable without a default
+// must be bound, and no undeclared one may be.
+func (t *Template) Bind(vars map[string]string) (string, []agent.ResearchOption, error) {
+	values := make(map[string]string, len(t.Variables))
+	for _, v := range t.Variables {
+		value, ok := vars[v.Name]
+		if !ok || value == "" {
+			value = v.Default
+		}
+		if value == "" {
+			return "", nil, fmt.Errorf("template %s: variable %q is required", t.Name, v.Name)
+		}
+		if len(v.Values) > 0 && !slices.Contains(v.Values, value) {
+			return "", nil, fmt.Errorf("template %s: variable %q must be one of %s, got %q", t.Name, v.Name, strings.Join(v.Values, ", "), value)
+		}
+		values[v.Name] = value
+	}
+	for name := range vars {
+		if _, ok := values[name]; !ok {
+			return "", nil, fmt.Errorf("template %s has no variable %q", t.Name, name)
+		}
+	}
+
+	var query strings.Builder
+	if err := t.query.Execute(&query, values); err != nil {
+		return "", nil, fmt.Errorf("template %s: %w", t.Name, err)
+	}
+
+	var options []agent.ResearchOption
+	if t.Style != "" {
+		options = append(options, agent.WithReportStyle(t.Style))
+	}
+	if t.Locale != "" {
+		options = append(options, agent.WithLocale(t.Locale))
+	}
+	if t.Planner.MaxSteps > 0 || t.Planner.MaxIterations > 0 {
+		options = append(options, agent.WithPlanLimits(t.Planner.MaxSteps, t.Planner.MaxIterations))
+	}
+	if t.Planner.Instructions != "" {
+		options = append(options, agent.WithPlannerInstructions(t.Planner.Instructions))
+	}
+	if len(t.Tools) > 0 {
+		options = append(options, agent.WithTools(t.Tools...))
+	}
+	return strings.TrimSpace(query.String()), options, nil
+}
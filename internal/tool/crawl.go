@@ -28,9 +28,41 @@ var CrawlTool = llms.Tool{
 	},
 }
 
+// Crawler fetches a url and returns its readable content.
+type Crawler interface {
+	Crawl(ctx context.Context, url string) (string, error)
+}
+
+// CrawlFunc adapts a function to the Crawler interface.
+type CrawlFunc func(ctx context.Context, url string) (string, error)
+
+func (f CrawlFunc) Crawl(ctx context.Context, url string) (string, error) {
+	return f(ctx, url)
+}
+
+// JinaCrawler crawls urls through the Jina AI reader service.
+type JinaCrawler struct {
+	client *http.Client
+}
+
+var _ Crawler = (*JinaCrawler)(nil)
+
+func NewJinaCrawler(client *http.Client) *JinaCrawler {
+	return &JinaCrawler{client: client}
+}
+
+// Crawl crawls url with the default http client.
 func Crawl(ctx context.Context, url string) (string, error) {
+	return NewJinaCrawler(http.DefaultClient).Crawl(ctx, url)
+}
+
+func (c *JinaCrawler) Crawl(ctx context.Context, url string) (string, error) {
 	requetURL := "https://r.jina.ai/" + url
-	resp, err := http.Get(requetURL)
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, requetURL, nil)
+	if err != nil {
+		return "", err
+	}
+	resp, err := c.client.Do(req)
 	if err != nil {
 		slog.Error("jina ai crawler", "error", err)
 		return "", err
@@ -0,0 +1,76 @@
+package tool
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+
+	"github.com/rickif/tiny-research/internal/rag"
+	"github.com/tmc/langchaingo/llms"
+)
+
+var LocalSearchTool = llms.Tool{
+	Type: "function",
+	Function: &llms.FunctionDefinition{
+		Name:        "local_search",
+		Description: "Use this to search the local document corpus (internal Markdown, PDF, HTML and text files). Results include the file path and the line range or page to cite.",
+		Parameters: map[string]any{
+			"type": "object",
+			"properties": map[string]any{
+				"query": map[string]any{
+					"type":        "string",
+					"description": "keywords to look up in the local documents.",
+				},
+			},
+			"required": []string{"query"},
+		},
+	},
+}
+
+type LocalSearch struct {
+	index *rag.Index
+	topK  int
+}
+
+func NewLocalSearch(index *rag.Index, topK int) *LocalSearch {
+	return &LocalSearch{index: index, topK: topK}
+}
+
+type localSearchResult struct {
+	Citation  string  `json:"citation"`
+	Path      string  `json:"path"`
+	StartLine int     `json:"start_line,omitempty"`
+	EndLine   int     `json:"end_line,omitempty"`
+	Page      int     `json:"page,omitempty"`
+	Score     float64 `json:"score"`
+	Content   string  `json:"content"`
+}
+
+func (l *LocalSearch) Search(ctx context.Context, query string) (string, error) {
+	var results []localSearchResult
+	for _, r := range l.index.Search(query, l.topK) {
+		result := localSearchResult{
+			Citation: r.Source,
+			Path:     r.Source,
+			Page:     r.Page,
+			Score:    r.Score,
+			Content:  r.Text,
+		}
+		// the lines of an HTML or PDF chunk are those of its extracted text,
+		// so only text files are cited by line and PDFs by page
+		switch {
+		case rag.IsPlainText(r.Source):
+			result.Citation = fmt.Sprintf("%s#L%d-L%d", r.Source, r.StartLine, r.EndLine)
+			result.StartLine, result.EndLine = r.StartLine, r.EndLine
+		case r.Page > 0:
+			result.Citation = fmt.Sprintf("%s#page=%d", r.Source, r.Page)
+		}
+		results = append(results, result)
+	}
+	if len(results) == 0 {
+		return "no matching local documents", nil
+	}
+
+	b, _ := json.Marshal(results)
+	return string(b), nil
+}
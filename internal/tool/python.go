@@ -27,9 +27,21 @@ var PythonTool = llms.Tool{
 	},
 }
 
+// PythonRunner executes python code and returns what it printed.
+type PythonRunner interface {
+	Run(ctx context.Context, code string) (string, error)
+}
+
+// PythonFunc adapts a function to the PythonRunner interface.
+type PythonFunc func(ctx context.Context, code string) (string, error)
+
+func (f PythonFunc) Run(ctx context.Context, code string) (string, error) {
+	return f(ctx, code)
+}
+
 func Python(ctx context.Context, code string) (string, error) {
 	var buffer bytes.Buffer
-	command := exec.Command("python", "-c", code)
+	command := exec.CommandContext(ctx, "python", "-c", code)
 	command.Stderr = &buffer
 	output, err := command.Output()
 	if err != nil {
@@ -0,0 +1,90 @@
+package tool
+
+import (
+	"context"
+	"encoding/json"
+
+	"github.com/rickif/tiny-research/internal/rag"
+	"github.com/strrl/tavily-go/pkg/tavily"
+	"github.com/tmc/langchaingo/llms"
+)
+
+var RetrieveTool = llms.Tool{
+	Type: "function",
+	Function: &llms.FunctionDefinition{
+		Name:        "retrieve",
+		Description: "Use this to retrieve the passages most relevant to a question from all content searched and crawled so far in this session.",
+		Parameters: map[string]any{
+			"type": "object",
+			"properties": map[string]any{
+				"query": map[string]any{
+					"type":        "string",
+					"description": "The question or keywords to retrieve passages for.",
+				},
+			},
+			"required": []string{"query"},
+		},
+	},
+}
+
+type Retriever struct {
+	store *rag.VectorStore
+	topK  int
+}
+
+func NewRetriever(store *rag.VectorStore, topK int) *Retriever {
+	return &Retriever{store: store, topK: topK}
+}
+
+// Index stores content fetched from source so it can be retrieved later.
+func (r *Retriever) Index(ctx context.Context, source string, content string) (int, error) {
+	return r.store.Add(ctx, source, content)
+}
+
+type retrieveResult struct {
+	Source    string  `json:"source"`
+	StartLine int     `json:"start_line"`
+	EndLine   int     `json:"end_line"`
+	Score     float64 `json:"score"`
+	Content   string  `json:"content"`
+}
+
+func (r *Retriever) Retrieve(ctx context.Context, query string) (string, error) {
+	passages, err := r.store.Search(ctx, query, r.topK)
+	if err != nil {
+		return "", err
+	}
+	if len(passages) == 0 {
+		return "no passages have been collected yet", nil
+	}
+
+	results := make([]retrieveResult, 0, len(passages))
+	for _, p := range passages {
+		results = append(results, retrieveResult{
+			Source:    p.Source,
+			StartLine: p.StartLine,
+			EndLine:   p.EndLine,
+			Score:     p.Score,
+			Content:   p.Text,
+		})
+	}
+	b, _ := json.Marshal(results)
+	return string(b), nil
+}
+
+// IndexSearch stores every result of a tavily_search response under its URL.
+func (r *Retriever) IndexSearch(ctx context.Context, output string) (int, error) {
+	var resp tavily.SearchResponse
+	if err := json.Unmarshal([]byte(output), &resp); err != nil {
+		return 0, err
+	}
+	total := 0
+	for _, result := range resp.Results {
+		n, err := r.store.Add(ctx, result.URL, result.Title+"\n"+result.Content)
+		if err != nil {
+			return total, err
+		}
+		total += n
+	}
+	return total, nil
+}
@@ -4,6 +4,7 @@ import (
 	"context"
 	"encoding/json"
 	"log/slog"
+	"net/http"
 
 	"github.com/strrl/tavily-go/pkg/tavily"
 	"github.com/tmc/langchaingo/llms"
@@ -27,16 +28,30 @@ var SearchTool = llms.Tool{
 	},
 }
 
+// Searcher runs a web search and returns the results as a string for the LLM.
+type Searcher interface {
+	Search(ctx context.Context, query string) (string, error)
+}
+
+var _ Searcher = (*TavilySearchTool)(nil)
+
 type TavilySearchTool struct {
-	key string
+	key    string
+	client *http.Client
 }
 
-func NewTavilySearchTool(key string) *TavilySearchTool {
-	return &TavilySearchTool{key: key}
+// NewTavilySearchTool creates a Tavily search tool. client is optional and
+// defaults to http.DefaultClient.
+func NewTavilySearchTool(key string, client *http.Client) *TavilySearchTool {
+	if client == nil {
+		client = http.DefaultClient
+	}
+	return &TavilySearchTool{key: key, client: client}
 }
 
 func (t *TavilySearchTool) Search(ctx context.Context, query string) (string, error) {
 	client := tavily.NewClient(t.key)
+	client.HttpClient = t.client
 	resp, err := client.Search(ctx, query)
 	if err != nil {
 		slog.Error("tavily search", "error", err)
@@ -0,0 +1,85 @@
+package tool
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+)
+
+// ErrTimeout is returned by a tool wrapped with a timeout when the call runs
+// out of time while the caller's context is still live, so the caller can
+// report the failure to the model instead of ending the run.
+var ErrTimeout = errors.New("tool call timed out")
+
+// SearcherWithTimeout bounds every search by timeout. A zero timeout returns
+// searcher unchanged.
+func SearcherWithTimeout(searcher Searcher, timeout time.Duration) Searcher {
+	if timeout <= 0 {
+		return searcher
+	}
+	return &timeoutSearcher{searcher: searcher, timeout: timeout}
+}
+
+// CrawlerWithTimeout bounds every crawl by timeout. A zero timeout returns
+// crawler unchanged.
+func CrawlerWithTimeout(crawler Crawler, timeout time.Duration) Crawler {
+	if timeout <= 0 {
+		return crawler
+	}
+	return &timeoutCrawler{crawler: crawler, timeout: timeout}
+}
+
+// PythonWithTimeout bounds every python run by timeout. A zero timeout
+// returns python unchanged.
+func PythonWithTimeout(python PythonRunner, timeout time.Duration) PythonRunner {
+	if timeout <= 0 {
+		return python
+	}
+	return &timeoutPython{python: python, timeout: timeout}
+}
+
+type timeoutSearcher struct {
+	searcher Searcher
+	timeout  time.Duration
+}
+
+func (t *timeoutSearcher) Search(ctx context.Context, query string) (string, error) {
+	callCtx, cancel := context.WithTimeout(ctx, t.timeout)
+	defer cancel()
+	output, err := t.searcher.Search(callCtx, query)
+	return output, timedOut(ctx, callCtx, t.timeout, err)
+}
+
+type timeoutCrawler struct {
+	crawler Crawler
+	timeout time.Duration
+}
+
+func (t *timeoutCrawler) Crawl(ctx context.Context, url string) (string, error) {
+	callCtx, cancel := context.WithTimeout(ctx, t.timeout)
+	defer cancel()
+	output, err := t.crawler.Crawl(callCtx, url)
+	return output, timedOut(ctx, callCtx, t.timeout, err)
+}
+
+type timeoutPython struct {
+	python  PythonRunner
+	timeout time.Duration
+}
+
+func (t *timeoutPython) Run(ctx context.Context, code string) (string, error) {
+	callCtx, cancel := context.WithTimeout(ctx, t.timeout)
+	defer cancel()
+	output, err := t.python.Run(callCtx, code)
+	return output, timedOut(ctx, callCtx, t.timeout, err)
+}
+
+// timedOut wraps err with ErrTimeout when the call context ran out of time
+// but its parent did not.
+func timedOut(parent, call context.Context, timeout time.Duration, err error) error {
+	if err == nil || parent.Err() != nil || !errors.Is(call.Err(), context.DeadlineExceeded) {
+		return err
+	}
+	return fmt.Errorf("%w after %s: %w", ErrTimeout, timeout, err)
+}
@@ -0,0 +1,115 @@
+package transcript
+
+import (
+	"fmt"
+	"html/template"
+	"io"
+	"time"
+)
+
+var htmlTemplate = template.Must(template.New("transcript").Funcs(template.FuncMap{
+	"offset": func(start time.Time, t time.Time) string {
+		return fmt.Sprintf("+%.1fs", t.Sub(start).Seconds())
+	},
+	"size": func(s string) string {
+		if len(s) < 1024 {
+			return fmt.Sprintf("%d B", len(s))
+		}
+		return fmt.Sprintf("%.1f KB", float64(len(s))/1024)
+	},
+}).Parse(`<!DOCTYPE html>
+<html lang="en">
+<head>
+<meta charset="utf-8">
+<meta name="viewport" content="width=device-width, initial-scale=1">
+<title>Transcript{{ with .Run }}: {{ .Query }}{{ end }}</title>
+<style>
+body { max-width: 64rem; margin: 2rem auto; padding: 0 1rem; font: 15px/1.5 -apple-system, "Segoe UI", Helvetica, Arial, sans-serif; color: #1f2328; }
+h1 { font-size: 1.5rem; border-bottom: 1px solid #d1d9e0; padding-bottom: .3em; }
+dl { display: grid; grid-template-columns: max-content 1fr; gap: .2em 1em; }
+dt { color: #59636e; }
+dd { margin: 0; }
+ol { list-style: none; padding: 0; border-left: 2px solid #d1d9e0; }
+li { position: relative; margin: 0 0 .8em 1.2em; }
+li::before { content: ""; position: absolute; left: -1.62em; top: .45em; width: .6em; height: .6em; border-radius: 50%; background: #d1d9e0; }
+li.node_start, li.node_end { margin-left: .6em; }
+li.node_start::before, li.node_end::before { left: -1.02em; background: #0969da; }
+li.error::before { background: #cf222e; }
+.head { display: flex; gap: .6em; align-items: baseline; }
+.time { color: #59636e; font-family: ui-monospace, Menlo, Consolas, monospace; font-size: 85%; min-width: 5em; }
+.kind { font-weight: 600; }
+.node { color: #0969da; font-family: ui-monospace, Menlo, Consolas, monospace; font-size: 85%; }
+.meta { color: #59636e; font-size: 85%; }
+.err { color: #cf222e; }
+details { margin: .3em 0 0 5.6em; }
+summary { cursor: pointer; color: #59636e; font-size: 90%; }
+pre { background: #f6f8fa; padding: .8em; border-radius: 6px; white-space: pre-wrap; word-break: break-word; font: 13px/1.45 ui-monospace, Menlo, Consolas, monospace; max-height: 40rem; overflow-y: auto; }
+.role { font-weight: 600; font-size: 85%; text-transform: uppercase; color: #59636e; margin-top: .6em; }
+</style>
+</head>
+<body>
+<h1>Transcript</h1>
+{{ with .Run }}<dl>
+<dt>Query</dt><dd>{{ .Query }}</dd>
+<dt>Session</dt><dd>{{ .SessionID }}, turn {{ .Turn }}</dd>
+{{ if .Model }}<dt>Model</dt><dd>{{ .Model }}</dd>{{ end }}
+{{ if .Style }}<dt>Style</dt><dd>{{ .Style }}</dd>{{ end }}
+{{ if .Locale }}<dt>Locale</dt><dd>{{ .Locale }}</dd>{{ end }}
+<dt>Started</dt><dd>{{ $.Start.Format "2006-01-02 15:04:05 MST" }}</dd>
+</dl>{{ end }}
+<ol>
+{{ range .Events }}<li class="{{ .Kind }}{{ if .Error }} error{{ end }}">
+<div class="head"><span class="time">{{ offset $.Start .Time }}</span>
+{{ if eq .Kind "run_start" }}<span class="kind">Run started</span>
+{{ else if eq .Kind "node_start" }}<span class="kind">Node</span> <span class="node">{{ .Node }}</span>
+{{ else if eq .Kind "llm_request" }}<span class="kind">Prompt</span> <span class="meta">{{ len .Messages }} messages{{ if .Tools }}, tools: {{ range $i, $t := .Tools }}{{ if $i }}, {{ end }}{{ $t }}{{ end }}{{ end }}</span>
+{{ else if eq .Kind "llm_response" }}<span class="kind">Model output</span> <span class="meta">{{ .Duration }}{{ if .Cached }}, cached{{ end }}{{ if .ToolCalls }}, {{ len .ToolCalls }} tool calls{{ end }}{{ if or .InputTokens .OutputTokens }}, {{ .InputTokens }} in / {{ .OutputTokens }} out tokens{{ end }}</span>
+{{ else if eq .Kind "tool_call" }}<span class="kind">Tool {{ .Tool }}</span> <span class="meta">{{ .Args }} · {{ size .Output }} · {{ .Duration }}</span>
+{{ else if eq .Kind "plan" }}<span class="kind">Plan revision</span>
+{{ else if eq .Kind "node_end" }}<span class="kind">Node done</span> <span class="node">{{ .Node }}</span> <span class="meta">{{ .Duration }}{{ if .NextStep }} → {{ .NextStep }}{{ end }}</span>
+{{ else if eq .Kind "report" }}<span class="kind">Report</span>
+{{ else if eq .Kind "run_end" }}<span class="kind">Run ended</span> <span class="meta">{{ .Duration }}</span>
+{{ else }}<span class="kind">{{ .Kind }}</span>{{ end }}
+{{ if .Error }}<span class="err">{{ .Error }}</span>{{ end }}
+</div>
+{{ if .Messages }}<details><summary>Prompt</summary>
+{{ range .Messages }}<div class="role">{{ .Role }}{{ if .Name }} · {{ .Name }}{{ end }}</div>
+{{ if .Content }}<pre>{{ .Content }}</pre>{{ end }}
+{{ range .ToolCalls }}<pre>{{ .Name }}({{ .Arguments }})</pre>{{ end }}
+{{ end }}</details>{{ end }}
+{{ if and (eq .Kind "llm_response") (or .Content .ToolCalls) }}<details><summary>Output</summary>
+{{ if .Content }}<pre>{{ .Content }}</pre>{{ end }}
+{{ range .ToolCalls }}<pre>{{ .Name }}({{ .Arguments }})</pre>{{ end }}
+</details>{{ end }}
+{{ if and (eq .Kind "tool_call") .Output }}<details><summary>Result</summary><pre>{{ .Output }}</pre></details>{{ end }}
+{{ with .Plan }}<details><summary>{{ .Title }}</summary>
+{{ if .Thought }}<pre>{{ .Thought }}</pre>{{ end }}
+{{ range $i, $step := .Steps }}<div class="role">{{ if $step.Skipped }}⤼{{ else if $step.Done }}✓{{ else }}○{{ end }} {{ $step.Title }}{{ if $step.Type }} · {{ $step.Type }}{{ end }}</div>
+{{ if $step.Description }}<pre>{{ $step.Description }}</pre>{{ end }}
+{{ end }}</details>{{ end }}
+{{ if and (eq .Kind "node_end") .Output }}<details><summary>Output</summary><pre>{{ .Output }}</pre></details>{{ end }}
+{{ if eq .Kind "report" }}<details open><summary>Report</summary><pre>{{ .Content }}</pre></details>{{ end }}
+</li>
+{{ end }}</ol>
+</body>
+</html>
+`))
+
+// WriteHTML renders events as a timeline.
+func WriteHTML(w io.Writer, events []Event) error {
+	data := struct {
+		Run    *Run
+		Start  time.Time
+		Events []Event
+	}{Events: events}
+	if len(events) > 0 {
+		data.Start = events[0].Time
+	}
+	for _, event := range events {
+		if event.Run != nil {
+			data.Run = event.Run
+			break
+		}
+	}
+	return htmlTemplate.Execute(w, data)
+}
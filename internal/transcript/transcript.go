@@ -0,0 +1,349 @@
+// Package transcript writes the audit trail of a research run: the prompts
+// sent to each node, the raw model outputs, the tool calls with their full
+// results, the plan revisions and the final report, as a JSONL file with one
+// Event per line and an HTML timeline.
+package transcript
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"fmt"
+	"os"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/tmc/langchaingo/llms"
+)
+
+const (
+	KindRunStart    = "run_start"
+	KindNodeStart   = "node_start"
+	KindLLMRequest  = "llm_request"
+	KindLLMResponse = "llm_response"
+	KindToolCall    = "tool_call"
+	KindPlan        = "plan"
+	KindNodeEnd     = "node_end"
+	KindReport      = "report"
+	KindRunEnd      = "run_end"
+)
+
+// Event is one entry of a transcript. Which fields are set depends on Kind.
+type Event struct {
+	Seq  int       `json:"seq"`
+	Time time.Time `json:"time"`
+	Kind string    `json:"kind"`
+	// Node is the step of the node the event happened in.
+	Node string `json:"node,omitempty"`
+
+	// Run describes the run, on run_start.
+	Run *Run `json:"run,omitempty"`
+	// Messages and Tools are the prompt and the tools offered, on
+	// llm_request.
+	Messages []Message `json:"messages,omitempty"`
+	Tools    []string  `json:"tools,omitempty"`
+	// Content is the model output on llm_response, and the report markdown
+	// on report.
+	Content   string     `json:"content,omitempty"`
+	ToolCalls []ToolCall `json:"tool_calls,omitempty"`
+	// InputTokens and OutputTokens are the token usage of an llm_response,
+	// when the provider reports it. Cached marks an llm_response answered
+	// from the cache or a cassette, which used no tokens.
+	InputTokens  int  `json:"input_tokens,omitempty"`
+	OutputTokens int  `json:"output_tokens,omitempty"`
+	Cached       bool `json:"cached,omitempty"`
+	// Tool, Args and Output describe a tool_call; Output is also the output
+	// of a node on node_end.
+	Tool   string `json:"tool,omitempty"`
+	Args   string `json:"args,omitempty"`
+	Output string `json:"output,omitempty"`
+	// Plan is the plan after a revision or an executed step, on plan.
+	Plan *Plan `json:"plan,omitempty"`
+	// NextStep is the step the node hands over to, on node_end.
+	NextStep string        `json:"next_step,omitempty"`
+	Duration time.Duration `json:"duration,omitempty"`
+	Error    string        `json:"error,omitempty"`
+}
+
+// Run describes the question a transcript is about.
+type Run struct {
+	Query     string `json:"query"`
+	SessionID string `json:"session_id"`
+	Turn      int    `json:"turn"`
+	Style     string `json:"style,omitempty"`
+	Locale    string `json:"locale,omitempty"`
+	Model     string `json:"model,omitempty"`
+}
+
+// Plan is the plan of a run along with the steps executed so far.
+type Plan struct {
+	Title   string `json:"title"`
+	Thought string `json:"thought,omitempty"`
+	Steps   []Step `json:"steps"`
+}
+
+// Step is a step of a Plan.
+type Step struct {
+	Title       string `json:"title"`
+	Description string `json:"description,omitempty"`
+	Type        string `json:"type,omitempty"`
+	Done        bool   `json:"done"`
+	Skipped     bool   `json:"skipped,omitempty"`
+}
+
+// Message is a message of a prompt.
+type Message struct {
+	Role       string     `json:"role"`
+	Content    string     `json:"content,omitempty"`
+	ToolCalls  []ToolCall `json:"tool_calls,omitempty"`
+	ToolCallID string     `json:"tool_call_id,omitempty"`
+	Name       string     `json:"name,omitempty"`
+}
+
+// ToolCall is a tool call requested by the model.
+type ToolCall struct {
+	ID        string `json:"id,omitempty"`
+	Name      string `json:"name"`
+	Arguments string `json:"arguments"`
+}
+
+// Messages converts the messages of a GenerateContent call.
+func Messages(messages []llms.MessageContent) []Message {
+	converted := make([]Message, 0, len(messages))
+	for _, message := range messages {
+		m := Message{Role: string(message.Role)}
+		var text []string
+		for _, part := range message.Parts {
+			switch part := part.(type) {
+			case llms.TextContent:
+				text = append(text, part.Text)
+			case llms.ToolCall:
+				m.ToolCalls = append(m.ToolCalls, toolCall(part))
+			case llms.ToolCallResponse:
+				m.ToolCallID = part.ToolCallID
+				m.Name = part.Name
+				text = append(text, part.Content)
+			default:
+				text = append(text, fmt.Sprintf("[%T]", part))
+			}
+		}
+		m.Content = strings.Join(text, "\n")
+		converted = append(converted, m)
+	}
+	return converted
+}
+
+// ToolCalls converts the tool calls of a GenerateContent response.
+func ToolCalls(calls []llms.ToolCall) []ToolCall {
+	converted := make([]ToolCall, 0, len(calls))
+	for _, call := range calls {
+		converted = append(converted, toolCall(call))
+	}
+	return converted
+}
+
+func toolCall(call llms.ToolCall) ToolCall {
+	converted := ToolCall{ID: call.ID}
+	if call.FunctionCall != nil {
+		converted.Name = call.FunctionCall.Name
+		converted.Arguments = call.FunctionCall.Arguments
+	}
+	return converted
+}
+
+// Recorder receives the events of the runs it is attached to with
+// NewContext, e.g. a Transcript or a live progress view.
+type Recorder interface {
+	Record(event Event)
+}
+
+var _ Recorder = (*Transcript)(nil)
+
+// Transcript appends the events of one run to a JSONL file, and renders
+// them as an HTML timeline on Close.
+type Transcript struct {
+	path string
+
+	mu     sync.Mutex
+	file   *os.File
+	events []Event
+	err    error
+}
+
+// Create creates or truncates the transcript at path, a .jsonl file; the
+// timeline is written next to it with the .html extension.
+func Create(path string) (*Transcript, error) {
+	f, err := os.Create(path)
+	if err != nil {
+		return nil, err
+	}
+	return &Transcript{path: path, file: f}, nil
+}
+
+// Path returns the path of the JSONL file.
+func (t *Transcript) Path() string {
+	return t.path
+}
+
+// HTMLPath returns the path of the timeline.
+func (t *Transcript) HTMLPath() string {
+	return strings.TrimSuffix(t.path, ".jsonl") + ".html"
+}
+
+// Record appends event to the JSONL file.
+func (t *Transcript) Record(event Event) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	t.events = append(t.events, event)
+	b, err := json.Marshal(event)
+	if err == nil {
+		_, err = t.file.Write(append(b, '\n'))
+	}
+	if err != nil && t.err == nil {
+		// the first failure is returned by Close, the run goes on
+		t.err = fmt.Errorf("write transcript event %d: %w", event.Seq, err)
+	}
+}
+
+// Close closes the JSONL file and writes the timeline. It returns the first
+// error writing an event, if any.
+func (t *Transcript) Close() error {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	err := t.err
+	if closeErr := t.file.Close(); err == nil {
+		err = closeErr
+	}
+	f, createErr := os.Create(t.HTMLPath())
+	if createErr != nil {
+		if err == nil {
+			err = createErr
+		}
+		return err
+	}
+	if writeErr := WriteHTML(f, t.events); err == nil {
+		err = writeErr
+	}
+	if closeErr := f.Close(); err == nil {
+		err = closeErr
+	}
+	return err
+}
+
+// Load reads the events of a transcript written by a Transcript.
+func Load(path string) ([]Event, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	var events []Event
+	scanner := bufio.NewScanner(f)
+	scanner.Buffer(make([]byte, 0, 64*1024), 64*1024*1024)
+	for line := 1; scanner.Scan(); line++ {
+		if len(scanner.Bytes()) == 0 {
+			continue
+		}
+		var event Event
+		if err := json.Unmarshal(scanner.Bytes(), &event); err != nil {
+			return nil, fmt.Errorf("%s:%d: %w", path, line, err)
+		}
+		events = append(events, event)
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, err
+	}
+	return events, nil
+}
+
+type contextKey int
+
+const (
+	recordingKey contextKey = iota
+	nodeKey
+)
+
+// recording holds the recorders of a run and numbers its events.
+type recording struct {
+	recorders []Recorder
+
+	// shared by the contexts adding recorders to the same run
+	sequence *sequence
+}
+
+type sequence struct {
+	mu       sync.Mutex
+	seq      int
+	lastPlan string
+}
+
+// NewContext returns a context whose events also go to recorder, along with
+// the recorders ctx already has.
+func NewContext(ctx context.Context, recorder Recorder) context.Context {
+	r := &recording{recorders: []Recorder{recorder}, sequence: &sequence{}}
+	if parent, ok := ctx.Value(recordingKey).(*recording); ok {
+		r.recorders = append(slices.Clone(parent.recorders), recorder)
+		r.sequence = parent.sequence
+	}
+	return context.WithValue(ctx, recordingKey, r)
+}
+
+// WithNode returns a context whose events are attributed to the node of step.
+func WithNode(ctx context.Context, step string) context.Context {
+	return context.WithValue(ctx, nodeKey, step)
+}
+
+// Enabled reports whether the events of ctx are recorded.
+func Enabled(ctx context.Context) bool {
+	_, ok := ctx.Value(recordingKey).(*recording)
+	return ok
+}
+
+// Record numbers event and sends it to the recorders of ctx, if any,
+// attributed to the node of ctx unless it names one.
+func Record(ctx context.Context, event Event) {
+	r, ok := ctx.Value(recordingKey).(*recording)
+	if !ok {
+		return
+	}
+	if event.Node == "" {
+		event.Node, _ = ctx.Value(nodeKey).(string)
+	}
+	if event.Time.IsZero() {
+		event.Time = time.Now()
+	}
+
+	// the lock keeps the events in the same order for every recorder
+	r.sequence.mu.Lock()
+	defer r.sequence.mu.Unlock()
+	r.sequence.seq++
+	event.Seq = r.sequence.seq
+	for _, recorder := range r.recorders {
+		recorder.Record(event)
+	}
+}
+
+// RecordPlan records a plan event for plan unless it equals the last plan
+// recorded.
+func RecordPlan(ctx context.Context, plan *Plan) {
+	r, ok := ctx.Value(recordingKey).(*recording)
+	if !ok || plan == nil {
+		return
+	}
+	b, err := json.Marshal(plan)
+	if err != nil {
+		return
+	}
+	r.sequence.mu.Lock()
+	changed := string(b) != r.sequence.lastPlan
+	r.sequence.lastPlan = string(b)
+	r.sequence.mu.Unlock()
+	if changed {
+		Record(ctx, Event{Kind: KindPlan, Plan: plan})
+	}
+}
@@ -0,0 +1,103 @@
+package tui
+
+import (
+	"fmt"
+	"os"
+	"os/exec"
+	"strings"
+
+	tea "github.com/charmbracelet/bubbletea"
+	"github.com/rickif/tiny-research/internal/agent"
+	"github.com/rickif/tiny-research/internal/transcript"
+	"gopkg.in/yaml.v3"
+)
+
+const editHeader = `# Edit the steps of the plan not executed yet, then save and quit the editor.
+# Remove, reorder or add steps; type is research or processing. The step
+# being executed finishes unless it is skipped. An unchanged file keeps the plan.
+`
+
+// editableStep is a plan step as written to the editor.
+type editableStep struct {
+	Title       string `yaml:"title"`
+	Description string `yaml:"description"`
+	Type        string `yaml:"type"`
+}
+
+// editedMsg holds the steps saved in the editor; changed is false when the
+// file was left as is.
+type editedMsg struct {
+	steps   []agent.Step
+	changed bool
+	err     error
+}
+
+// editPlan opens the pending steps of plan as YAML in the editor of the
+// user, $VISUAL or $EDITOR, else vi.
+func editPlan(plan *transcript.Plan) tea.Cmd {
+	fail := func(err error) tea.Cmd {
+		return func() tea.Msg { return editedMsg{err: err} }
+	}
+
+	pending := []editableStep{}
+	for _, step := range plan.Steps {
+		if !step.Done {
+			pending = append(pending, editableStep{Title: step.Title, Description: step.Description, Type: step.Type})
+		}
+	}
+	b, err := yaml.Marshal(pending)
+	if err != nil {
+		return fail(fmt.Errorf("encode plan: %w", err))
+	}
+	original := editHeader + string(b)
+
+	f, err := os.CreateTemp("", "tiny-research-plan-*.yaml")
+	if err != nil {
+		return fail(err)
+	}
+	path := f.Name()
+	_, err = f.WriteString(original)
+	if closeErr := f.Close(); err == nil {
+		err = closeErr
+	}
+	if err != nil {
+		os.Remove(path)
+		return fail(err)
+	}
+
+	editor := strings.Fields(os.Getenv("VISUAL"))
+	if len(editor) == 0 {
+		editor = strings.Fields(os.Getenv("EDITOR"))
+	}
+	if len(editor) == 0 {
+		editor = []string{"vi"}
+	}
+	cmd := exec.Command(editor[0], append(editor[1:], path)...)
+	return tea.ExecProcess(cmd, func(err error) tea.Msg {
+		defer os.Remove(path)
+		if err != nil {
+			return editedMsg{err: fmt.Errorf("run editor: %w", err)}
+		}
+		b, err := os.ReadFile(path)
+		if err != nil {
+			return editedMsg{err: err}
+		}
+		if string(b) == original {
+			return editedMsg{}
+		}
+		var edited []editableStep
+		if err := yaml.Unmarshal(b, &edited); err != nil {
+			return editedMsg{err: fmt.Errorf("parse plan: %w", err)}
+		}
+		steps := make([]agent.Step, 0, len(edited))
+		for _, step := range edited {
+			steps = append(steps, agent.Step{
+				NeedSearch:  step.Type == agent.StepTypeReasearch,
+				Title:       strings.TrimSpace(step.Title),
+				Description: strings.TrimSpace(step.Description),
+				StepType:    step.Type,
+			})
+		}
+		return editedMsg{steps: steps, changed: true}
+	})
+}
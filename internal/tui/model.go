@@ -0,0 +1,294 @@
+package tui
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/charmbracelet/bubbles/spinner"
+	"github.com/charmbracelet/bubbles/viewport"
+	tea "github.com/charmbracelet/bubbletea"
+	"github.com/charmbracelet/glamour"
+	"github.com/charmbracelet/lipgloss"
+	"github.com/rickif/tiny-research/internal/agent"
+	"github.com/rickif/tiny-research/internal/report"
+	"github.com/rickif/tiny-research/internal/tool"
+	"github.com/rickif/tiny-research/internal/transcript"
+)
+
+var nodeNames = map[string]string{
+	agent.StepCoordinator:  "Understanding the question",
+	agent.StepPlanner:      "Planning",
+	agent.StepResearchTeam: "Assigning the next step",
+	agent.StepResearcher:   "Researching",
+	agent.StepCoder:        "Running code",
+	agent.StepVerifier:     "Verifying claims",
+	agent.StepReporter:     "Writing the report",
+	agent.StepCritic:       "Reviewing the report",
+}
+
+// model is the state of the UI, updated from the events of the run.
+type model struct {
+	query   string
+	options Options
+	control *agent.Control
+	cancel  context.CancelFunc
+	style   string
+
+	width, height int
+	started       time.Time
+	finished      time.Time
+	spinner       spinner.Model
+	activity      viewport.Model
+	reportView    viewport.Model
+	lines         []string
+
+	plan *transcript.Plan
+	// running is the index of the plan step being executed, or -1
+	running int
+	node    string
+
+	llmCalls     int
+	inputTokens  int
+	outputTokens int
+	toolCalls    int
+	crawled      map[string]bool
+
+	// status is the last notice, shown before the keys
+	status    string
+	canceling bool
+	done      bool
+	result    *report.Report
+	err       error
+	// showReport shows the report instead of the progress of the run
+	showReport bool
+}
+
+func newModel(query string, options Options, control *agent.Control, cancel context.CancelFunc, style string) model {
+	return model{
+		query:      query,
+		options:    options,
+		control:    control,
+		cancel:     cancel,
+		style:      style,
+		started:    time.Now(),
+		spinner:    spinner.New(spinner.WithSpinner(spinner.Dot)),
+		activity:   viewport.New(0, 0),
+		reportView: viewport.New(0, 0),
+		running:    -1,
+		crawled:    make(map[string]bool),
+	}
+}
+
+func (m model) Init() tea.Cmd {
+	return tea.Batch(m.spinner.Tick, tick())
+}
+
+func tick() tea.Cmd {
+	return tea.Tick(time.Second, func(time.Time) tea.Msg { return tickMsg{} })
+}
+
+func (m model) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
+	switch msg := msg.(type) {
+	case tea.WindowSizeMsg:
+		m.width, m.height = msg.Width, msg.Height
+		m.layout()
+		return m, nil
+
+	case tea.KeyMsg:
+		return m.key(msg)
+
+	case eventMsg:
+		m.apply(transcript.Event(msg))
+		return m, nil
+
+	case doneMsg:
+		m.done = true
+		m.finished = time.Now()
+		m.node = ""
+		m.running = -1
+		m.result, m.err = msg.report, msg.err
+		switch {
+		case msg.err == nil:
+			m.status = "Research completed."
+		case errors.Is(msg.err, agent.ErrCanceled), errors.Is(msg.err, agent.ErrIncomplete):
+			m.status = "Research stopped early: " + msg.err.Error()
+		default:
+			m.status = "Research failed: " + msg.err.Error()
+		}
+		if m.result != nil {
+			m.showReport = true
+			m.renderReport()
+		}
+		return m, nil
+
+	case editedMsg:
+		switch {
+		case msg.err != nil:
+			m.status = "Edit plan: " + msg.err.Error()
+		case m.done:
+		case !msg.changed:
+			m.status = "Plan unchanged."
+		default:
+			if err := m.control.EditSteps(msg.steps); err != nil {
+				m.status = "Edit plan: " + err.Error()
+			} else {
+				m.status = fmt.Sprintf("Plan edited, %d pending steps from the next step on.", len(msg.steps))
+			}
+		}
+		return m, nil
+
+	case tickMsg:
+		if m.done {
+			return m, nil
+		}
+		return m, tick()
+
+	case spinner.TickMsg:
+		var cmd tea.Cmd
+		m.spinner, cmd = m.spinner.Update(msg)
+		return m, cmd
+	}
+	return m, nil
+}
+
+func (m model) key(msg tea.KeyMsg) (tea.Model, tea.Cmd) {
+	switch msg.String() {
+	case "q", "ctrl+c":
+		// Run cancels the research and waits for the partial report
+		return m, tea.Quit
+	case "c":
+		if !m.done && !m.canceling {
+			m.canceling = true
+			m.cancel()
+			m.status = "Canceling, the report is written from the steps executed so far…"
+		}
+		return m, nil
+	case "s":
+		if m.done {
+			return m, nil
+		}
+		if m.control.SkipStep() {
+			m.status = "Skipping the current step…"
+		} else {
+			m.status = "No plan step is being executed."
+		}
+		return m, nil
+	case "e":
+		if m.done {
+			return m, nil
+		}
+		if m.plan == nil {
+			m.status = "There is no plan to edit yet."
+			return m, nil
+		}
+		return m, editPlan(m.plan)
+	case "r":
+		if m.result != nil {
+			m.showReport = !m.showReport
+		}
+		return m, nil
+	}
+
+	var cmd tea.Cmd
+	if m.showReport {
+		m.reportView, cmd = m.reportView.Update(msg)
+	} else {
+		m.activity, cmd = m.activity.Update(msg)
+	}
+	return m, cmd
+}
+
+// apply updates the progress of the run with event.
+func (m *model) apply(event transcript.Event) {
+	switch event.Kind {
+	case transcript.KindPlan:
+		m.plan = event.Plan
+	case transcript.KindNodeStart:
+		m.node = event.Node
+		if (event.Node == agent.StepResearcher || event.Node == agent.StepCoder) && m.plan != nil {
+			for i, step := range m.plan.Steps {
+				if !step.Done {
+					m.running = i
+					m.log(fmt.Sprintf("Step %d: %s", i+1, step.Title), headingStyle)
+					break
+				}
+			}
+		}
+	case transcript.KindNodeEnd:
+		m.node = ""
+		m.running = -1
+		if event.Error != "" {
+			m.log(fmt.Sprintf("%s failed: %s", nodeName(event.Node), event.Error), errorStyle)
+		}
+	case transcript.KindLLMResponse:
+		if event.Cached {
+			break
+		}
+		m.llmCalls++
+		m.inputTokens += event.InputTokens
+		m.outputTokens += event.OutputTokens
+	case transcript.KindToolCall:
+		m.toolCalls++
+		if event.Tool == tool.CrawlTool.Function.Name && event.Error == "" {
+			m.crawled[event.Args] = true
+		}
+		// code run by the coder spans lines
+		line := fmt.Sprintf("%-15s %s", event.Tool, strings.Join(strings.Fields(event.Args), " "))
+		if event.Error != "" {
+			m.log(line+"  failed: "+event.Error, errorStyle)
+		} else {
+			m.log(line+dimStyle.Render(fmt.Sprintf("  %s · %s", size(len(event.Output)), event.Duration.Round(100*time.Millisecond))), lipgloss.NewStyle())
+		}
+	}
+}
+
+// log appends a line to the activity pane, which follows the new lines
+// unless scrolled up.
+func (m *model) log(line string, style lipgloss.Style) {
+	follow := m.activity.AtBottom()
+	m.lines = append(m.lines, style.Render(line))
+	m.activity.SetContent(strings.Join(m.lines, "\n"))
+	if follow {
+		m.activity.GotoBottom()
+	}
+}
+
+// layout sizes the panes to the terminal.
+func (m *model) layout() {
+	body := max(m.height-bodyMargin, 1)
+	m.activity.Width = max(m.width-planWidth(m.width)-2, 1)
+	m.activity.Height = max(body-2, 1)
+	m.reportView.Width = m.width
+	m.reportView.Height = body
+	m.renderReport()
+}
+
+// renderReport renders the report as Markdown to the width of the terminal.
+func (m *model) renderReport() {
+	if m.result == nil || m.width == 0 {
+		return
+	}
+	markdown := m.result.Markdown()
+	renderer, err := glamour.NewTermRenderer(glamour.WithStandardStyle(m.style), glamour.WithWordWrap(max(m.width-4, 20)))
+	if err == nil {
+		if rendered, renderErr := renderer.Render(markdown); renderErr == nil {
+			markdown = rendered
+		}
+	}
+	m.reportView.SetContent(markdown)
+}
+
+// cost returns the price of the tokens used so far.
+func (m model) cost() float64 {
+	return float64(m.inputTokens)/1e6*m.options.InputPrice + float64(m.outputTokens)/1e6*m.options.OutputPrice
+}
+
+func nodeName(step string) string {
+	if name, ok := nodeNames[step]; ok {
+		return name
+	}
+	return step
+}
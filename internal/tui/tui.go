@@ -0,0 +1,85 @@
+// Package tui researches a question in a terminal UI. It shows the plan
+// steps with their status, the tool calls and crawled URLs, and the token
+// usage and cost of the run as it goes, and renders the report as Markdown
+// at the end. Keys cancel the run, skip the step being executed, or open the
+// pending steps of the plan in an editor.
+package tui
+
+import (
+	"context"
+	"fmt"
+
+	tea "github.com/charmbracelet/bubbletea"
+	"github.com/charmbracelet/lipgloss"
+	"github.com/rickif/tiny-research/internal/agent"
+	"github.com/rickif/tiny-research/internal/report"
+	"github.com/rickif/tiny-research/internal/transcript"
+)
+
+// Researcher runs the research shown in the UI.
+type Researcher interface {
+	Research(ctx context.Context, query string, options ...agent.ResearchOption) (*report.Report, error)
+}
+
+// Options configures the UI.
+type Options struct {
+	// InputPrice and OutputPrice are the prices of a million input and
+	// output tokens; zero hides the cost.
+	InputPrice  float64
+	OutputPrice float64
+}
+
+// Run researches query in the terminal UI until the user quits, and returns
+// the report of the run along with the error of Research. Quitting while
+// the run is in progress cancels it, and waits for its partial report.
+func Run(ctx context.Context, researcher Researcher, query string, options Options, researchOptions ...agent.ResearchOption) (*report.Report, error) {
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+
+	control := agent.NewControl()
+	// the background is detected before the program owns the terminal
+	style := "light"
+	if lipgloss.HasDarkBackground() {
+		style = "dark"
+	}
+	program := tea.NewProgram(newModel(query, options, control, cancel, style), tea.WithAltScreen())
+
+	type outcome struct {
+		report *report.Report
+		err    error
+	}
+	done := make(chan outcome, 1)
+	go func() {
+		researchOptions = append(researchOptions, agent.WithControl(control))
+		result, err := researcher.Research(transcript.NewContext(ctx, recorder{program}), query, researchOptions...)
+		done <- outcome{result, err}
+		program.Send(doneMsg{report: result, err: err})
+	}()
+
+	_, err := program.Run()
+	cancel()
+	result := <-done
+	if err != nil {
+		return result.report, fmt.Errorf("run terminal ui: %w", err)
+	}
+	return result.report, result.err
+}
+
+// recorder forwards the events of the run to the program.
+type recorder struct {
+	program *tea.Program
+}
+
+func (r recorder) Record(event transcript.Event) {
+	// Send returns without delivering once the program has exited
+	r.program.Send(eventMsg(event))
+}
+
+type (
+	eventMsg transcript.Event
+	doneMsg  struct {
+		report *report.Report
+		err    error
+	}
+	tickMsg struct{}
+)
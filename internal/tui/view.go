@@ -0,0 +1,124 @@
+package tui
+
+import (
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/charmbracelet/lipgloss"
+)
+
+// bodyMargin is the number of lines around the panes: the header, the
+// counters and the keys.
+const bodyMargin = 3
+
+var (
+	titleStyle   = lipgloss.NewStyle().Bold(true)
+	headingStyle = lipgloss.NewStyle().Bold(true).Foreground(lipgloss.Color("12"))
+	dimStyle     = lipgloss.NewStyle().Foreground(lipgloss.Color("8"))
+	errorStyle   = lipgloss.NewStyle().Foreground(lipgloss.Color("9"))
+	doneStyle    = lipgloss.NewStyle().Foreground(lipgloss.Color("10"))
+	runningStyle = lipgloss.NewStyle().Foreground(lipgloss.Color("12")).Bold(true)
+	paneStyle    = lipgloss.NewStyle().Border(lipgloss.RoundedBorder()).BorderForeground(lipgloss.Color("8"))
+)
+
+func (m model) View() string {
+	if m.width == 0 {
+		return ""
+	}
+	var body string
+	if m.showReport {
+		body = m.reportView.View()
+	} else {
+		body = lipgloss.JoinHorizontal(lipgloss.Top, m.planView(), paneStyle.Render(m.activity.View()))
+	}
+	return lipgloss.JoinVertical(lipgloss.Left, m.header(), body, m.counters(), m.keys())
+}
+
+func (m model) header() string {
+	state := m.spinner.View() + " " + nodeName(m.node)
+	switch {
+	case m.done && m.err == nil:
+		state = doneStyle.Render("✓ done")
+	case m.done:
+		state = errorStyle.Render("✗ stopped")
+	case m.node == "":
+		state = m.spinner.View()
+	}
+	elapsed := time.Since(m.started)
+	if m.done {
+		elapsed = m.finished.Sub(m.started)
+	}
+	line := titleStyle.Render(m.query) + "  " + state + dimStyle.Render("  "+elapsed.Round(time.Second).String())
+	return lipgloss.NewStyle().MaxWidth(m.width).Render(line)
+}
+
+func (m model) planView() string {
+	width := planWidth(m.width)
+	var b strings.Builder
+	if m.plan == nil {
+		b.WriteString(dimStyle.Render("Waiting for the plan…"))
+	} else {
+		b.WriteString(titleStyle.Render(m.plan.Title) + "\n")
+		for i, step := range m.plan.Steps {
+			icon, style := "○", dimStyle
+			switch {
+			case step.Skipped:
+				icon = "⤼"
+			case step.Done:
+				icon, style = "✓", doneStyle
+			case i == m.running:
+				icon, style = "●", runningStyle
+			}
+			fmt.Fprintf(&b, "\n%s %s", style.Render(icon), step.Title)
+		}
+	}
+	height := max(m.height-bodyMargin-2, 1)
+	return paneStyle.Render(lipgloss.NewStyle().Width(width - 2).Height(height).MaxHeight(height).Render(b.String()))
+}
+
+func (m model) counters() string {
+	parts := []string{
+		fmt.Sprintf("LLM calls %d", m.llmCalls),
+		fmt.Sprintf("tokens %s in / %s out", tokens(m.inputTokens), tokens(m.outputTokens)),
+	}
+	if m.options.InputPrice > 0 || m.options.OutputPrice > 0 {
+		parts = append(parts, fmt.Sprintf("cost $%.4f", m.cost()))
+	}
+	parts = append(parts, fmt.Sprintf("tool calls %d", m.toolCalls), fmt.Sprintf("URLs crawled %d", len(m.crawled)))
+	return lipgloss.NewStyle().MaxWidth(m.width).Render(strings.Join(parts, dimStyle.Render(" · ")))
+}
+
+func (m model) keys() string {
+	keys := "c cancel · s skip step · e edit plan · ↑/↓ scroll · q quit"
+	if m.done {
+		keys = "↑/↓ scroll · q quit"
+		if m.result != nil {
+			keys = "r report/progress · " + keys
+		}
+	}
+	line := dimStyle.Render(keys)
+	if m.status != "" {
+		line = m.status + dimStyle.Render("  ·  "+keys)
+	}
+	return lipgloss.NewStyle().MaxWidth(m.width).Render(line)
+}
+
+// planWidth is the width of the plan pane, borders included.
+func planWidth(width int) int {
+	return min(max(width/3, 24), 60)
+}
+
+func tokens(n int) string {
+	if n < 1000 {
+		return fmt.Sprint(n)
+	}
+	return fmt.Sprintf("%.1fk", float64(n)/1000)
+}
+
+func size(n int) string {
+	if n < 1024 {
+		return fmt.Sprintf("%d B", n)
+	}
+	return fmt.Sprintf("%.1f KB", float64(n)/1024)
+}
@@ -0,0 +1,98 @@
+package web
+
+import (
+	"errors"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"strconv"
+
+	"github.com/rickif/tiny-research/internal/history"
+)
+
+// listHistory lists the past runs, or with q those matching its keywords,
+// up to limit, 50 by default.
+func (s *Server) listHistory(w http.ResponseWriter, r *http.Request) {
+	if !s.historyEnabled(w) {
+		return
+	}
+	limit := 50
+	if value := r.URL.Query().Get("limit"); value != "" {
+		var err error
+		if limit, err = strconv.Atoi(value); err != nil {
+			writeError(w, http.StatusBadRequest, fmt.Errorf("invalid limit %q", value))
+			return
+		}
+	}
+	if keywords := r.URL.Query().Get("q"); keywords != "" {
+		matches, err := s.history.Search(r.Context(), keywords, limit)
+		if err != nil {
+			writeError(w, http.StatusBadRequest, err)
+			return
+		}
+		writeJSON(w, http.StatusOK, matches)
+		return
+	}
+	runs, err := s.history.List(r.Context(), limit)
+	if err != nil {
+		writeError(w, http.StatusInternalServerError, err)
+		return
+	}
+	writeJSON(w, http.StatusOK, runs)
+}
+
+func (s *Server) getHistory(w http.ResponseWriter, r *http.Request) {
+	run, ok := s.lookupHistory(w, r)
+	if !ok {
+		return
+	}
+	writeJSON(w, http.StatusOK, run)
+}
+
+func (s *Server) historyReport(w http.ResponseWriter, r *http.Request) {
+	run, ok := s.lookupHistory(w, r)
+	if !ok {
+		return
+	}
+	s.export(w, r, run.ID, run.Report)
+}
+
+func (s *Server) deleteHistory(w http.ResponseWriter, r *http.Request) {
+	if !s.historyEnabled(w) {
+		return
+	}
+	id, err := s.history.Delete(r.Context(), r.PathValue("id"))
+	if err != nil {
+		writeHistoryError(w, err)
+		return
+	}
+	slog.Info("run deleted from history", "run", id)
+	w.WriteHeader(http.StatusNoContent)
+}
+
+func (s *Server) lookupHistory(w http.ResponseWriter, r *http.Request) (*history.Run, bool) {
+	if !s.historyEnabled(w) {
+		return nil, false
+	}
+	run, err := s.history.Get(r.Context(), r.PathValue("id"))
+	if err != nil {
+		writeHistoryError(w, err)
+		return nil, false
+	}
+	return run, true
+}
+
+func (s *Server) historyEnabled(w http.ResponseWriter) bool {
+	if s.history == nil {
+		writeError(w, http.StatusNotFound, fmt.Errorf("history is disabled"))
+	}
+	return s.history != nil
+}
+
+func writeHistoryError(w http.ResponseWriter, err error) {
+	if errors.Is(err, history.ErrNotFound) {
+		writeError(w, http.StatusNotFound, err)
+		return
+	}
+	writeError(w, http.StatusInternalServerError, err)
+}
@@ -0,0 +1,183 @@
+package web
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"time"
+
+	"github.com/rickif/tiny-research/internal/agent"
+	"github.com/rickif/tiny-research/internal/report"
+	"github.com/rickif/tiny-research/internal/transcript"
+)
+
+// Run statuses.
+const (
+	StatusRunning    = "running"
+	StatusCompleted  = "completed"
+	StatusIncomplete = "incomplete"
+	StatusCanceled   = "canceled"
+	StatusFailed     = "failed"
+)
+
+// maxPreview bounds the tool outputs and model outputs streamed to the
+// browser; the full ones are in the transcript.
+const maxPreview = 2000
+
+var _ transcript.Recorder = (*run)(nil)
+
+// run is a research run started from the UI. It records the events of the
+// run for the live view.
+type run struct {
+	id      string
+	request runRequest
+	started time.Time
+	cancel  context.CancelFunc
+
+	mu       sync.Mutex
+	status   string
+	err      string
+	finished time.Time
+	events   []event
+	report   *report.Report
+	// changed is closed and replaced whenever the run changes, waking up the
+	// event streams
+	changed chan struct{}
+}
+
+// event is a transcript event as streamed to the browser, without prompts
+// and with outputs cut to a preview.
+type event struct {
+	transcript.Event
+	OutputBytes int `json:"output_bytes,omitempty"`
+}
+
+// summary describes a run in the history list.
+type summary struct {
+	ID        string        `json:"id"`
+	Query     string        `json:"query"`
+	Style     string        `json:"style,omitempty"`
+	Locale    string        `json:"locale,omitempty"`
+	Status    string        `json:"status"`
+	Error     string        `json:"error,omitempty"`
+	Title     string        `json:"title,omitempty"`
+	Started   time.Time     `json:"started"`
+	Duration  time.Duration `json:"duration"`
+	ToolCalls int           `json:"tool_calls"`
+}
+
+func newRun(id string, request runRequest, cancel context.CancelFunc) *run {
+	return &run{
+		id:      id,
+		request: request,
+		started: time.Now(),
+		cancel:  cancel,
+		status:  StatusRunning,
+		changed: make(chan struct{}),
+	}
+}
+
+// Record keeps event for the live view and wakes up its streams.
+func (r *run) Record(e transcript.Event) {
+	streamed := event{Event: e}
+	streamed.Messages = nil
+	if e.Kind == transcript.KindReport {
+		// the report is served rendered once the run is done
+		streamed.Content = ""
+	}
+	streamed.Content = preview(streamed.Content)
+	if e.Output != "" {
+		streamed.OutputBytes = len(e.Output)
+		streamed.Output = preview(e.Output)
+	}
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.events = append(r.events, streamed)
+	r.notify()
+}
+
+// finish records the result of the run.
+func (r *run) finish(result *report.Report, err error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	r.report = result
+	r.finished = time.Now()
+	switch {
+	case err == nil:
+		r.status = StatusCompleted
+	case errors.Is(err, agent.ErrIncomplete):
+		r.status = StatusIncomplete
+	case errors.Is(err, agent.ErrCanceled):
+		r.status = StatusCanceled
+	default:
+		r.status = StatusFailed
+	}
+	if err != nil {
+		r.err = err.Error()
+	}
+	r.notify()
+}
+
+// notify wakes up the event streams; r.mu must be held.
+func (r *run) notify() {
+	close(r.changed)
+	r.changed = make(chan struct{})
+}
+
+// since returns the events after the one numbered seq, whether the run is
+// done, and a channel closed on the next change.
+func (r *run) since(seq int) (events []event, done bool, changed <-chan struct{}) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	for _, e := range r.events {
+		if e.Seq > seq {
+			events = append(events, e)
+		}
+	}
+	return events, r.status != StatusRunning, r.changed
+}
+
+func (r *run) result() *report.Report {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return r.report
+}
+
+func (r *run) summary() summary {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	s := summary{
+		ID:      r.id,
+		Query:   r.request.Query,
+		Style:   r.request.Style,
+		Locale:  r.request.Locale,
+		Status:  r.status,
+		Error:   r.err,
+		Started: r.started,
+	}
+	if r.finished.IsZero() {
+		s.Duration = time.Since(r.started)
+	} else {
+		s.Duration = r.finished.Sub(r.started)
+	}
+	if r.report != nil {
+		s.Title = r.report.Title
+		s.ToolCalls = r.report.Metadata.ToolCalls
+	}
+	return s
+}
+
+func preview(s string) string {
+	if len(s) <= maxPreview {
+		return s
+	}
+	cut := maxPreview
+	// do not split a UTF-8 sequence
+	for cut > 0 && s[cut]&0xC0 == 0x80 {
+		cut--
+	}
+	return s[:cut] + "…"
+}
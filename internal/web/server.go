@@ -0,0 +1,308 @@
+// Package web serves a small browser UI to submit research, watch the plan,
+// steps and tool calls of a run live, and read the reports of past runs.
+package web
+
+import (
+	"context"
+	"embed"
+	"encoding/json"
+	"fmt"
+	"io/fs"
+	"log/slog"
+	"net/http"
+	"slices"
+	"strconv"
+	"strings"
+	"sync"
+
+	"github.com/google/uuid"
+	"github.com/rickif/tiny-research/internal/agent"
+	"github.com/rickif/tiny-research/internal/history"
+	"github.com/rickif/tiny-research/internal/report"
+	"github.com/rickif/tiny-research/internal/telemetry"
+	"github.com/rickif/tiny-research/internal/transcript"
+)
+
+//go:embed static
+var staticFiles embed.FS
+
+// Researcher runs the research submitted from the UI.
+type Researcher interface {
+	Research(ctx context.Context, query string, options ...agent.ResearchOption) (*report.Report, error)
+}
+
+// Server serves the UI and its JSON API. Runs are kept in memory for the
+// lifetime of the server.
+type Server struct {
+	researcher    Researcher
+	history       *history.Store
+	reportOptions []report.Option
+
+	// ctx is the parent of the runs, canceled by Close
+	ctx    context.Context
+	cancel context.CancelFunc
+	wg     sync.WaitGroup
+
+	mu   sync.Mutex
+	runs map[string]*run
+	// order lists the run IDs, oldest first
+	order []string
+}
+
+// New creates a server running research with researcher and serving the
+// past runs of store, if not nil; reportOptions apply to the exported
+// reports.
+func New(researcher Researcher, store *history.Store, reportOptions ...report.Option) *Server {
+	ctx, cancel := context.WithCancel(context.Background())
+	return &Server{
+		researcher:    researcher,
+		history:       store,
+		reportOptions: reportOptions,
+		ctx:           ctx,
+		cancel:        cancel,
+		runs:          make(map[string]*run),
+	}
+}
+
+// Close cancels the runs in progress and waits for them to finish.
+func (s *Server) Close() {
+	s.cancel()
+	s.wg.Wait()
+}
+
+// Handler returns the handler of the UI, the API under /api/ and the
+// Prometheus metrics at /metrics.
+func (s *Server) Handler() http.Handler {
+	static, _ := fs.Sub(staticFiles, "static")
+	mux := http.NewServeMux()
+	mux.Handle("GET /", http.FileServerFS(static))
+	mux.Handle("GET /metrics", telemetry.MetricsHandler())
+	mux.HandleFunc("GET /api/options", s.options)
+	mux.HandleFunc("GET /api/runs", s.list)
+	mux.HandleFunc("POST /api/runs", s.start)
+	mux.HandleFunc("GET /api/runs/{id}", s.get)
+	mux.HandleFunc("GET /api/runs/{id}/events", s.events)
+	mux.HandleFunc("GET /api/runs/{id}/report", s.report)
+	mux.HandleFunc("POST /api/runs/{id}/cancel", s.cancelRun)
+	mux.HandleFunc("GET /api/history", s.listHistory)
+	mux.HandleFunc("GET /api/history/{id}", s.getHistory)
+	mux.HandleFunc("GET /api/history/{id}/report", s.historyReport)
+	mux.HandleFunc("DELETE /api/history/{id}", s.deleteHistory)
+	return mux
+}
+
+type runRequest struct {
+	Query  string   `json:"query"`
+	Style  string   `json:"style,omitempty"`
+	Locale string   `json:"locale,omitempty"`
+	Tools  []string `json:"tools,omitempty"`
+}
+
+func (s *Server) options(w http.ResponseWriter, r *http.Request) {
+	writeJSON(w, http.StatusOK, map[string]any{
+		"styles":  agent.ReportStyles(),
+		"style":   agent.ReportStyleDefault,
+		"locale":  "en-US",
+		"tools":   agent.ToolNames(),
+		"formats": report.Formats,
+	})
+}
+
+func (s *Server) list(w http.ResponseWriter, r *http.Request) {
+	s.mu.Lock()
+	runs := make([]summary, 0, len(s.order))
+	for _, id := range slices.Backward(s.order) {
+		runs = append(runs, s.runs[id].summary())
+	}
+	s.mu.Unlock()
+	writeJSON(w, http.StatusOK, runs)
+}
+
+func (s *Server) start(w http.ResponseWriter, r *http.Request) {
+	var request runRequest
+	if err := json.NewDecoder(http.MaxBytesReader(w, r.Body, 1<<20)).Decode(&request); err != nil {
+		writeError(w, http.StatusBadRequest, fmt.Errorf("decode request: %w", err))
+		return
+	}
+	request.Query = strings.TrimSpace(request.Query)
+	if request.Query == "" {
+		writeError(w, http.StatusBadRequest, fmt.Errorf("query is required"))
+		return
+	}
+	if request.Style == "" {
+		request.Style = agent.ReportStyleDefault
+	}
+	if _, err := agent.LookupReportStyle(request.Style); err != nil {
+		writeError(w, http.StatusBadRequest, err)
+		return
+	}
+	for _, name := range request.Tools {
+		if !slices.Contains(agent.ToolNames(), name) {
+			writeError(w, http.StatusBadRequest, fmt.Errorf("unknown tool %q, expected one of %s", name, strings.Join(agent.ToolNames(), ", ")))
+			return
+		}
+	}
+
+	options := []agent.ResearchOption{agent.WithReportStyle(request.Style)}
+	if request.Locale != "" {
+		options = append(options, agent.WithLocale(request.Locale))
+	}
+	if request.Tools != nil {
+		options = append(options, agent.WithTools(request.Tools...))
+	}
+
+	ctx, cancel := context.WithCancel(s.ctx)
+	run := newRun(uuid.NewString(), request, cancel)
+	s.mu.Lock()
+	s.runs[run.id] = run
+	s.order = append(s.order, run.id)
+	s.mu.Unlock()
+
+	s.wg.Add(1)
+	go func() {
+		defer s.wg.Done()
+		defer cancel()
+		result, err := s.researcher.Research(transcript.NewContext(ctx, run), request.Query, options...)
+		if err != nil {
+			slog.Error("research", "run", run.id, "error", err)
+		}
+		run.finish(result, err)
+	}()
+	slog.Info("research started", "run", run.id, "query", request.Query)
+	writeJSON(w, http.StatusAccepted, run.summary())
+}
+
+func (s *Server) get(w http.ResponseWriter, r *http.Request) {
+	run, ok := s.lookup(w, r)
+	if !ok {
+		return
+	}
+	events, _, _ := run.since(0)
+	writeJSON(w, http.StatusOK, struct {
+		summary
+		Events []event `json:"events"`
+	}{run.summary(), events})
+}
+
+// events streams the events of a run as server-sent events, from the one
+// after Last-Event-ID when the browser reconnects, and ends with a done
+// event holding the summary of the run.
+func (s *Server) events(w http.ResponseWriter, r *http.Request) {
+	run, ok := s.lookup(w, r)
+	if !ok {
+		return
+	}
+	flusher, ok := w.(http.Flusher)
+	if !ok {
+		writeError(w, http.StatusInternalServerError, fmt.Errorf("streaming is not supported"))
+		return
+	}
+	sent, _ := strconv.Atoi(r.Header.Get("Last-Event-ID"))
+
+	w.Header().Set("Content-Type", "text/event-stream")
+	w.Header().Set("Cache-Control", "no-cache")
+	for {
+		events, done, changed := run.since(sent)
+		for _, e := range events {
+			b, err := json.Marshal(e)
+			if err != nil {
+				slog.Error("encode event", "error", err)
+				return
+			}
+			fmt.Fprintf(w, "id: %d\nevent: event\ndata: %s\n\n", e.Seq, b)
+			sent = e.Seq
+		}
+		if done {
+			b, _ := json.Marshal(run.summary())
+			fmt.Fprintf(w, "event: done\ndata: %s\n\n", b)
+			flusher.Flush()
+			return
+		}
+		flusher.Flush()
+
+		select {
+		case <-changed:
+		case <-r.Context().Done():
+			return
+		}
+	}
+}
+
+func (s *Server) report(w http.ResponseWriter, r *http.Request) {
+	run, ok := s.lookup(w, r)
+	if !ok {
+		return
+	}
+	result := run.result()
+	if result == nil {
+		writeError(w, http.StatusNotFound, fmt.Errorf("run %s has no report", run.id))
+		return
+	}
+	s.export(w, r, run.id, result)
+}
+
+// export writes the report of run id in the format of the request, HTML by
+// default, as an attachment if the request asks for a download.
+func (s *Server) export(w http.ResponseWriter, r *http.Request, id string, result *report.Report) {
+	format := report.FormatHTML
+	if value := r.URL.Query().Get("format"); value != "" {
+		var err error
+		if format, err = report.ParseFormat(value); err != nil {
+			writeError(w, http.StatusBadRequest, err)
+			return
+		}
+	}
+
+	w.Header().Set("Content-Type", contentType(format))
+	if r.URL.Query().Has("download") {
+		w.Header().Set("Content-Disposition", fmt.Sprintf("attachment; filename=%q", "report."+string(format)))
+	}
+	if err := result.Export(w, format, s.reportOptions...); err != nil {
+		slog.Error("export report", "run", id, "error", err)
+	}
+}
+
+func (s *Server) cancelRun(w http.ResponseWriter, r *http.Request) {
+	run, ok := s.lookup(w, r)
+	if !ok {
+		return
+	}
+	run.cancel()
+	writeJSON(w, http.StatusAccepted, run.summary())
+}
+
+func (s *Server) lookup(w http.ResponseWriter, r *http.Request) (*run, bool) {
+	s.mu.Lock()
+	run, ok := s.runs[r.PathValue("id")]
+	s.mu.Unlock()
+	if !ok {
+		writeError(w, http.StatusNotFound, fmt.Errorf("run %s not found", r.PathValue("id")))
+	}
+	return run, ok
+}
+
+func contentType(format report.Format) string {
+	switch format {
+	case report.FormatHTML:
+		return "text/html; charset=utf-8"
+	case report.FormatPDF:
+		return "application/pdf"
+	case report.FormatDOCX:
+		return "application/vnd.openxmlformats-officedocument.wordprocessingml.document"
+	case report.FormatJSON:
+		return "application/json"
+	}
+	return "text/markdown; charset=utf-8"
+}
+
+func writeJSON(w http.ResponseWriter, status int, value any) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	if err := json.NewEncoder(w).Encode(value); err != nil {
+		slog.Error("write response", "error", err)
+	}
+}
+
+func writeError(w http.ResponseWriter, status int, err error) {
+	writeJSON(w, status, map[string]string{"error": err.Error()})
+}
@@ -1,18 +1,90 @@
 package main
 
 import (
+	"bufio"
 	"context"
+	"errors"
+	"flag"
 	"fmt"
+	"io"
 	"log/slog"
+	"net"
+	"net/http"
 	"os"
+	"os/signal"
 	"path/filepath"
+	"strings"
+	"time"
 
 	"github.com/rickif/tiny-research/internal/agent"
+	"github.com/rickif/tiny-research/internal/batch"
 	"github.com/rickif/tiny-research/internal/config"
+	"github.com/rickif/tiny-research/internal/history"
+	"github.com/rickif/tiny-research/internal/report"
+	"github.com/rickif/tiny-research/internal/schedule"
+	"github.com/rickif/tiny-research/internal/telemetry"
+	"github.com/rickif/tiny-research/internal/templates"
+	"github.com/rickif/tiny-research/internal/tui"
+	"github.com/rickif/tiny-research/internal/web"
 )
 
 func main() {
-	slog.SetDefault(slog.New(slog.NewTextHandler(os.Stdout, &slog.HandlerOptions{Level: slog.LevelInfo, AddSource: true,
+	noCache := flag.Bool("no-cache", false, "disable the response cache for search, crawl and LLM calls")
+	refresh := flag.Bool("refresh", false, "ignore cached responses but store fresh ones")
+	record := flag.String("record", "", "record every LLM and tool call of the run to this cassette file")
+	replay := flag.String("replay", "", "serve LLM and tool calls from this cassette file instead of the network")
+	format := flag.String("format", "", "report format: md, html, pdf, docx or json (default: from the -o extension, else md)")
+	output := flag.String("o", "", "write the report to this file instead of stdout")
+	style := flag.String("style", agent.ReportStyleDefault, "report style: "+strings.Join(agent.ReportStyles(), ", "))
+	verify := flag.Bool("verify", false, "fact-check the key claims of the findings against their sources before reporting")
+	critique := flag.Bool("critique", false, "review the report and revise it, with follow-up research when needed")
+	chat := flag.Bool("chat", false, "after the report, read follow-up questions from stdin, one per line")
+	batchFile := flag.String("batch", "", "research every query of this CSV or JSONL file, with optional locale and style columns")
+	batchDir := flag.String("batch-dir", "reports", "directory of the batch reports and their index; completed queries in it are skipped")
+	concurrency := flag.Int("concurrency", 2, "number of batch queries researched at once")
+	templateName := flag.String("template", "", "research the query of this template of TEMPLATES_DIR, bound with -var")
+	vars := make(map[string]string)
+	flag.Func("var", "bind a template variable, as name=value; repeatable", func(s string) error {
+		name, value, ok := strings.Cut(s, "=")
+		if !ok {
+			return fmt.Errorf("expected name=value, got %q", s)
+		}
+		vars[strings.TrimSpace(name)] = value
+		return nil
+	})
+	listTemplates := flag.Bool("templates", false, "list the templates of TEMPLATES_DIR and exit")
+	runSchedule := flag.Bool("schedule", false, "run the jobs of SCHEDULE_FILE on their cron schedules until interrupted")
+	runJob := flag.String("schedule-run", "", "run the job of SCHEDULE_FILE with this name once, now")
+	transcriptDir := flag.String("transcript", "", "write the transcript of every run to this directory, as JSONL and an HTML timeline (default: TRANSCRIPT_DIR)")
+	traceExporter := flag.String("trace", "", "export OpenTelemetry spans: otlp or stdout (default: TRACE_EXPORTER)")
+	metricsAddr := flag.String("metrics-addr", "", "serve Prometheus metrics on this address at /metrics, e.g. :9090 (default: METRICS_ADDR)")
+	serveAddr := flag.String("serve", "", "serve the web UI on this address, e.g. :8080, instead of researching a query (default: SERVE_ADDR)")
+	interactive := flag.Bool("tui", false, "research the query in a terminal UI showing the plan, tool calls and token usage live")
+	listHistory := flag.Bool("history", false, "list the past runs of HISTORY_DB, newest first, and exit")
+	searchHistory := flag.String("history-search", "", "list the past runs whose query, report, a finding or a source contains all these keywords, and exit")
+	historyLimit := flag.Int("history-limit", 20, "number of past runs listed by -history and -history-search; 0 lists them all")
+	showRun := flag.String("history-show", "", "print the query, metrics, plan, findings and sources of the past run with this ID or ID prefix, and exit")
+	exportRun := flag.String("history-export", "", "write the report of the past run with this ID or ID prefix, in -format to -o, and exit")
+	deleteRun := flag.String("history-delete", "", "delete the past run with this ID or ID prefix, and exit")
+	timeout := flag.Duration("timeout", 0, "cancel the research after this duration and print the partial report")
+	flag.Parse()
+
+	// stdout carries the report, so the logs go to stderr
+	logOutput := io.Writer(os.Stderr)
+	if *interactive {
+		// the terminal belongs to the UI, so the logs go to a file
+		logFile, err := os.CreateTemp("", "tiny-research-*.log")
+		if err != nil {
+			fmt.Fprintln(os.Stderr, "create log file:", err)
+			return
+		}
+		defer func() {
+			logFile.Close()
+			fmt.Fprintln(os.Stderr, "logs written to", logFile.Name())
+		}()
+		logOutput = logFile
+	}
+	slog.SetDefault(slog.New(slog.NewTextHandler(logOutput, &slog.HandlerOptions{Level: slog.LevelInfo, AddSource: true,
 		ReplaceAttr: func(groups []string, a slog.Attr) slog.Attr {
 			if a.Key == slog.SourceKey {
 				source, _ := a.Value.Any().(*slog.Source)
@@ -28,17 +100,426 @@ func main() {
 		slog.Error("load config", "error", err)
 		return
 	}
+	config.NoCache = *noCache
+	config.RefreshCache = *refresh
+	config.RecordPath = *record
+	config.ReplayPath = *replay
+	if *verify {
+		config.VerifyClaims = true
+	}
+	if *critique {
+		config.CritiqueReport = true
+	}
+	if *transcriptDir != "" {
+		config.TranscriptDir = *transcriptDir
+	}
+	if *traceExporter != "" {
+		config.TraceExporter = *traceExporter
+	}
+	if *metricsAddr != "" {
+		config.MetricsAddr = *metricsAddr
+	}
+	if *serveAddr != "" {
+		config.ServeAddr = *serveAddr
+	}
+
+	reportFormat := report.FormatMarkdown
+	switch {
+	case *format != "":
+		reportFormat, err = report.ParseFormat(*format)
+	case *output != "":
+		reportFormat, err = report.FormatFromPath(*output)
+	}
+	if err != nil {
+		slog.Error("report format", "error", err)
+		return
+	}
+	if _, err := agent.LookupReportStyle(*style); err != nil {
+		slog.Error("report style", "error", err)
+		return
+	}
+	if *listTemplates {
+		if err := printTemplates(config.TemplatesDir); err != nil {
+			slog.Error("list templates", "error", err)
+		}
+		return
+	}
+	if *listHistory || *searchHistory != "" || *showRun != "" || *exportRun != "" || *deleteRun != "" {
+		if config.HistoryDB == "" || config.HistoryDB == "off" {
+			slog.Error("history", "error", "HISTORY_DB is off")
+			return
+		}
+		store, err := history.Open(config.HistoryDB)
+		if err != nil {
+			slog.Error("open history", "error", err)
+			return
+		}
+		defer store.Close()
+
+		ctx := context.Background()
+		switch {
+		case *showRun != "":
+			err = printRun(ctx, store, *showRun)
+		case *exportRun != "":
+			var run *history.Run
+			if run, err = store.Get(ctx, *exportRun); err == nil {
+				err = writeReport(run.Report, reportFormat, *output, report.WithPDFFont(config.ReportPDFFont))
+			}
+		case *deleteRun != "":
+			var id string
+			if id, err = store.Delete(ctx, *deleteRun); err == nil {
+				fmt.Println("deleted", id)
+			}
+		default:
+			err = printHistory(ctx, store, *searchHistory, *historyLimit)
+		}
+		if err != nil {
+			slog.Error("history", "error", err)
+		}
+		return
+	}
+
+	query := "What's the weather like in Chengdu today?"
+	options := []agent.ResearchOption{agent.WithReportStyle(*style)}
+	if *templateName != "" {
+		// the template is bound before the agent starts, so a bad variable
+		// fails fast; an explicit -style overrides the style of the template
+		t, err := templates.Find(config.TemplatesDir, *templateName)
+		if err != nil {
+			slog.Error("load template", "error", err)
+			return
+		}
+		var templateOptions []agent.ResearchOption
+		query, templateOptions, err = t.Bind(vars)
+		if err != nil {
+			slog.Error("bind template", "error", err)
+			return
+		}
+		if !flagSet("style") {
+			options = nil
+		}
+		options = append(templateOptions, options...)
+	}
+
+	shutdownTracing, err := telemetry.Setup(context.Background(), config.TraceExporter)
+	if err != nil {
+		slog.Error("setup tracing", "error", err)
+		return
+	}
+	defer func() {
+		// the run context may be canceled already, so flushing gets its own
+		ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+		defer cancel()
+		if err := shutdownTracing(ctx); err != nil {
+			slog.Error("flush traces", "error", err)
+		}
+	}()
+
+	if config.MetricsAddr != "" {
+		stopMetrics, err := serveMetrics(config.MetricsAddr)
+		if err != nil {
+			slog.Error("serve metrics", "error", err)
+			return
+		}
+		defer stopMetrics()
+	}
+
 	agent, err := agent.NewAgent(config)
 	if err != nil {
 		slog.Error("new agent", "error", err)
 		return
 	}
+	defer agent.Close()
+
+	// the first interrupt cancels the run and prints the partial report, a
+	// second one kills the process
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt)
+	defer stop()
+	go func() {
+		<-ctx.Done()
+		stop()
+	}()
+	if *timeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, *timeout)
+		defer cancel()
+	}
+
+	if config.ServeAddr != "" {
+		if err := serveWeb(ctx, config.ServeAddr, web.New(agent, agent.History(), report.WithPDFFont(config.ReportPDFFont))); err != nil {
+			slog.Error("serve web ui", "error", err)
+		}
+		return
+	}
 
-	result, err := agent.Research(context.Background(), "What's the weather like in Chengdu today?")
+	if *runSchedule || *runJob != "" {
+		jobs, err := schedule.Load(config.ScheduleFile, config.TemplatesDir)
+		if err != nil {
+			slog.Error("load schedule", "error", err)
+			return
+		}
+		scheduler := schedule.New(agent, jobs, config.ScheduleDir, report.WithPDFFont(config.ReportPDFFont))
+		if *runJob != "" {
+			_, err = scheduler.RunJob(ctx, *runJob)
+		} else {
+			err = scheduler.Run(ctx)
+		}
+		if err != nil {
+			slog.Error("schedule", "error", err)
+		}
+		return
+	}
+
+	if *batchFile != "" {
+		jobs, err := batch.Load(*batchFile)
+		if err != nil {
+			slog.Error("load batch", "error", err)
+			return
+		}
+		for i := range jobs {
+			if jobs[i].Style == "" {
+				jobs[i].Style = *style
+			}
+		}
+		if _, err := batch.Run(ctx, agent, jobs, batch.Options{
+			Dir:           *batchDir,
+			Concurrency:   *concurrency,
+			Format:        reportFormat,
+			ReportOptions: []report.Option{report.WithPDFFont(config.ReportPDFFont)},
+		}); err != nil {
+			slog.Error("batch", "error", err)
+		}
+		return
+	}
+
+	if *interactive {
+		result, err := tui.Run(ctx, agent, query, tui.Options{
+			InputPrice:  config.LLMInputPrice,
+			OutputPrice: config.LLMOutputPrice,
+		}, options...)
+		if err != nil {
+			slog.Error("research", "error", err)
+		}
+		if result != nil && *output != "" {
+			if err := writeReport(result, reportFormat, *output, report.WithPDFFont(config.ReportPDFFont)); err != nil {
+				slog.Error("write report", "error", err)
+			}
+		}
+		return
+	}
+
+	session, err := agent.NewSession(options...)
 	if err != nil {
-		slog.Error("research", "error", err)
+		slog.Error("new session", "error", err)
+		return
+	}
+	ask := func(question string) bool {
+		result, err := session.Ask(ctx, question)
+		for _, stat := range agent.CacheStats() {
+			slog.Info("cache stats", "kind", stat.Kind, "hits", stat.Hits, "misses", stat.Misses, "hit_rate", fmt.Sprintf("%.1f%%", stat.HitRate()*100))
+		}
+		if err != nil {
+			slog.Error("research", "error", err)
+			if result == nil {
+				return ctx.Err() == nil
+			}
+		}
+		if err := writeReport(result, reportFormat, *output, report.WithPDFFont(config.ReportPDFFont)); err != nil {
+			slog.Error("write report", "error", err)
+		}
+		return ctx.Err() == nil
+	}
+
+	if !ask(query) || !*chat {
 		return
 	}
+	// every answer overwrites the -o file, so it always holds the latest one
+	scanner := bufio.NewScanner(os.Stdin)
+	for {
+		fmt.Fprint(os.Stderr, "> ")
+		if !scanner.Scan() {
+			return
+		}
+		question := strings.TrimSpace(scanner.Text())
+		if question == "" {
+			continue
+		}
+		if !ask(question) {
+			return
+		}
+	}
+}
+
+// serveMetrics serves the Prometheus metrics at addr/metrics until the
+// returned function is called.
+func serveMetrics(addr string) (stop func(), err error) {
+	listener, err := net.Listen("tcp", addr)
+	if err != nil {
+		return nil, err
+	}
+	mux := http.NewServeMux()
+	mux.Handle("/metrics", telemetry.MetricsHandler())
+	server := &http.Server{Addr: addr, Handler: mux, ReadHeaderTimeout: 10 * time.Second}
+	go func() {
+		if err := server.Serve(listener); err != nil && !errors.Is(err, http.ErrServerClosed) {
+			slog.Error("serve metrics", "error", err)
+		}
+	}()
+	slog.Info("serving metrics", "addr", listener.Addr().String(), "path", "/metrics")
+	return func() {
+		ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+		defer cancel()
+		server.Shutdown(ctx)
+	}, nil
+}
+
+// serveWeb serves the web UI at addr until ctx is done, then cancels the
+// runs in progress.
+func serveWeb(ctx context.Context, addr string, ui *web.Server) error {
+	defer ui.Close()
+	listener, err := net.Listen("tcp", addr)
+	if err != nil {
+		return err
+	}
+	server := &http.Server{Addr: addr, Handler: ui.Handler(), ReadHeaderTimeout: 10 * time.Second}
+	go func() {
+		<-ctx.Done()
+		// the event streams of running research stay open, so shutting down
+		// does not wait for them for long
+		shutdownCtx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+		defer cancel()
+		server.Shutdown(shutdownCtx)
+	}()
+	slog.Info("serving web ui", "addr", listener.Addr().String())
+	if err := server.Serve(listener); !errors.Is(err, http.ErrServerClosed) {
+		return err
+	}
+	return nil
+}
+
+func flagSet(name string) bool {
+	set := false
+	flag.Visit(func(f *flag.Flag) {
+		set = set || f.Name == name
+	})
+	return set
+}
+
+func printTemplates(dir string) error {
+	list, err := templates.List(dir)
+	if err != nil {
+		return err
+	}
+	for _, t := range list {
+		fmt.Printf("%s\t%s\n", t.Name, t.Description)
+		for _, v := range t.Variables {
+			fmt.Printf("  -var %s=", v.Name)
+			switch {
+			case len(v.Values) > 0:
+				fmt.Printf("{%s}", strings.Join(v.Values, ","))
+			case v.Default == "":
+				fmt.Print("(required)")
+			}
+			if v.Default != "" {
+				fmt.Printf(" (default %q)", v.Default)
+			}
+			if v.Description != "" {
+				fmt.Printf("\t%s", v.Description)
+			}
+			fmt.Println()
+		}
+	}
+	return nil
+}
+
+// printHistory lists the past runs, or those matching keywords with the
+// passages that matched.
+func printHistory(ctx context.Context, store *history.Store, keywords string, limit int) error {
+	if keywords == "" {
+		runs, err := store.List(ctx, limit)
+		if err != nil {
+			return err
+		}
+		for _, run := range runs {
+			printSummary(run)
+		}
+		return nil
+	}
+	matches, err := store.Search(ctx, keywords, limit)
+	if err != nil {
+		return err
+	}
+	for _, match := range matches {
+		printSummary(match.Summary)
+		for _, snippet := range match.Snippets {
+			fmt.Printf("  %s %q: %s\n", snippet.Kind, snippet.Label, strings.Join(strings.Fields(snippet.Text), " "))
+		}
+	}
+	return nil
+}
+
+func printSummary(run history.Summary) {
+	fmt.Printf("%s\t%s\t%s\t%s\t%s\n", run.ID[:8], run.CreatedAt.Format(time.DateTime), run.Status, run.Duration.Round(time.Second), run.Query)
+}
+
+// printRun prints the query, metrics, plan, findings and sources of a past
+// run; -history-export writes its report.
+func printRun(ctx context.Context, store *history.Store, id string) error {
+	run, err := store.Get(ctx, id)
+	if err != nil {
+		return err
+	}
+	metadata := run.Report.Metadata
+	fmt.Printf("ID:        %s\n", run.ID)
+	fmt.Printf("Query:     %s\n", metadata.Query)
+	if run.Report.Title != "" {
+		fmt.Printf("Title:     %s\n", run.Report.Title)
+	}
+	fmt.Printf("Status:    %s\n", run.Status)
+	if run.Error != "" {
+		fmt.Printf("Error:     %s\n", run.Error)
+	}
+	fmt.Printf("Created:   %s\n", metadata.CreatedAt.Format(time.DateTime))
+	fmt.Printf("Style:     %s, %s\n", metadata.Style, run.Locale)
+	fmt.Printf("Model:     %s\n", metadata.Model)
+	fmt.Printf("Session:   %s, turn %d\n", metadata.SessionID, metadata.Turn)
+	fmt.Printf("Duration:  %s\n", metadata.Duration.Round(time.Second))
+	fmt.Printf("Calls:     %d LLM, %d tools\n", run.LLMCalls, metadata.ToolCalls)
+	fmt.Printf("Tokens:    %d in, %d out\n", run.InputTokens, run.OutputTokens)
+	if plan := metadata.Plan; plan != nil {
+		fmt.Printf("\nPlan: %s\n", plan.Title)
+		for i, step := range plan.Steps {
+			fmt.Printf("\n%d. %s\n", i+1, step.Title)
+			if step.Result != "" {
+				fmt.Println(strings.TrimSpace(step.Result))
+			}
+		}
+	}
+	if len(run.Sources) > 0 {
+		fmt.Println("\nSources:")
+		for _, source := range run.Sources {
+			fmt.Printf("- %s %s (%d bytes)\n", source.Tool, source.Input, len(source.Content))
+		}
+	}
+	return nil
+}
 
-	fmt.Println(result)
+func writeReport(result *report.Report, format report.Format, path string, options ...report.Option) error {
+	if path == "" {
+		return result.Export(os.Stdout, format, options...)
+	}
+
+	f, err := os.Create(path)
+	if err != nil {
+		return err
+	}
+	if err := result.Export(f, format, options...); err != nil {
+		f.Close()
+		return err
+	}
+	if err := f.Close(); err != nil {
+		return err
+	}
+	slog.Info("report written", "path", path, "format", format)
+	return nil
 }
@@ -0,0 +1,25 @@
+package util
+
+import (
+	"os"
+	"path/filepath"
+)
+
+// WriteFile writes path through a temporary file in the same directory,
+// renamed over path once write succeeds, so a crash never leaves a
+// truncated file behind.
+func WriteFile(path string, write func(f *os.File) error) error {
+	f, err := os.CreateTemp(filepath.Dir(path), "."+filepath.Base(path)+".*")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(f.Name())
+	if err := write(f); err != nil {
+		f.Close()
+		return err
+	}
+	if err := f.Close(); err != nil {
+		return err
+	}
+	return os.Rename(f.Name(), path)
+}
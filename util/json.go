@@ -1,15 +1,389 @@
 package util
 
-import "strings"
-
-func FixJSON(input string) string {
-	input = strings.TrimSpace(input)
-	if strings.HasPrefix(input, "```json") {
-		input = strings.TrimPrefix(input, "```json")
-		input = strings.TrimSuffix(input, "```")
-	} else if strings.HasPrefix(input, "```") {
-		input = strings.TrimPrefix(input, "```")
-		input = strings.TrimSuffix(input, "```")
-	}
-	return input
+import (
+	"encoding/json"
+	"fmt"
+	"strings"
+)
+
+// FixJSON extracts the first JSON object from LLM output and repairs the
+// syntax mistakes models commonly make: surrounding prose or code fences,
+// comments, single-quoted strings, bare keys, Python literals, unescaped
+// control characters and quotes in strings, missing and trailing commas, and
+// output truncated before the closing brackets. It returns the repaired JSON
+// along with a description of every repair applied. A valid object, or
+// input without any object, is returned trimmed, so the caller's decoder
+// reports the error of the latter.
+func FixJSON(input string) (string, []string) {
+	text := strings.TrimSpace(input)
+	r := &jsonRepairer{}
+	if strings.HasPrefix(text, "{") && json.Valid([]byte(text)) {
+		return text, nil
+	}
+
+	// a fence after the start of the object is part of a string, e.g. a
+	// code block in a report
+	if fence := strings.Index(text, "```"); fence >= 0 && (strings.IndexByte(text, '{') < 0 || fence < strings.IndexByte(text, '{')) {
+		if fenced, ok := fencedBlock(text); ok {
+			text = fenced
+			r.note("removed code fence")
+		}
+	}
+
+	start := strings.IndexByte(text, '{')
+	if start < 0 {
+		return text, r.repairs
+	}
+	if strings.TrimSpace(text[:start]) != "" {
+		r.note("removed leading text")
+	}
+
+	end := r.scan(text, start)
+	if strings.TrimSpace(text[end:]) != "" {
+		r.note("removed trailing text")
+	}
+	return string(r.out), r.repairs
+}
+
+// fencedBlock returns the content of the first ``` fenced block in text.
+func fencedBlock(text string) (string, bool) {
+	open := strings.Index(text, "```")
+	if open < 0 {
+		return "", false
+	}
+	body := text[open+3:]
+	// skip the info string, e.g. ```json
+	if newline := strings.IndexByte(body, '\n'); newline >= 0 && !strings.ContainsAny(body[:newline], "{[") {
+		body = body[newline+1:]
+	} else {
+		body = strings.TrimPrefix(body, "json")
+	}
+	if end := strings.Index(body, "```"); end >= 0 {
+		body = body[:end]
+	}
+	return strings.TrimSpace(body), true
+}
+
+type jsonRepairer struct {
+	out       []byte
+	stack     []byte
+	afterItem bool
+	repairs   []string
+}
+
+func (r *jsonRepairer) note(repair string) {
+	for _, existing := range r.repairs {
+		if existing == repair {
+			return
+		}
+	}
+	r.repairs = append(r.repairs, repair)
+}
+
+// scan writes the repaired object starting at s[start] and returns the index
+// just past the end of the object.
+func (r *jsonRepairer) scan(s string, start int) int {
+	i := start
+	for i < len(s) {
+		c := s[i]
+		switch {
+		case c == ' ' || c == '\t' || c == '\n' || c == '\r':
+			r.out = append(r.out, c)
+			i++
+		case c == '/' && i+1 < len(s) && (s[i+1] == '/' || s[i+1] == '*'):
+			i = skipComment(s, i)
+			r.note("removed comment")
+		case c == '{' || c == '[':
+			r.beginItem()
+			r.out = append(r.out, c)
+			r.stack = append(r.stack, c)
+			i++
+		case c == '}' || c == ']':
+			r.dropTrailingComma()
+			want := closing(r.stack[len(r.stack)-1])
+			if c != want {
+				r.note("fixed mismatched bracket")
+			}
+			r.out = append(r.out, want)
+			r.stack = r.stack[:len(r.stack)-1]
+			r.afterItem = true
+			i++
+			if len(r.stack) == 0 {
+				return i
+			}
+		case c == ',':
+			if r.afterItem {
+				r.out = append(r.out, c)
+			} else {
+				r.note("removed extra comma")
+			}
+			r.afterItem = false
+			i++
+		case c == ':':
+			r.out = append(r.out, c)
+			r.afterItem = false
+			i++
+		case c == '"' || c == '\'':
+			r.beginItem()
+			i = r.scanString(s, i)
+			r.afterItem = true
+		case c == '-' || c == '+' || c == '.' || isDigit(c):
+			r.beginItem()
+			i = r.scanNumber(s, i)
+			r.afterItem = true
+		case isIdentStart(c):
+			r.beginItem()
+			i = r.scanWord(s, i)
+			r.afterItem = true
+		default:
+			r.note("removed invalid character")
+			i++
+		}
+	}
+
+	r.closeTruncated()
+	return i
+}
+
+// beginItem inserts a comma when a new value or key directly follows the
+// previous one.
+func (r *jsonRepairer) beginItem() {
+	if r.afterItem && len(r.stack) > 0 {
+		r.insertComma()
+		r.note("inserted missing comma")
+	}
+	r.afterItem = false
+}
+
+func (r *jsonRepairer) insertComma() {
+	end := len(r.out)
+	for end > 0 && isSpace(r.out[end-1]) {
+		end--
+	}
+	r.out = append(r.out[:end], append([]byte{','}, r.out[end:]...)...)
+}
+
+func (r *jsonRepairer) dropTrailingComma() {
+	end := len(r.out)
+	for end > 0 && isSpace(r.out[end-1]) {
+		end--
+	}
+	if end > 0 && r.out[end-1] == ',' {
+		r.out = append(r.out[:end-1], r.out[end:]...)
+		r.note("removed trailing comma")
+	}
+}
+
+func (r *jsonRepairer) closeTruncated() {
+	if len(r.stack) == 0 {
+		return
+	}
+	end := len(r.out)
+	for end > 0 && isSpace(r.out[end-1]) {
+		end--
+	}
+	r.out = r.out[:end]
+	if end > 0 && r.out[end-1] == ':' {
+		r.out = append(r.out, "null"...)
+	}
+	r.dropTrailingComma()
+	for i := len(r.stack) - 1; i >= 0; i-- {
+		r.out = append(r.out, closing(r.stack[i]))
+	}
+	r.note(fmt.Sprintf("closed %d unclosed brackets", len(r.stack)))
+	r.stack = nil
+}
+
+func (r *jsonRepairer) scanString(s string, i int) int {
+	quote := s[i]
+	if quote == '\'' {
+		r.note("replaced single quotes")
+	}
+	r.out = append(r.out, '"')
+	i++
+	for i < len(s) {
+		c := s[i]
+		switch {
+		case c == '\\':
+			if i+1 < len(s) && quote == '\'' && s[i+1] == '\'' {
+				r.out = append(r.out, '\'')
+				i += 2
+			} else if i+1 < len(s) && strings.IndexByte(`"\/bfnrtu`, s[i+1]) >= 0 {
+				r.out = append(r.out, c, s[i+1])
+				i += 2
+			} else {
+				r.out = append(r.out, `\\`...)
+				r.note("escaped backslash in string")
+				i++
+			}
+		case c == quote:
+			if quote == '"' && !stringEnds(s, i+1) {
+				r.out = append(r.out, `\"`...)
+				r.note("escaped quote in string")
+				i++
+				continue
+			}
+			r.out = append(r.out, '"')
+			return i + 1
+		case c == '"':
+			r.out = append(r.out, `\"`...)
+			i++
+		case c == '\n':
+			r.out = append(r.out, `\n`...)
+			r.note("escaped control character in string")
+			i++
+		case c == '\r':
+			r.out = append(r.out, `\r`...)
+			r.note("escaped control character in string")
+			i++
+		case c == '\t':
+			r.out = append(r.out, `\t`...)
+			r.note("escaped control character in string")
+			i++
+		case c < 0x20:
+			r.out = append(r.out, fmt.Sprintf(`\u%04x`, c)...)
+			r.note("escaped control character in string")
+			i++
+		default:
+			r.out = append(r.out, c)
+			i++
+		}
+	}
+	r.out = append(r.out, '"')
+	r.note("closed unterminated string")
+	return i
+}
+
+// stringEnds reports whether a double quote followed by s[i:] closes the
+// string: the next token must end a value, start on a new line, or be the
+// next string of an object or array missing the comma before it.
+func stringEnds(s string, i int) bool {
+	newline := false
+	for ; i < len(s); i++ {
+		switch c := s[i]; {
+		case c == '\n':
+			newline = true
+		case isSpace(c):
+		case c == ',' || c == ':' || c == '}' || c == ']':
+			return true
+		case c == '"':
+			return newline || nextStringEnds(s, i)
+		default:
+			return newline
+		}
+	}
+	return true
+}
+
+// nextStringEnds reports whether the string starting at s[i] is followed by
+// a key separator or the end of a value, i.e. is a key or value of its own
+// rather than quoted text inside the current string.
+func nextStringEnds(s string, i int) bool {
+	for i++; i < len(s); i++ {
+		if s[i] == '\\' {
+			i++
+			continue
+		}
+		if s[i] == '"' {
+			break
+		}
+	}
+	if i >= len(s) {
+		return false
+	}
+	for i++; i < len(s) && isSpace(s[i]); i++ {
+	}
+	return i == len(s) || strings.IndexByte(",:}]", s[i]) >= 0
+}
+
+func (r *jsonRepairer) scanNumber(s string, i int) int {
+	start := i
+	for i < len(s) && (isDigit(s[i]) || strings.IndexByte("+-.eE", s[i]) >= 0) {
+		i++
+	}
+	number := s[start:i]
+	if strings.HasPrefix(number, "+") {
+		number = number[1:]
+		r.note("fixed number format")
+	}
+	if strings.HasPrefix(number, ".") || strings.HasPrefix(number, "-.") {
+		number = strings.Replace(number, ".", "0.", 1)
+		r.note("fixed number format")
+	}
+	if strings.HasSuffix(number, ".") {
+		number += "0"
+		r.note("fixed number format")
+	}
+	if number == "" || number == "-" {
+		number = "0"
+		r.note("fixed number format")
+	}
+	r.out = append(r.out, number...)
+	return i
+}
+
+func (r *jsonRepairer) scanWord(s string, i int) int {
+	start := i
+	for i < len(s) && (isIdentStart(s[i]) || isDigit(s[i]) || s[i] == '-') {
+		i++
+	}
+	word := s[start:i]
+	switch word {
+	case "true", "false", "null":
+		r.out = append(r.out, word...)
+	case "True", "False":
+		r.out = append(r.out, strings.ToLower(word)...)
+		r.note("replaced Python literal")
+	case "None":
+		r.out = append(r.out, "null"...)
+		r.note("replaced Python literal")
+	default:
+		if isKey(s, i) {
+			r.note("quoted bare key")
+		} else {
+			r.note("quoted bare value")
+		}
+		r.out = append(r.out, '"')
+		r.out = append(r.out, word...)
+		r.out = append(r.out, '"')
+	}
+	return i
+}
+
+func isKey(s string, i int) bool {
+	for ; i < len(s) && isSpace(s[i]); i++ {
+	}
+	return i < len(s) && s[i] == ':'
+}
+
+func skipComment(s string, i int) int {
+	if s[i+1] == '/' {
+		if end := strings.IndexByte(s[i:], '\n'); end >= 0 {
+			return i + end
+		}
+		return len(s)
+	}
+	if end := strings.Index(s[i+2:], "*/"); end >= 0 {
+		return i + 2 + end + 2
+	}
+	return len(s)
+}
+
+func closing(open byte) byte {
+	if open == '[' {
+		return ']'
+	}
+	return '}'
+}
+
+func isSpace(c byte) bool {
+	return c == ' ' || c == '\t' || c == '\n' || c == '\r'
+}
+
+func isDigit(c byte) bool {
+	return c >= '0' && c <= '9'
+}
+
+func isIdentStart(c byte) bool {
+	return c == '_' || c == '$' || (c >= 'a' && c <= 'z') || (c >= 'A' && c <= 'Z')
 }
@@ -0,0 +1,95 @@
+package util
+
+import (
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestFixJSON(t *testing.T) {
+	tests := []struct {
+		name  string
+		input string
+		want  string
+	}{
+		{name: "valid object", input: `{"a": 1}`, want: `{"a": 1}`},
+		{name: "fence in a string of a valid object", input: "{\"body\": \"```go\\nx := 1\\n```\"}", want: "{\"body\": \"```go\\nx := 1\\n```\"}"},
+		{name: "fence in a string of an invalid object", input: "{\"body\": \"```go\\nx := 1\\n```\", \"a\": 1,}", want: "{\"body\": \"```go\\nx := 1\\n```\", \"a\": 1}"},
+		{name: "fenced object", input: "```json\n{\"a\": 1}\n```", want: `{"a": 1}`},
+		{name: "missing comma between values", input: `{"a": "x" "b": 2}`, want: `{"a": "x", "b": 2}`},
+		{name: "missing comma in array", input: `{"a": ["x" "y"]}`, want: `{"a": ["x", "y"]}`},
+		{name: "missing comma after number", input: `{"a": 1 "b": 2}`, want: `{"a": 1, "b": 2}`},
+		{name: "quoted word in string", input: `{"a": "say "hi" now"}`, want: `{"a": "say \"hi\" now"}`},
+		{name: "truncated", input: `{"a": [1, 2`, want: `{"a": [1, 2]}`},
+		{name: "no object", input: " not json ", want: "not json"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got, _ := FixJSON(tt.input); got != tt.want {
+				t.Errorf("FixJSON(%q) = %q, want %q", tt.input, got, tt.want)
+			}
+		})
+	}
+}
+
+// TestFixJSONCorpus repairs model outputs in testdata/fixjson, each paired
+// with the JSON it must decode to.
+func TestFixJSONCorpus(t *testing.T) {
+	inputs, err := filepath.Glob("testdata/fixjson/*.txt")
+	if err != nil || len(inputs) == 0 {
+		t.Fatalf("no corpus: %v", err)
+	}
+	for _, path := range inputs {
+		name := strings.TrimSuffix(filepath.Base(path), ".txt")
+		t.Run(name, func(t *testing.T) {
+			input := readFile(t, path)
+			fixed, repairs := FixJSON(input)
+			var got, want any
+			if err := json.Unmarshal([]byte(fixed), &got); err != nil {
+				t.Fatalf("FixJSON = %q (repairs %q): %v", fixed, repairs, err)
+			}
+			if err := json.Unmarshal([]byte(readFile(t, strings.TrimSuffix(path, ".txt")+".json")), &want); err != nil {
+				t.Fatal(err)
+			}
+			if !reflect.DeepEqual(got, want) {
+				t.Errorf("FixJSON = %s, want %s", fixed, want)
+			}
+		})
+	}
+}
+
+func FuzzFixJSON(f *testing.F) {
+	inputs, _ := filepath.Glob("testdata/fixjson/*.txt")
+	for _, path := range inputs {
+		data, err := os.ReadFile(path)
+		if err != nil {
+			f.Fatal(err)
+		}
+		f.Add(string(data))
+	}
+	f.Fuzz(func(t *testing.T, input string) {
+		fixed, repairs := FixJSON(input)
+		text := strings.TrimSpace(input)
+		if strings.HasPrefix(text, "{") && json.Valid([]byte(text)) && (fixed != text || len(repairs) > 0) {
+			t.Errorf("valid object %q changed to %q", text, fixed)
+		}
+		// a repaired object is stable under another repair
+		if strings.HasPrefix(fixed, "{") && json.Valid([]byte(fixed)) {
+			if again, _ := FixJSON(fixed); again != fixed {
+				t.Errorf("FixJSON(%q) = %q, not stable", fixed, again)
+			}
+		}
+	})
+}
+
+func readFile(t *testing.T, path string) string {
+	t.Helper()
+	data, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return string(data)
+}